@@ -2,13 +2,13 @@ package app
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/aldelucca1/docsend_scraper/config"
 	"github.com/aldelucca1/docsend_scraper/service"
 	"github.com/gin-gonic/gin"
 	logger "github.com/sirupsen/logrus"
@@ -16,15 +16,17 @@ import (
 
 // App is our main application
 type App struct {
+	config  *config.Config
 	router  *gin.Engine
 	server  *http.Server
 	service *service.Service
 }
 
 // NewApp creates a new App instance
-func NewApp() *App {
+func NewApp(config *config.Config) *App {
 	app := new(App)
-	app.service = service.NewService()
+	app.config = config
+	app.service = service.NewService(config)
 	app.router = gin.New()
 	app.registerRoutes(app.router)
 	return app
@@ -56,7 +58,7 @@ func (a *App) Start() error {
 		return err
 	}
 	a.server = &http.Server{
-		Addr:    fmt.Sprintf(":%d", 8080),
+		Addr:    a.config.Server.Address,
 		Handler: a.router,
 	}
 	logger.Infof("Listening for HTTP on '%s'", a.server.Addr)
@@ -1,9 +1,14 @@
 package app
 
 import (
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/service"
 	"github.com/aldelucca1/docsend_scraper/store"
 	"github.com/gin-gonic/contrib/ginrus"
 	"github.com/gin-gonic/gin"
@@ -30,6 +35,18 @@ func (a *App) registerRoutes(router *gin.Engine) {
 	api.POST("documents", a.generate)
 	api.GET("documents/:id", a.get)
 	api.GET("documents/:id/download", a.download)
+	api.POST("documents/:id/cancel", a.cancel)
+	api.POST("documents/:id/challenge", a.challenge)
+	api.POST("documents/:id/recapture", a.recapture)
+	api.GET("documents/:id/versions", a.versions)
+	api.PUT("documents/:id/watch", a.watch)
+	api.DELETE("documents/:id/watch", a.unwatch)
+	api.GET("documents/:id/versions/:version/download", a.downloadVersion)
+	api.GET("collections", a.listCollections)
+	api.POST("collections", a.generateCollection)
+	api.GET("collections/:id", a.getCollection)
+	api.GET("collections/:id/download", a.downloadCollection)
+	api.POST("inspect", a.inspect)
 	api.GET("status", a.status)
 }
 
@@ -67,9 +84,14 @@ func (a *App) generate(c *gin.Context) {
 	urlStr := c.PostForm("source_url")
 	owner := c.PostForm("owner")
 	passcode := c.PostForm("passcode")
+	options, err := parseOptions(c)
+	if err != nil {
+		a.handleError(c, err)
+		return
+	}
 
 	// Generate the document
-	document, err := a.service.GenerateDocument(urlStr, owner, passcode)
+	document, err := a.service.GenerateDocument(urlStr, owner, passcode, options)
 	if err != nil {
 		a.handleError(c, err)
 		return
@@ -77,24 +99,303 @@ func (a *App) generate(c *gin.Context) {
 	c.JSON(http.StatusAccepted, document)
 }
 
+// parseOptions parses the capture option form fields shared by documents and
+// collections
+func parseOptions(c *gin.Context) (model.Options, error) {
+	options := model.Options{
+		Format: model.Format(c.PostForm("format")),
+		Layout: model.Layout(c.PostForm("layout")),
+	}
+	if dpi := c.PostForm("dpi"); dpi != "" {
+		var err error
+		if options.DPI, err = strconv.Atoi(dpi); err != nil {
+			return options, &service.InvalidRequestError{Message: "dpi must be a number"}
+		}
+	}
+	if cover := c.PostForm("cover"); cover != "" {
+		var err error
+		if options.Cover, err = strconv.ParseBool(cover); err != nil {
+			return options, &service.InvalidRequestError{Message: "cover must be true or false"}
+		}
+	}
+	optimize, err := parseOptimization(c)
+	if err != nil {
+		return options, err
+	}
+	options.Optimize = optimize
+	return options, nil
+}
+
+// parseOptimization parses the optional image optimization form fields.  If
+// none are present nil is returned so the configured default applies, while
+// optimize=false returns an empty Optimization which disables it
+func parseOptimization(c *gin.Context) (*model.Optimization, error) {
+
+	fields := []string{"optimize", "quality", "max_resolution", "max_dpi", "grayscale", "target_size"}
+	present := false
+	for _, field := range fields {
+		if c.PostForm(field) != "" {
+			present = true
+		}
+	}
+	if !present {
+		return nil, nil
+	}
+
+	if enabled := c.PostForm("optimize"); enabled != "" {
+		on, err := strconv.ParseBool(enabled)
+		if err != nil {
+			return nil, &service.InvalidRequestError{Message: "optimize must be true or false"}
+		}
+		if !on {
+			return &model.Optimization{}, nil
+		}
+	}
+
+	optimize := &model.Optimization{Quality: model.DefaultQuality}
+	ints := map[string]*int{
+		"quality":        &optimize.Quality,
+		"max_resolution": &optimize.MaxResolution,
+		"max_dpi":        &optimize.MaxDPI,
+	}
+	for field, value := range ints {
+		if str := c.PostForm(field); str != "" {
+			var err error
+			if *value, err = strconv.Atoi(str); err != nil {
+				return nil, &service.InvalidRequestError{Message: field + " must be a number"}
+			}
+		}
+	}
+	if str := c.PostForm("grayscale"); str != "" {
+		var err error
+		if optimize.Grayscale, err = strconv.ParseBool(str); err != nil {
+			return nil, &service.InvalidRequestError{Message: "grayscale must be true or false"}
+		}
+	}
+	if str := c.PostForm("target_size"); str != "" {
+		var err error
+		if optimize.TargetSize, err = strconv.ParseInt(str, 10, 64); err != nil {
+			return nil, &service.InvalidRequestError{Message: "target_size must be a number of bytes"}
+		}
+	}
+	return optimize, nil
+}
+
+func (a *App) cancel(c *gin.Context) {
+
+	// Parse the path params
+	id := c.Param("id")
+
+	// Cancel the capture
+	document, err := a.service.CancelDocument(id)
+	if err != nil {
+		a.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, document)
+}
+
+func (a *App) challenge(c *gin.Context) {
+
+	// Parse the path params
+	id := c.Param("id")
+	code := c.PostForm("code")
+
+	// Answer the challenge the capture is waiting on
+	document, err := a.service.AnswerChallenge(id, code)
+	if err != nil {
+		a.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, document)
+}
+
+func (a *App) recapture(c *gin.Context) {
+
+	// Parse the path params
+	id := c.Param("id")
+	passcode := c.PostForm("passcode")
+
+	// Capture a new version
+	document, err := a.service.RecaptureDocument(id, passcode)
+	if err != nil {
+		a.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, document)
+}
+
+func (a *App) versions(c *gin.Context) {
+
+	// Parse the path params
+	id := c.Param("id")
+
+	// List the versions
+	versions, err := a.service.ListVersions(id)
+	if err != nil {
+		a.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+func (a *App) watch(c *gin.Context) {
+
+	// Parse the path params
+	id := c.Param("id")
+	interval := c.PostForm("interval")
+
+	// Start watching the document
+	document, err := a.service.WatchDocument(id, interval)
+	if err != nil {
+		a.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, document)
+}
+
+func (a *App) unwatch(c *gin.Context) {
+
+	// Parse the path params
+	id := c.Param("id")
+
+	// Stop watching the document
+	document, err := a.service.UnwatchDocument(id)
+	if err != nil {
+		a.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, document)
+}
+
 func (a *App) download(c *gin.Context) {
 
 	// Parse the path params
 	id := c.Param("id")
 
+	document, reader, err := a.service.DownloadDocument(id)
+	if err != nil {
+		a.handleError(c, err)
+		return
+	}
+	a.render(c, document, id, reader)
+}
+
+func (a *App) downloadVersion(c *gin.Context) {
+
+	// Parse the path params
+	id := c.Param("id")
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		a.handleError(c, store.ErrNotFound)
+		return
+	}
+
+	document, reader, err := a.service.DownloadVersion(id, version)
+	if err != nil {
+		a.handleError(c, err)
+		return
+	}
+	a.render(c, document, fmt.Sprintf("%s.v%d", id, version), reader)
+}
+
+// render sends a downloaded document as an attachment with the supplied name
+func (a *App) render(c *gin.Context, document *model.Document, name string, reader io.Reader) {
+	format := document.Options.Format
 	extraHeaders := map[string]string{
-		"Content-Disposition": `attachment; filename="` + id + `.pdf"`,
+		"Content-Disposition": `attachment; filename="` + name + `.` + format.Extension() + `"`,
 	}
 
-	reader, err := a.service.DownloadDocument(id)
+	c.Render(http.StatusOK, Reader{
+		Headers:     extraHeaders,
+		ContentType: format.ContentType(),
+		Reader:      reader,
+	})
+}
+
+func (a *App) inspect(c *gin.Context) {
+
+	// Parse the incomming parameters
+	urlStr := c.PostForm("source_url")
+	owner := c.PostForm("owner")
+	passcode := c.PostForm("passcode")
+
+	// Open the link without capturing it
+	inspection, err := a.service.InspectLink(c.Request.Context(), urlStr, owner, passcode)
 	if err != nil {
 		a.handleError(c, err)
 		return
 	}
+	c.JSON(http.StatusOK, inspection)
+}
+
+func (a *App) listCollections(c *gin.Context) {
+
+	// Parse the query params
+	owner := c.Query("owner")
+
+	// List the collections
+	collections, err := a.service.ListCollections(owner)
+	if err != nil {
+		a.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, collections)
+}
+
+func (a *App) getCollection(c *gin.Context) {
+
+	// Parse the path params
+	id := c.Param("id")
+
+	// Get the collection
+	collection, err := a.service.GetCollection(id)
+	if err != nil {
+		a.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, collection)
+}
+
+func (a *App) generateCollection(c *gin.Context) {
+
+	// Parse the incomming parameters
+	urlStr := c.PostForm("source_url")
+	owner := c.PostForm("owner")
+	passcode := c.PostForm("passcode")
+	options, err := parseOptions(c)
+	if err != nil {
+		a.handleError(c, err)
+		return
+	}
+
+	// List the space and capture each of its documents
+	collection, err := a.service.GenerateCollection(c.Request.Context(), urlStr, owner, passcode, options)
+	if err != nil {
+		a.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, collection)
+}
+
+func (a *App) downloadCollection(c *gin.Context) {
+
+	// Parse the path and query params
+	id := c.Param("id")
+	format := model.Format(c.DefaultQuery("format", string(model.FormatZIP)))
+
+	collection, reader, err := a.service.DownloadCollection(id, format)
+	if err != nil {
+		a.handleError(c, err)
+		return
+	}
+	extraHeaders := map[string]string{
+		"Content-Disposition": `attachment; filename="` + collection.ID.Hex() + `.` + format.Extension() + `"`,
+	}
 
 	c.Render(http.StatusOK, Reader{
 		Headers:     extraHeaders,
-		ContentType: "application/pdf",
+		ContentType: format.ContentType(),
 		Reader:      reader,
 	})
 }
@@ -112,8 +413,18 @@ func (a *App) status(c *gin.Context) {
 }
 
 func (a *App) handleError(c *gin.Context, err error) {
-	if err == store.ErrNotFound {
+	if e, ok := err.(*service.InvalidRequestError); ok {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "BAD_REQUEST", "message": e.Message})
+	} else if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"code": "PAGE_NOT_FOUND", "message": "Page not found"})
+	} else if err == service.ErrNotCancellable {
+		c.JSON(http.StatusConflict, gin.H{"code": "NOT_CANCELLABLE", "message": err.Error()})
+	} else if err == service.ErrNotAwaitingInput {
+		c.JSON(http.StatusConflict, gin.H{"code": "NOT_AWAITING_INPUT", "message": err.Error()})
+	} else if err == service.ErrCaptureInProgress {
+		c.JSON(http.StatusConflict, gin.H{"code": "CAPTURE_IN_PROGRESS", "message": err.Error()})
+	} else if err == service.ErrCollectionIncomplete {
+		c.JSON(http.StatusConflict, gin.H{"code": "COLLECTION_INCOMPLETE", "message": err.Error()})
 	} else if err == store.ErrDuplicateKey {
 		c.JSON(http.StatusConflict, gin.H{"code": "CONFLICT", "message": "Document already exists"})
 	} else {
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aldelucca1/docsend_scraper/config"
+	logger "github.com/sirupsen/logrus"
+)
+
+// Command is a single subcommand of the docsend_scraper binary
+type Command struct {
+	Name      string
+	UsageLine string
+	Short     string
+	Run       func(args []string) error
+}
+
+// errUsage is returned by a Command when it was invoked incorrectly
+type errUsage string
+
+func (e errUsage) Error() string {
+	return string(e)
+}
+
+// commands is the set of available subcommands
+var commands []*Command
+
+func init() {
+	commands = []*Command{
+		serveCommand,
+		scrapeCommand,
+		listCommand,
+		downloadCommand,
+	}
+}
+
+// Execute runs the subcommand named by the first argument, returning the
+// process exit code.  With no arguments the web server is started
+func Execute(args []string) int {
+
+	if len(args) == 0 {
+		args = []string{serveCommand.Name}
+	}
+
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		usage(os.Stdout)
+		return 0
+	}
+
+	for _, cmd := range commands {
+		if cmd.Name != name {
+			continue
+		}
+		err := cmd.Run(args[1:])
+		if err == flag.ErrHelp {
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Name, err.Error())
+			if _, ok := err.(errUsage); ok {
+				fmt.Fprintf(os.Stderr, "usage: docsend_scraper %s\n", cmd.UsageLine)
+				return 2
+			}
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "docsend_scraper: unknown command %q\n", name)
+	usage(os.Stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: docsend_scraper <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "The commands are:")
+	fmt.Fprintln(w)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.Name, cmd.Short)
+	}
+}
+
+// loadConfig loads the configuration and applies its log level
+func loadConfig(flags *config.Flags) (*config.Config, error) {
+	cfg, err := config.Load(flags)
+	if err != nil {
+		return nil, err
+	}
+	level, err := logger.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		return nil, err
+	}
+	logger.SetLevel(level)
+	return cfg, nil
+}
+
+// parseFlags parses the supplied arguments allowing flags and positional
+// arguments to be interleaved, returning the positional arguments in order
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := make([]string, 0)
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := flags.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+
+		// Everything following a "--" terminator is positional
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
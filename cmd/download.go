@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aldelucca1/docsend_scraper/config"
+	"github.com/aldelucca1/docsend_scraper/service"
+)
+
+var downloadCommand = &Command{
+	Name:      "download",
+	UsageLine: "download <id> [--version n] [-o out] [--config file] [configuration flags]",
+	Short:     "download a captured document",
+	Run:       runDownload,
+}
+
+func runDownload(args []string) error {
+
+	flags := flag.NewFlagSet("download", flag.ContinueOnError)
+	configFlags := config.NewFlags(flags)
+	version := flags.Int("version", 0, "the version of the document to download (default the latest)")
+	output := flags.String("o", "", "the file to write the document to (default <id>.<format>)")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errUsage("expected a single document id")
+	}
+	id := positional[0]
+
+	cfg, err := loadConfig(configFlags)
+	if err != nil {
+		return err
+	}
+
+	svc := service.NewService(cfg)
+	if err := svc.Open(); err != nil {
+		return err
+	}
+	defer svc.Close()
+
+	doc, reader, err := svc.DownloadVersion(id, *version)
+	if err != nil {
+		return err
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	out := *output
+	if out == "" {
+		out = id + "." + doc.Options.Format.Extension()
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err = io.Copy(f, reader); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", out)
+	return nil
+}
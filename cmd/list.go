@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/config"
+	"github.com/aldelucca1/docsend_scraper/service"
+)
+
+var listCommand = &Command{
+	Name:      "list",
+	UsageLine: "list --owner email [--config file] [configuration flags]",
+	Short:     "list the captured documents for an owner",
+	Run:       runList,
+}
+
+func runList(args []string) error {
+
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	configFlags := config.NewFlags(flags)
+	owner := flags.String("owner", "", "the email address of the document owner")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 || *owner == "" {
+		return errUsage("expected an owner")
+	}
+
+	cfg, err := loadConfig(configFlags)
+	if err != nil {
+		return err
+	}
+
+	svc := service.NewService(cfg)
+	if err := svc.Open(); err != nil {
+		return err
+	}
+	defer svc.Close()
+
+	documents, err := svc.ListDocuments(*owner)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tCREATED\tSOURCE")
+	for _, doc := range documents {
+		created := time.Unix(0, doc.Created*int64(time.Millisecond)).Format(time.RFC3339)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", doc.ID.Hex(), doc.Status, created, doc.SourceURL)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/scraper"
+	"github.com/aldelucca1/docsend_scraper/scraper/cassette"
+	"github.com/aldelucca1/docsend_scraper/store/fs"
+)
+
+var scrapeCommand = &Command{
+	Name:      "scrape",
+	UsageLine: "scrape <url> [--email email] [--passcode passcode] [--format pdf|zip|pptx|cbz] [--layout native|a4|letter] [--dpi dpi] [--quality q] [--max-resolution px] [--max-dpi dpi] [--grayscale] [--target-size bytes] [--cover] [--rules file] [--record dir | --replay dir] [-o out]",
+	Short:     "capture a DocSend link to a local file",
+	Run:       runScrape,
+}
+
+func runScrape(args []string) error {
+
+	flags := flag.NewFlagSet("scrape", flag.ContinueOnError)
+	email := flags.String("email", "", "the email address to authenticate with")
+	passcode := flags.String("passcode", "", "the passcode to authenticate with")
+	format := flags.String("format", string(model.FormatPDF), "the output format, one of pdf, zip, pptx or cbz")
+	layout := flags.String("layout", string(model.LayoutNative), "the page layout, one of native, a4 or letter")
+	dpi := flags.Int("dpi", scraper.DefaultDPI, "the page image resolution for the native layout")
+	quality := flags.Int("quality", 0, "optimize page images as JPEGs of this quality, from 1 to 100")
+	maxResolution := flags.Int("max-resolution", 0, "optimize page images to at most this many pixels along their longest edge")
+	maxDPI := flags.Int("max-dpi", 0, "optimize page images to at most this resolution as placed on the page")
+	grayscale := flags.Bool("grayscale", false, "optimize page images by converting them to grayscale")
+	targetSize := flags.Int64("target-size", 0, "optimize page images, lowering their quality until the document fits this many bytes")
+	cover := flags.Bool("cover", false, "start a PDF with a cover page describing where it came from")
+	rules := flags.String("rules", "", "load the extraction rules from this file instead of using the built in rules")
+	record := flags.String("record", "", "record every request and response to a cassette in this directory")
+	replay := flags.String("replay", "", "answer every request from the cassette in this directory instead of the network")
+	output := flags.String("o", "", "the file to write the document to (default <slug>.<format>)")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errUsage("expected a single url")
+	}
+	if *record != "" && *replay != "" {
+		return errUsage("--record and --replay can't be used together")
+	}
+
+	options := model.Options{Format: model.Format(*format), Layout: model.Layout(*layout), DPI: *dpi, Cover: *cover}
+	if *quality != 0 || *maxResolution != 0 || *maxDPI != 0 || *grayscale || *targetSize != 0 {
+		options.Optimize = &model.Optimization{
+			Quality:       *quality,
+			MaxResolution: *maxResolution,
+			MaxDPI:        *maxDPI,
+			Grayscale:     *grayscale,
+			TargetSize:    *targetSize,
+		}
+		if options.Optimize.Quality == 0 {
+			options.Optimize.Quality = model.DefaultQuality
+		}
+	}
+	if err := options.Validate(); err != nil {
+		return errUsage(err.Error())
+	}
+
+	// Parse the source URL
+	u, err := url.Parse(positional[0])
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return errors.New("Invalid URL")
+	}
+
+	// Resolve the output file, defaulting to the document slug
+	out := *output
+	if out == "" {
+		out = path.Base(u.Path) + "." + options.Format.Extension()
+	}
+	out, err = filepath.Abs(out)
+	if err != nil {
+		return err
+	}
+
+	if *rules != "" {
+		r, err := scraper.LoadRules(*rules)
+		if err != nil {
+			return err
+		}
+		scraper.SetRules(r)
+	}
+
+	// Scrape directly into a filesystem store rooted at the output directory
+	objects := fs.NewStore(fs.NewConfig().WithOutputPath(filepath.Dir(out)))
+	client, err := cassetteClient(*record, *replay, *email, *passcode)
+	if err != nil {
+		return err
+	}
+	s := scraper.NewScraperWithClient(objects, client)
+	s.Options.Format = options.Format
+	s.Options.Layout = options.Layout
+	s.Options.DPI = options.DPI
+	s.Options.Optimize = options.Optimize
+	s.Options.Cover = options.Cover
+	s.StatusHandler = func(msg string) {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+	s.ProgressHandler = func(progress model.Progress) {
+		if progress.PagesTotal > 0 {
+			fmt.Fprintf(os.Stderr, "%s: %d of %d pages\n", progress.Phase, progress.PagesDone, progress.PagesTotal)
+		}
+	}
+	// Ask for verification codes on the terminal
+	stdin := bufio.NewReader(os.Stdin)
+	s.ChallengeHandler = func(challenge model.Challenge) (string, error) {
+		fmt.Fprintf(os.Stderr, "%s: ", challenge.Message)
+		answer, err := stdin.ReadString('\n')
+		if err != nil && answer == "" {
+			return "", scraper.ErrInputRequired
+		}
+		return strings.TrimSpace(answer), nil
+	}
+	if err := s.ScrapeTo(context.Background(), u, *email, *passcode, filepath.Base(out)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", out)
+	return nil
+}
+
+// cassetteClient returns the client the scrape makes its requests with,
+// recording them to or replaying them from a cassette if asked to.  The email
+// and passcode are scrubbed from a recording
+func cassetteClient(record string, replay string, email string, passcode string) (*http.Client, error) {
+	switch {
+	case record != "":
+		recorder, err := cassette.NewRecorder(record, http.DefaultTransport, email, passcode)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Client{Transport: recorder}, nil
+	case replay != "":
+		player, err := cassette.NewPlayer(replay, email, passcode)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Client{Transport: player}, nil
+	}
+	return &http.Client{}, nil
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"flag"
+
+	"github.com/aldelucca1/docsend_scraper/app"
+	"github.com/aldelucca1/docsend_scraper/config"
+	logger "github.com/sirupsen/logrus"
+)
+
+var serveCommand = &Command{
+	Name:      "serve",
+	UsageLine: "serve [--config file] [configuration flags]",
+	Short:     "start the web server and task dispatcher",
+	Run:       runServe,
+}
+
+func runServe(args []string) error {
+
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configFlags := config.NewFlags(flags)
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errUsage("unexpected arguments")
+	}
+
+	cfg, err := loadConfig(configFlags)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Effective configuration:\n%s", cfg)
+
+	app := app.NewApp(cfg)
+	app.Run()
+	return nil
+}
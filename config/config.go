@@ -0,0 +1,346 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	logger "github.com/sirupsen/logrus"
+)
+
+// redacted - The placeholder logged in place of a secret value
+const redacted = "********"
+
+// Config is the complete application configuration
+type Config struct {
+	Server      ServerConfig      `yaml:"server"`
+	Log         LogConfig         `yaml:"log"`
+	Dispatcher  DispatcherConfig  `yaml:"dispatcher"`
+	Queue       QueueConfig       `yaml:"queue"`
+	Retry       RetryConfig       `yaml:"retry"`
+	Scraper     ScraperConfig     `yaml:"scraper"`
+	Watch       WatchConfig       `yaml:"watch"`
+	Datastore   DatastoreConfig   `yaml:"datastore"`
+	ObjectStore ObjectStoreConfig `yaml:"objectstore"`
+}
+
+// ServerConfig configures the HTTP server
+type ServerConfig struct {
+	Address string `yaml:"address"`
+}
+
+// LogConfig configures logging
+type LogConfig struct {
+	Level string `yaml:"level"`
+}
+
+// DispatcherConfig configures the task dispatcher
+type DispatcherConfig struct {
+	Workers int `yaml:"workers"`
+}
+
+// QueueConfig configures how queued captures are persisted and leased
+type QueueConfig struct {
+	InstanceID string        `yaml:"instance_id"`
+	SecretKey  string        `yaml:"secret_key"`
+	LeaseTTL   time.Duration `yaml:"lease_ttl"`
+}
+
+// RetryConfig configures how failed captures are retried
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Multiplier     float64       `yaml:"multiplier"`
+	Jitter         float64       `yaml:"jitter"`
+}
+
+// ScraperConfig configures how documents are downloaded
+type ScraperConfig struct {
+	Concurrency        int    `yaml:"concurrency"`
+	MaxHostConnections int    `yaml:"max_host_connections"`
+	Format             string `yaml:"format"`
+	Layout             string `yaml:"layout"`
+	DPI                int    `yaml:"dpi"`
+
+	// The default optimization of page images
+	Optimize OptimizeConfig `yaml:"optimize"`
+
+	// The file the extraction rules are loaded from, the built in rules are
+	// used if it isn't set, and how often it's checked for changes
+	RulesFile           string        `yaml:"rules_file"`
+	RulesReloadInterval time.Duration `yaml:"rules_reload_interval"`
+
+	// How long a capture waits for the user to answer a challenge, such as
+	// entering a verification code
+	InputTimeout time.Duration `yaml:"input_timeout"`
+}
+
+// OptimizeConfig configures the default optimization of page images
+type OptimizeConfig struct {
+	Enabled       bool  `yaml:"enabled"`
+	Quality       int   `yaml:"quality"`
+	MaxResolution int   `yaml:"max_resolution"`
+	MaxDPI        int   `yaml:"max_dpi"`
+	Grayscale     bool  `yaml:"grayscale"`
+	TargetSize    int64 `yaml:"target_size"`
+}
+
+// Options returns the default per document capture options
+func (c ScraperConfig) Options() model.Options {
+	options := model.Options{
+		Format: model.Format(c.Format),
+		Layout: model.Layout(c.Layout),
+		DPI:    c.DPI,
+	}
+	if c.Optimize.Enabled {
+		options.Optimize = &model.Optimization{
+			Quality:       c.Optimize.Quality,
+			MaxResolution: c.Optimize.MaxResolution,
+			MaxDPI:        c.Optimize.MaxDPI,
+			Grayscale:     c.Optimize.Grayscale,
+			TargetSize:    c.Optimize.TargetSize,
+		}
+	}
+	return options
+}
+
+// WatchConfig configures the periodic re-capture of watched documents
+type WatchConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval"`
+	MinInterval  time.Duration `yaml:"min_interval"`
+}
+
+// DatastoreConfig selects and configures the Datastore backend
+type DatastoreConfig struct {
+	Type  string      `yaml:"type"`
+	Mongo MongoConfig `yaml:"mongo"`
+	Bolt  BoltConfig  `yaml:"bolt"`
+}
+
+// MongoConfig configures the MongoDB Datastore
+type MongoConfig struct {
+	Endpoints  []string `yaml:"endpoints"`
+	ReplicaSet string   `yaml:"replica_set"`
+	Database   string   `yaml:"database"`
+	Username   string   `yaml:"username"`
+	Password   string   `yaml:"password"`
+}
+
+// BoltConfig configures the BoltDB Datastore
+type BoltConfig struct {
+	Path string `yaml:"path"`
+}
+
+// ObjectStoreConfig selects and configures the ObjectStore backend
+type ObjectStoreConfig struct {
+	Type string   `yaml:"type"`
+	FS   FSConfig `yaml:"fs"`
+	S3   S3Config `yaml:"s3"`
+}
+
+// FSConfig configures the filesystem ObjectStore
+type FSConfig struct {
+	OutputPath string `yaml:"output_path"`
+}
+
+// S3Config configures the S3 ObjectStore
+type S3Config struct {
+	Endpoint   string `yaml:"endpoint"`
+	Region     string `yaml:"region"`
+	DisableSSL bool   `yaml:"disable_ssl"`
+	Bucket     string `yaml:"bucket"`
+	Prefix     string `yaml:"prefix"`
+	PartSize   int64  `yaml:"part_size"`
+	AccessKey  string `yaml:"access_key"`
+	SecretKey  string `yaml:"secret_key"`
+}
+
+// NewConfig creates a new Config with the default values
+func NewConfig() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Address: ":8080",
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Dispatcher: DispatcherConfig{
+			Workers: 10,
+		},
+		Queue: QueueConfig{
+			InstanceID: instanceID(),
+			LeaseTTL:   2 * time.Minute,
+		},
+		Retry: RetryConfig{
+			MaxAttempts:    3,
+			InitialBackoff: 5 * time.Second,
+			MaxBackoff:     2 * time.Minute,
+			Multiplier:     2,
+			Jitter:         0.2,
+		},
+		Scraper: ScraperConfig{
+			Concurrency:        4,
+			MaxHostConnections: 8,
+			Format:             "pdf",
+			Layout:             "native",
+			DPI:                72,
+			Optimize: OptimizeConfig{
+				Quality: model.DefaultQuality,
+			},
+			RulesReloadInterval: 30 * time.Second,
+			InputTimeout:        15 * time.Minute,
+		},
+		Watch: WatchConfig{
+			PollInterval: time.Minute,
+			MinInterval:  time.Hour,
+		},
+		Datastore: DatastoreConfig{
+			Type: "mongo",
+			Mongo: MongoConfig{
+				Endpoints: []string{"localhost"},
+				Database:  "docsend",
+				Username:  "docsend",
+			},
+			Bolt: BoltConfig{
+				Path: "docsend.db",
+			},
+		},
+		ObjectStore: ObjectStoreConfig{
+			Type: "fs",
+			FS: FSConfig{
+				OutputPath: "/tmp/",
+			},
+			S3: S3Config{
+				Endpoint: "s3.amazonaws.com",
+				PartSize: 5 * 1024 * 1024,
+			},
+		},
+	}
+}
+
+// Validate checks the configuration is complete and consistent
+func (c *Config) Validate() error {
+
+	if c.Server.Address == "" {
+		return fmt.Errorf("server.address must be set")
+	}
+	if _, err := logger.ParseLevel(c.Log.Level); err != nil {
+		return fmt.Errorf("log.level: %s", err.Error())
+	}
+	if c.Dispatcher.Workers < 1 {
+		return fmt.Errorf("dispatcher.workers must be at least 1, got %d", c.Dispatcher.Workers)
+	}
+
+	if c.Queue.InstanceID == "" {
+		return fmt.Errorf("queue.instance_id must be set")
+	}
+	if c.Queue.LeaseTTL < 3*time.Second {
+		return fmt.Errorf("queue.lease_ttl must be at least 3s, got %s", c.Queue.LeaseTTL)
+	}
+
+	if c.Retry.MaxAttempts < 1 {
+		return fmt.Errorf("retry.max_attempts must be at least 1, got %d", c.Retry.MaxAttempts)
+	}
+	if c.Retry.InitialBackoff < 0 || c.Retry.MaxBackoff < c.Retry.InitialBackoff {
+		return fmt.Errorf("retry.max_backoff must be at least retry.initial_backoff")
+	}
+	if c.Retry.Multiplier < 1 {
+		return fmt.Errorf("retry.multiplier must be at least 1, got %g", c.Retry.Multiplier)
+	}
+	if c.Retry.Jitter < 0 || c.Retry.Jitter > 1 {
+		return fmt.Errorf("retry.jitter must be between 0 and 1, got %g", c.Retry.Jitter)
+	}
+
+	if c.Scraper.Concurrency < 1 {
+		return fmt.Errorf("scraper.concurrency must be at least 1, got %d", c.Scraper.Concurrency)
+	}
+	if c.Scraper.MaxHostConnections < 1 {
+		return fmt.Errorf("scraper.max_host_connections must be at least 1, got %d", c.Scraper.MaxHostConnections)
+	}
+	if err := c.Scraper.Options().Validate(); err != nil {
+		return fmt.Errorf("scraper: %s", err.Error())
+	}
+	if c.Scraper.RulesFile != "" && c.Scraper.RulesReloadInterval < time.Second {
+		return fmt.Errorf("scraper.rules_reload_interval must be at least 1s, got %s", c.Scraper.RulesReloadInterval)
+	}
+	if c.Scraper.InputTimeout < time.Second {
+		return fmt.Errorf("scraper.input_timeout must be at least 1s, got %s", c.Scraper.InputTimeout)
+	}
+
+	if c.Watch.PollInterval < time.Second {
+		return fmt.Errorf("watch.poll_interval must be at least 1s, got %s", c.Watch.PollInterval)
+	}
+	if c.Watch.MinInterval < c.Watch.PollInterval {
+		return fmt.Errorf("watch.min_interval must be at least watch.poll_interval")
+	}
+
+	switch c.Datastore.Type {
+	case "mongo":
+		if len(c.Datastore.Mongo.Endpoints) == 0 {
+			return fmt.Errorf("datastore.mongo.endpoints must be set")
+		}
+		if c.Datastore.Mongo.Database == "" {
+			return fmt.Errorf("datastore.mongo.database must be set")
+		}
+	case "bolt":
+		if c.Datastore.Bolt.Path == "" {
+			return fmt.Errorf("datastore.bolt.path must be set")
+		}
+	case "memory":
+	default:
+		return fmt.Errorf("datastore.type must be one of mongo, bolt or memory, got %q", c.Datastore.Type)
+	}
+
+	switch c.ObjectStore.Type {
+	case "fs":
+		if c.ObjectStore.FS.OutputPath == "" {
+			return fmt.Errorf("objectstore.fs.output_path must be set")
+		}
+	case "s3":
+		if c.ObjectStore.S3.Endpoint == "" {
+			return fmt.Errorf("objectstore.s3.endpoint must be set")
+		}
+		if c.ObjectStore.S3.Bucket == "" {
+			return fmt.Errorf("objectstore.s3.bucket must be set")
+		}
+	case "memory":
+	default:
+		return fmt.Errorf("objectstore.type must be one of fs, s3 or memory, got %q", c.ObjectStore.Type)
+	}
+
+	return nil
+}
+
+// Redacted returns a copy of the configuration with any secrets replaced, safe
+// for logging
+func (c *Config) Redacted() *Config {
+	r := *c
+	r.Datastore.Mongo.Endpoints = append([]string(nil), c.Datastore.Mongo.Endpoints...)
+	if r.Datastore.Mongo.Password != "" {
+		r.Datastore.Mongo.Password = redacted
+	}
+	if r.ObjectStore.S3.SecretKey != "" {
+		r.ObjectStore.S3.SecretKey = redacted
+	}
+	if r.Queue.SecretKey != "" {
+		r.Queue.SecretKey = redacted
+	}
+	return &r
+}
+
+// instanceID - The default instance id, unique to the process so no two
+// processes on a host share leases, even the server and a command run
+// alongside it
+func instanceID() string {
+	name, err := os.Hostname()
+	if err != nil {
+		name = "localhost"
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", name, os.Getpid(), hex.EncodeToString(suffix))
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		err    string
+	}{
+		{"defaults", func(c *Config) {}, ""},
+		{"address", func(c *Config) { c.Server.Address = "" }, "server.address"},
+		{"log level", func(c *Config) { c.Log.Level = "loud" }, "log.level"},
+		{"workers", func(c *Config) { c.Dispatcher.Workers = 0 }, "dispatcher.workers"},
+		{"instance id", func(c *Config) { c.Queue.InstanceID = "" }, "queue.instance_id"},
+		{"lease ttl", func(c *Config) { c.Queue.LeaseTTL = time.Second }, "queue.lease_ttl"},
+		{"max attempts", func(c *Config) { c.Retry.MaxAttempts = 0 }, "retry.max_attempts"},
+		{"backoff", func(c *Config) { c.Retry.MaxBackoff = time.Second }, "retry.max_backoff"},
+		{"multiplier", func(c *Config) { c.Retry.Multiplier = 0.5 }, "retry.multiplier"},
+		{"jitter", func(c *Config) { c.Retry.Jitter = 1.5 }, "retry.jitter"},
+		{"concurrency", func(c *Config) { c.Scraper.Concurrency = 0 }, "scraper.concurrency"},
+		{"format", func(c *Config) { c.Scraper.Format = "doc" }, "scraper"},
+		{"rules reload", func(c *Config) {
+			c.Scraper.RulesFile = "rules.yaml"
+			c.Scraper.RulesReloadInterval = 0
+		}, "scraper.rules_reload_interval"},
+		{"rules reload unused", func(c *Config) { c.Scraper.RulesReloadInterval = 0 }, ""},
+		{"input timeout", func(c *Config) { c.Scraper.InputTimeout = 0 }, "scraper.input_timeout"},
+		{"watch interval", func(c *Config) { c.Watch.MinInterval = time.Second }, "watch.min_interval"},
+		{"datastore", func(c *Config) { c.Datastore.Type = "postgres" }, "datastore.type"},
+		{"mongo endpoints", func(c *Config) { c.Datastore.Mongo.Endpoints = nil }, "datastore.mongo.endpoints"},
+		{"bolt path", func(c *Config) {
+			c.Datastore.Type = "bolt"
+			c.Datastore.Bolt.Path = ""
+		}, "datastore.bolt.path"},
+		{"objectstore", func(c *Config) { c.ObjectStore.Type = "ftp" }, "objectstore.type"},
+		{"s3 bucket", func(c *Config) { c.ObjectStore.Type = "s3" }, "objectstore.s3.bucket"},
+		{"memory", func(c *Config) {
+			c.Datastore.Type = "memory"
+			c.ObjectStore.Type = "memory"
+		}, ""},
+	}
+	for _, tt := range tests {
+		c := NewConfig()
+		tt.modify(c)
+		err := c.Validate()
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: expected the configuration to be valid, got %s", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected an error about %s, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	c := NewConfig()
+	c.Queue.SecretKey = "queue-secret"
+	c.Datastore.Mongo.Password = "mongo-secret"
+	c.ObjectStore.S3.SecretKey = "s3-secret"
+
+	s := c.String()
+	for _, secret := range []string{"queue-secret", "mongo-secret", "s3-secret"} {
+		if strings.Contains(s, secret) {
+			t.Errorf("expected %s to be redacted from:\n%s", secret, s)
+		}
+	}
+	if c.Queue.SecretKey != "queue-secret" {
+		t.Error("expected redacting not to change the configuration")
+	}
+}
+
+func TestDefaultInstanceID(t *testing.T) {
+	name, err := os.Hostname()
+	if err != nil {
+		name = "localhost"
+	}
+	prefix := fmt.Sprintf("%s-%d-", name, os.Getpid())
+
+	first, second := NewConfig().Queue.InstanceID, NewConfig().Queue.InstanceID
+	for _, id := range []string{first, second} {
+		if !strings.HasPrefix(id, prefix) || len(id) == len(prefix) {
+			t.Errorf("expected an instance id starting %s and a random suffix, got %s", prefix, id)
+		}
+	}
+	if first == second {
+		t.Errorf("expected the default instance ids to differ, both were %s", first)
+	}
+}
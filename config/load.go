@@ -0,0 +1,331 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// setting maps a single configuration value to its environment variable and
+// command line flag
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	apply func(c *Config, value string) error
+}
+
+// settings is the set of values that can be supplied through the environment
+// or on the command line
+var settings = []setting{
+	{"LISTEN_ADDR", "listen", "the address to listen for HTTP on", func(c *Config, v string) error {
+		c.Server.Address = v
+		return nil
+	}},
+	{"LOG_LEVEL", "log-level", "the minimum level to log at", func(c *Config, v string) error {
+		c.Log.Level = v
+		return nil
+	}},
+	{"DISPATCHER_WORKERS", "workers", "the number of concurrent capture workers", func(c *Config, v string) error {
+		return parseInt(v, &c.Dispatcher.Workers)
+	}},
+	{"QUEUE_INSTANCE_ID", "instance-id", "the id this instance holds capture leases as", func(c *Config, v string) error {
+		c.Queue.InstanceID = v
+		return nil
+	}},
+	{"QUEUE_SECRET_KEY", "", "", func(c *Config, v string) error {
+		c.Queue.SecretKey = v
+		return nil
+	}},
+	{"QUEUE_LEASE_TTL", "lease-ttl", "how long a capture lease lasts without renewal", func(c *Config, v string) error {
+		return parseDuration(v, &c.Queue.LeaseTTL)
+	}},
+	{"RETRY_MAX_ATTEMPTS", "retry-max-attempts", "the maximum number of attempts at a capture", func(c *Config, v string) error {
+		return parseInt(v, &c.Retry.MaxAttempts)
+	}},
+	{"RETRY_INITIAL_BACKOFF", "retry-initial-backoff", "the delay before the first retry", func(c *Config, v string) error {
+		return parseDuration(v, &c.Retry.InitialBackoff)
+	}},
+	{"RETRY_MAX_BACKOFF", "retry-max-backoff", "the maximum delay between retries", func(c *Config, v string) error {
+		return parseDuration(v, &c.Retry.MaxBackoff)
+	}},
+	{"RETRY_MULTIPLIER", "retry-multiplier", "the factor the delay between retries grows by", func(c *Config, v string) error {
+		return parseFloat(v, &c.Retry.Multiplier)
+	}},
+	{"RETRY_JITTER", "retry-jitter", "the fraction of the delay between retries randomly added or removed", func(c *Config, v string) error {
+		return parseFloat(v, &c.Retry.Jitter)
+	}},
+	{"SCRAPER_CONCURRENCY", "concurrency", "the number of pages fetched at once per capture", func(c *Config, v string) error {
+		return parseInt(v, &c.Scraper.Concurrency)
+	}},
+	{"SCRAPER_MAX_HOST_CONNECTIONS", "max-host-connections", "the cap on concurrent connections to a single host", func(c *Config, v string) error {
+		return parseInt(v, &c.Scraper.MaxHostConnections)
+	}},
+	{"SCRAPER_FORMAT", "format", "the default output format, one of pdf, zip, pptx or cbz", func(c *Config, v string) error {
+		c.Scraper.Format = v
+		return nil
+	}},
+	{"SCRAPER_LAYOUT", "layout", "the default page layout, one of native, a4 or letter", func(c *Config, v string) error {
+		c.Scraper.Layout = v
+		return nil
+	}},
+	{"SCRAPER_DPI", "dpi", "the default page image resolution for the native layout", func(c *Config, v string) error {
+		return parseInt(v, &c.Scraper.DPI)
+	}},
+	{"SCRAPER_OPTIMIZE", "optimize", "whether page images are optimized by default", func(c *Config, v string) error {
+		return parseBool(v, &c.Scraper.Optimize.Enabled)
+	}},
+	{"SCRAPER_OPTIMIZE_QUALITY", "optimize-quality", "the JPEG quality optimized images are encoded at", func(c *Config, v string) error {
+		return parseInt(v, &c.Scraper.Optimize.Quality)
+	}},
+	{"SCRAPER_OPTIMIZE_MAX_RESOLUTION", "optimize-max-resolution", "the longest edge of an optimized image in pixels", func(c *Config, v string) error {
+		return parseInt(v, &c.Scraper.Optimize.MaxResolution)
+	}},
+	{"SCRAPER_OPTIMIZE_MAX_DPI", "optimize-max-dpi", "the resolution of an optimized image as placed on the page", func(c *Config, v string) error {
+		return parseInt(v, &c.Scraper.Optimize.MaxDPI)
+	}},
+	{"SCRAPER_OPTIMIZE_GRAYSCALE", "optimize-grayscale", "whether optimized images are converted to grayscale", func(c *Config, v string) error {
+		return parseBool(v, &c.Scraper.Optimize.Grayscale)
+	}},
+	{"SCRAPER_OPTIMIZE_TARGET_SIZE", "optimize-target-size", "the size in bytes optimized documents should fit within", func(c *Config, v string) error {
+		return parseInt64(v, &c.Scraper.Optimize.TargetSize)
+	}},
+	{"SCRAPER_RULES_FILE", "rules-file", "the file extraction rules are loaded from", func(c *Config, v string) error {
+		c.Scraper.RulesFile = v
+		return nil
+	}},
+	{"SCRAPER_RULES_RELOAD_INTERVAL", "rules-reload-interval", "how often the rules file is checked for changes", func(c *Config, v string) error {
+		return parseDuration(v, &c.Scraper.RulesReloadInterval)
+	}},
+	{"SCRAPER_INPUT_TIMEOUT", "input-timeout", "how long a capture waits for the user to enter a verification code", func(c *Config, v string) error {
+		return parseDuration(v, &c.Scraper.InputTimeout)
+	}},
+	{"WATCH_POLL_INTERVAL", "watch-poll-interval", "how often watched documents are checked for being due", func(c *Config, v string) error {
+		return parseDuration(v, &c.Watch.PollInterval)
+	}},
+	{"WATCH_MIN_INTERVAL", "watch-min-interval", "the shortest interval a document can be watched at", func(c *Config, v string) error {
+		return parseDuration(v, &c.Watch.MinInterval)
+	}},
+	{"DATASTORE", "datastore", "the datastore backend, one of mongo, bolt or memory", func(c *Config, v string) error {
+		c.Datastore.Type = v
+		return nil
+	}},
+	{"MONGO_HOST", "mongo-host", "comma separated mongodb endpoints", func(c *Config, v string) error {
+		c.Datastore.Mongo.Endpoints = parseList(v)
+		return nil
+	}},
+	{"MONGO_REPLICA_SET", "mongo-replica-set", "the mongodb replica set", func(c *Config, v string) error {
+		c.Datastore.Mongo.ReplicaSet = v
+		return nil
+	}},
+	{"MONGO_DB", "mongo-db", "the mongodb database", func(c *Config, v string) error {
+		c.Datastore.Mongo.Database = v
+		return nil
+	}},
+	{"MONGO_USER", "mongo-user", "the mongodb username", func(c *Config, v string) error {
+		c.Datastore.Mongo.Username = v
+		return nil
+	}},
+	{"MONGO_PWD", "", "", func(c *Config, v string) error {
+		c.Datastore.Mongo.Password = v
+		return nil
+	}},
+	{"BOLT_PATH", "bolt-path", "the boltdb database file", func(c *Config, v string) error {
+		c.Datastore.Bolt.Path = v
+		return nil
+	}},
+	{"OBJECTSTORE", "objectstore", "the object store backend, one of fs, s3 or memory", func(c *Config, v string) error {
+		c.ObjectStore.Type = v
+		return nil
+	}},
+	{"FS_OUTPUT_PATH", "output-path", "the directory the fs object store writes to", func(c *Config, v string) error {
+		c.ObjectStore.FS.OutputPath = v
+		return nil
+	}},
+	{"S3_ENDPOINT", "s3-endpoint", "the s3 host and optional port", func(c *Config, v string) error {
+		c.ObjectStore.S3.Endpoint = v
+		return nil
+	}},
+	{"S3_REGION", "s3-region", "the s3 bucket region", func(c *Config, v string) error {
+		c.ObjectStore.S3.Region = v
+		return nil
+	}},
+	{"S3_DISABLE_SSL", "s3-disable-ssl", "connect to s3 over plain HTTP", func(c *Config, v string) error {
+		return parseBool(v, &c.ObjectStore.S3.DisableSSL)
+	}},
+	{"S3_BUCKET", "s3-bucket", "the s3 bucket", func(c *Config, v string) error {
+		c.ObjectStore.S3.Bucket = v
+		return nil
+	}},
+	{"S3_PREFIX", "s3-prefix", "the s3 key prefix", func(c *Config, v string) error {
+		c.ObjectStore.S3.Prefix = v
+		return nil
+	}},
+	{"S3_PART_SIZE", "s3-part-size", "the s3 multipart upload part size in bytes", func(c *Config, v string) error {
+		return parseInt64(v, &c.ObjectStore.S3.PartSize)
+	}},
+	{"S3_ACCESS_KEY", "s3-access-key", "the s3 access key", func(c *Config, v string) error {
+		c.ObjectStore.S3.AccessKey = v
+		return nil
+	}},
+	{"S3_SECRET_KEY", "", "", func(c *Config, v string) error {
+		c.ObjectStore.S3.SecretKey = v
+		return nil
+	}},
+}
+
+// Flags holds the configuration values supplied on the command line
+type Flags struct {
+	file      string
+	overrides []func(c *Config) error
+}
+
+// flagValue is a flag.Value that records an override when set
+type flagValue struct {
+	flags   *Flags
+	setting setting
+	value   string
+}
+
+func (v *flagValue) String() string {
+	return v.value
+}
+
+func (v *flagValue) Set(value string) error {
+	// Check the value parses now so the error is reported against the flag
+	if err := v.setting.apply(NewConfig(), value); err != nil {
+		return err
+	}
+	v.value = value
+	v.flags.overrides = append(v.flags.overrides, func(c *Config) error {
+		return v.setting.apply(c, value)
+	})
+	return nil
+}
+
+// NewFlags registers the configuration flags with the supplied FlagSet.
+// Secrets can only be supplied through the file or environment so they never
+// show up in the process list
+func NewFlags(flags *flag.FlagSet) *Flags {
+	f := new(Flags)
+	flags.StringVar(&f.file, "config", os.Getenv("DOCSEND_CONFIG"), "the YAML configuration file to load")
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		flags.Var(&flagValue{flags: f, setting: s}, s.flag, s.usage+" (env "+s.env+")")
+	}
+	return f
+}
+
+// Load builds the configuration from, in increasing order of precedence, the
+// defaults, the configuration file, the environment and the command line.
+// The result is validated before being returned
+func Load(flags *Flags) (*Config, error) {
+
+	c := NewConfig()
+
+	// Apply the configuration file
+	if flags != nil && flags.file != "" {
+		data, err := ioutil.ReadFile(flags.file)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict(data, c); err != nil {
+			return nil, fmt.Errorf("%s: %s", flags.file, err.Error())
+		}
+	}
+
+	// Apply the environment
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(s.env); ok && v != "" {
+			if err := s.apply(c, v); err != nil {
+				return nil, fmt.Errorf("%s: %s", s.env, err.Error())
+			}
+		}
+	}
+
+	// Apply the command line
+	if flags != nil {
+		for _, override := range flags.overrides {
+			if err := override(c); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// String renders the configuration as YAML with any secrets redacted
+func (c *Config) String() string {
+	data, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func parseList(v string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func parseInt(v string, out *int) error {
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	*out = i
+	return nil
+}
+
+func parseInt64(v string, out *int64) error {
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return err
+	}
+	*out = i
+	return nil
+}
+
+func parseFloat(v string, out *float64) error {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return err
+	}
+	*out = f
+	return nil
+}
+
+func parseDuration(v string, out *time.Duration) error {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	*out = d
+	return nil
+}
+
+func parseBool(v string, out *bool) error {
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return err
+	}
+	*out = b
+	return nil
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes the YAML configuration to a file in a new directory,
+// returning its path and a function removing it
+func writeConfig(t *testing.T, yaml string) (string, func()) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(yaml), 0600); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+// setenv sets the environment variables, returning a function restoring them
+func setenv(vars map[string]string) func() {
+	previous := make(map[string]*string)
+	for k, v := range vars {
+		if old, ok := os.LookupEnv(k); ok {
+			previous[k] = &old
+		} else {
+			previous[k] = nil
+		}
+		os.Setenv(k, v)
+	}
+	return func() {
+		for k, v := range previous {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+// parse registers the configuration flags and parses the arguments
+func parse(t *testing.T, args ...string) *Flags {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	f := NewFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path, remove := writeConfig(t, `
+dispatcher:
+  workers: 3
+retry:
+  max_attempts: 5
+  multiplier: 3
+  jitter: 0.1
+scraper:
+  concurrency: 2
+`)
+	defer remove()
+	defer setenv(map[string]string{
+		"RETRY_MAX_ATTEMPTS": "6",
+		"RETRY_MULTIPLIER":   "4",
+		"RETRY_JITTER":       "0.3",
+		"SCRAPER_DPI":        "96",
+	})()
+
+	c, err := Load(parse(t, "--config", path, "--retry-jitter", "0.5", "--dpi", "150"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{"default", c.Retry.InitialBackoff, 5 * time.Second},
+		{"file", c.Dispatcher.Workers, 3},
+		{"file", c.Scraper.Concurrency, 2},
+		{"environment over file", c.Retry.MaxAttempts, 6},
+		{"environment over file", c.Retry.Multiplier, 4.0},
+		{"flag over environment and file", c.Retry.Jitter, 0.5},
+		{"flag over environment", c.Scraper.DPI, 150},
+	}
+	for _, tt := range tests {
+		if tt.value != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, tt.value)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	path, remove := writeConfig(t, "retry:\n  multiplyer: 2\n")
+	defer remove()
+	if _, err := Load(parse(t, "--config", path)); err == nil || !strings.Contains(err.Error(), "multiplyer") {
+		t.Errorf("expected an unknown field in the file to be rejected, got %v", err)
+	}
+
+	restore := setenv(map[string]string{"RETRY_MULTIPLIER": "twice"})
+	_, err := Load(parse(t))
+	restore()
+	if err == nil || !strings.Contains(err.Error(), "RETRY_MULTIPLIER") {
+		t.Errorf("expected an invalid environment variable to be reported, got %v", err)
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	NewFlags(flags)
+	if err := flags.Parse([]string{"--retry-jitter", "some"}); err == nil {
+		t.Error("expected an invalid flag to be rejected")
+	}
+
+	if _, err := Load(parse(t, "--retry-jitter", "2")); err == nil || !strings.Contains(err.Error(), "retry.jitter") {
+		t.Errorf("expected the loaded configuration to be validated, got %v", err)
+	}
+}
+
+func TestSecretsHaveNoFlags(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	NewFlags(flags)
+	flags.VisitAll(func(f *flag.Flag) {
+		if strings.Contains(f.Name, "secret") || strings.Contains(f.Name, "password") || strings.Contains(f.Name, "pwd") {
+			t.Errorf("expected no flag for the secret %s", f.Name)
+		}
+	})
+}
@@ -1,8 +1,11 @@
 package main
 
-import "github.com/aldelucca1/docsend_scraper/app"
+import (
+	"os"
+
+	"github.com/aldelucca1/docsend_scraper/cmd"
+)
 
 func main() {
-	app := app.NewApp()
-	app.Run()
+	os.Exit(cmd.Execute(os.Args[1:]))
 }
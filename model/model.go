@@ -1,6 +1,12 @@
 package model
 
-import "github.com/globalsign/mgo/bson"
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
 
 type Status int
 
@@ -9,13 +15,412 @@ const (
 	StatusCapturing Status = iota
 	StatusComplete  Status = iota
 	StatusError     Status = iota
+	StatusCancelled Status = iota
+
+	// The capture is waiting on input from the user, such as a verification
+	// code emailed to them, before it can continue
+	StatusAwaitingInput Status = iota
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusPending:
+		return "pending"
+	case StatusCapturing:
+		return "capturing"
+	case StatusComplete:
+		return "complete"
+	case StatusError:
+		return "error"
+	case StatusCancelled:
+		return "cancelled"
+	case StatusAwaitingInput:
+		return "awaiting_input"
+	}
+	return "unknown"
+}
+
+// Phase is a stage of capturing a document
+type Phase string
+
+const (
+	PhaseAuthenticating    Phase = "authenticating"
+	PhaseFetchingMetadata  Phase = "fetching_metadata"
+	PhaseDownloadingImages Phase = "downloading_images"
+	PhaseOptimizing        Phase = "optimizing"
+	PhaseGenerating        Phase = "generating"
+	PhaseUploading         Phase = "uploading"
 )
 
+// Gate is a step DocSend puts in front of a document before showing it
+type Gate string
+
+const (
+	GateNone         Gate = "none"
+	GateEmail        Gate = "email"
+	GatePasscode     Gate = "passcode"
+	GateNDA          Gate = "nda"
+	GateVerification Gate = "verification"
+)
+
+// Challenge is the input a capture is waiting on from the user.  Attempt
+// counts the answers already rejected
+type Challenge struct {
+	Gate    Gate   `json:"gate"`
+	Message string `json:"message"`
+	Attempt int    `json:"attempt"`
+	Created int64  `json:"created"`
+}
+
+// ChallengeEvent is the message pushed to clients when a capture is waiting
+// on their input
+type ChallengeEvent struct {
+	ID        string    `json:"id"`
+	Challenge Challenge `json:"challenge"`
+}
+
+// Progress is the current progress of a capture.  Pages count the pages
+// completed within the phase and Bytes the bytes downloaded or uploaded
+type Progress struct {
+	Phase      Phase `json:"phase"`
+	PagesDone  int   `json:"pages_done" bson:"pages_done"`
+	PagesTotal int   `json:"pages_total" bson:"pages_total"`
+	Bytes      int64 `json:"bytes"`
+}
+
+// ProgressUpdate is the message pushed to clients when a capture progresses
+type ProgressUpdate struct {
+	ID       string   `json:"id"`
+	Progress Progress `json:"progress"`
+}
+
+// ChangeEvent is the message pushed to clients when a new version of a
+// document differs from the previous one, or its link is revoked
+type ChangeEvent struct {
+	ID      string   `json:"id"`
+	Version int      `json:"version,omitempty"`
+	Changes *Changes `json:"changes,omitempty"`
+	Message string   `json:"message,omitempty"`
+}
+
+// Layout is how page images are placed on the pages of a generated document
+type Layout string
+
+const (
+	// LayoutNative sizes each page to its image
+	LayoutNative Layout = "native"
+
+	// LayoutA4 fits each image on an A4 page, letterboxing it to keep its
+	// aspect ratio
+	LayoutA4 Layout = "a4"
+
+	// LayoutLetter fits each image on a US Letter page, letterboxing it to
+	// keep its aspect ratio
+	LayoutLetter Layout = "letter"
+)
+
+// ParseLayout parses the name of a Layout
+func ParseLayout(s string) (Layout, error) {
+	switch l := Layout(s); l {
+	case LayoutNative, LayoutA4, LayoutLetter:
+		return l, nil
+	}
+	return "", fmt.Errorf("unknown layout %q, expected native, a4 or letter", s)
+}
+
+// Format is the file format of a generated document
+type Format string
+
+const (
+	// FormatPDF is a PDF with one page per slide
+	FormatPDF Format = "pdf"
+
+	// FormatZIP is a ZIP of the numbered slide images and a JSON manifest of
+	// their links
+	FormatZIP Format = "zip"
+
+	// FormatPPTX is a PowerPoint presentation with one image slide per page
+	FormatPPTX Format = "pptx"
+
+	// FormatCBZ is a comic book archive of the numbered slide images
+	FormatCBZ Format = "cbz"
+)
+
+// ParseFormat parses the name of a Format
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatPDF, FormatZIP, FormatPPTX, FormatCBZ:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected pdf, zip, pptx or cbz", s)
+}
+
+// Extension returns the file extension of the Format.  Documents captured
+// before formats were introduced have no Format and are PDFs
+func (f Format) Extension() string {
+	if f == "" {
+		return string(FormatPDF)
+	}
+	return string(f)
+}
+
+// ContentType returns the MIME type of the Format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatZIP:
+		return "application/zip"
+	case FormatPPTX:
+		return "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	case FormatCBZ:
+		return "application/vnd.comicbook+zip"
+	}
+	return "application/pdf"
+}
+
+// Options are the per document capture options
+type Options struct {
+	Format Format `json:"format"`
+	Layout Layout `json:"layout"`
+
+	// The resolution of the page images for the native layout, in dots per
+	// inch
+	DPI int `json:"dpi"`
+
+	// How page images are optimized before the document is generated, nil
+	// if they're embedded as downloaded
+	Optimize *Optimization `json:"optimize,omitempty" bson:"optimize,omitempty"`
+
+	// Whether a PDF starts with a cover page describing where it came from
+	Cover bool `json:"cover,omitempty"`
+}
+
+// MaxDPI is the highest supported page image resolution
+const MaxDPI = 1200
+
+// Optimization configures how page images are shrunk before the document is
+// generated.  Optimized images are re-encoded as JPEGs
+type Optimization struct {
+
+	// The JPEG quality, from 1 to 100.  In target size mode the highest
+	// quality tried
+	Quality int `json:"quality"`
+
+	// The longest edge of a page image in pixels, 0 for no limit
+	MaxResolution int `json:"max_resolution,omitempty" bson:"max_resolution"`
+
+	// The resolution of a page image as placed on the page, 0 for no limit
+	MaxDPI int `json:"max_dpi,omitempty" bson:"max_dpi"`
+
+	// Whether page images are converted to grayscale
+	Grayscale bool `json:"grayscale,omitempty"`
+
+	// The size in bytes the document should fit within, lowering the
+	// quality until it does.  0 to use Quality as it is
+	TargetSize int64 `json:"target_size,omitempty" bson:"target_size"`
+}
+
+// DefaultQuality is the JPEG quality optimized images are encoded at by default
+const DefaultQuality = 80
+
+// Validate checks the Optimization is supported
+func (o *Optimization) Validate() error {
+	if o.Quality < 1 || o.Quality > 100 {
+		return fmt.Errorf("quality must be between 1 and 100, got %d", o.Quality)
+	}
+	if o.MaxResolution < 0 {
+		return fmt.Errorf("max_resolution can't be negative")
+	}
+	if o.MaxDPI < 0 || o.MaxDPI > MaxDPI {
+		return fmt.Errorf("max_dpi must be between 0 and %d, got %d", MaxDPI, o.MaxDPI)
+	}
+	if o.TargetSize < 0 {
+		return fmt.Errorf("target_size can't be negative")
+	}
+	return nil
+}
+
+// OptimizationResult records the optimization applied to a document.  Quality
+// is the quality chosen, which in target size mode may be lower than asked
+type OptimizationResult struct {
+	Optimization `bson:",inline"`
+
+	// The total size of the page images before and after optimization
+	OriginalSize  int64 `json:"original_size" bson:"original_size"`
+	OptimizedSize int64 `json:"optimized_size" bson:"optimized_size"`
+
+	// In target size mode, whether the document fit the target
+	TargetMet bool `json:"target_met,omitempty" bson:"target_met"`
+}
+
+// Capture describes the document produced by a capture
+type Capture struct {
+	Pages        int                 `json:"pages"`
+	Size         int64               `json:"size"`
+	Optimization *OptimizationResult `json:"optimization,omitempty" bson:"optimization,omitempty"`
+
+	// The hex SHA-256 of each page image as downloaded, in page order
+	PageHashes []string `json:"page_hashes,omitempty" bson:"page_hashes,omitempty"`
+
+	// The name of the extraction rule set that matched the document
+	Rules string `json:"rules,omitempty" bson:"rules,omitempty"`
+}
+
+// Version is a single capture of a document.  Each version is written to its
+// own path so capturing the document again never overwrites an earlier one
+type Version struct {
+	Number  int      `json:"number"`
+	Path    string   `json:"-"`
+	Created int64    `json:"created"`
+	Capture Capture  `json:"capture"`
+	Changes *Changes `json:"changes,omitempty" bson:"changes,omitempty"`
+}
+
+// Changes summarises how the pages of a version differ from the version
+// before it.  Added and Changed are page numbers in the new version, Removed
+// page numbers in the previous one
+type Changes struct {
+	Added   []int `json:"added"`
+	Removed []int `json:"removed"`
+	Changed []int `json:"changed"`
+}
+
+// Empty reports whether no pages were added, removed or changed
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Changed) == 0
+}
+
+// Watch schedules a document to be captured again periodically, recording a
+// new version whenever its pages change
+type Watch struct {
+
+	// How often the document is checked, hourly, daily, weekly or a
+	// duration such as 12h
+	Interval string `json:"interval"`
+
+	// When the document is next checked and was last checked, in
+	// milliseconds since the epoch
+	NextCheck   int64 `json:"next_check" bson:"next_check"`
+	LastChecked int64 `json:"last_checked,omitempty" bson:"last_checked"`
+
+	// Set once the link stops granting access, after which the document is
+	// no longer checked
+	Revoked bool `json:"revoked,omitempty"`
+}
+
+// ParseInterval parses a watch interval, either hourly, daily, weekly or a
+// duration such as 12h
+func ParseInterval(str string) (time.Duration, error) {
+	switch str {
+	case "hourly":
+		return time.Hour, nil
+	case "daily":
+		return 24 * time.Hour, nil
+	case "weekly":
+		return 7 * 24 * time.Hour, nil
+	}
+	interval, err := time.ParseDuration(str)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("unknown watch interval %q, expected hourly, daily, weekly or a duration", str)
+	}
+	return interval, nil
+}
+
+// CompareVersions works out the pages added, removed and changed between the
+// page hashes of two versions.  Pages are matched by the longest common
+// subsequence of their hashes, so inserting a slide doesn't mark every slide
+// after it as changed.  Unmatched pages between two matches are paired up as
+// changed with any left over added or removed
+func CompareVersions(previous []string, current []string) Changes {
+	n, m := len(previous), len(current)
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// previous[i:] and current[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if previous[i] == current[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	changes := Changes{Added: []int{}, Removed: []int{}, Changed: []int{}}
+	var removed, added []int
+	flush := func() {
+		for len(removed) > 0 && len(added) > 0 {
+			changes.Changed = append(changes.Changed, added[0])
+			removed, added = removed[1:], added[1:]
+		}
+		changes.Removed = append(changes.Removed, removed...)
+		changes.Added = append(changes.Added, added...)
+		removed, added = nil, nil
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && previous[i] == current[j]:
+			flush()
+			i++
+			j++
+		case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, i+1)
+			i++
+		default:
+			added = append(added, j+1)
+			j++
+		}
+	}
+	flush()
+	return changes
+}
+
+// Validate checks the Options are supported
+func (o Options) Validate() error {
+	if _, err := ParseFormat(string(o.Format)); err != nil {
+		return err
+	}
+	if _, err := ParseLayout(string(o.Layout)); err != nil {
+		return err
+	}
+	if o.DPI < 1 || o.DPI > MaxDPI {
+		return fmt.Errorf("dpi must be between 1 and %d, got %d", MaxDPI, o.DPI)
+	}
+	if o.Optimize != nil {
+		return o.Optimize.Validate()
+	}
+	return nil
+}
+
 type StatusDetail struct {
 	Message string
 	Created int64
 }
 
+// Job holds what's needed to resume a capture after a restart and the lease
+// held by the worker running it.  It is never sent to clients
+type Job struct {
+	Passcode     string `bson:"passcode,omitempty"`
+	HasPasscode  bool   `bson:"has_passcode"`
+	LeaseOwner   string `bson:"lease_owner,omitempty"`
+	LeaseExpires int64  `bson:"lease_expires,omitempty"`
+
+	// Whether the capture is a watch check, which only records a version if
+	// the pages changed
+	Check bool `bson:"check,omitempty"`
+
+	// The user's answer to the challenge the capture is waiting on,
+	// encrypted if a secret key is configured
+	Answer string `bson:"answer,omitempty"`
+}
+
 type Document struct {
 	ID            bson.ObjectId  `json:"id" bson:"_id"`
 	Owner         string         `json:"owner"`
@@ -23,10 +428,117 @@ type Document struct {
 	URL           string         `json:"url,omitempty"`
 	Status        Status         `json:"status"`
 	StatusDetails []StatusDetail `json:"status_details" bson:"status_details"`
+	Progress      *Progress      `json:"progress,omitempty" bson:"progress,omitempty"`
+	Challenge     *Challenge     `json:"challenge,omitempty" bson:"challenge,omitempty"`
+	Options       Options        `json:"options"`
+	Capture       *Capture       `json:"capture,omitempty" bson:"capture,omitempty"`
+	Version       int            `json:"version"`
+	Versions      []Version      `json:"versions,omitempty" bson:"versions,omitempty"`
+	Watch         *Watch         `json:"watch,omitempty" bson:"watch,omitempty"`
+	Title         string         `json:"title,omitempty" bson:"title,omitempty"`
+	CollectionID  string         `json:"collection_id,omitempty" bson:"collection_id,omitempty"`
+	Adapter       string         `json:"adapter,omitempty" bson:"adapter,omitempty"`
+	Job           Job            `json:"-" bson:"job"`
 	Created       int64          `json:"created"`
 	LastUpdated   int64          `json:"last_updated" bson:"last_updated"`
 }
 
+// Cancellable reports whether the document's capture can still be cancelled
+func (d *Document) Cancellable() bool {
+	return d.Active()
+}
+
+// Active reports whether the document is queued, being captured or waiting
+// on input for its capture
+func (d *Document) Active() bool {
+	return d.Status == StatusPending || d.Status == StatusCapturing || d.Status == StatusAwaitingInput
+}
+
+// OutputPath returns the path in the object store of the latest version.
+// Documents captured before versioning keep the path they were written to
+func (d *Document) OutputPath() string {
+	if version, ok := d.GetVersion(d.Version); ok {
+		return version.Path
+	}
+	return path.Join(d.Owner, path.Base(d.SourceURL)+"."+d.Options.Format.Extension())
+}
+
+// NextVersion returns the number of the next version to be captured
+func (d *Document) NextVersion() int {
+	return d.Version + 1
+}
+
+// VersionPath returns the path in the object store of the supplied version
+func (d *Document) VersionPath(number int) string {
+	name := fmt.Sprintf("%s.v%d.%s", path.Base(d.SourceURL), number, d.Options.Format.Extension())
+	return path.Join(d.Owner, d.ID.Hex(), name)
+}
+
+// GetVersion returns the supplied version of the document, or false if it
+// has no such version
+func (d *Document) GetVersion(number int) (Version, bool) {
+	for _, version := range d.Versions {
+		if version.Number == number {
+			return version, true
+		}
+	}
+	return Version{}, false
+}
+
+// Leasable reports whether the supplied owner may lease the document at the
+// given time.  Only active documents can be leased, and only when unleased,
+// already leased by the owner or the lease has expired
+func (d *Document) Leasable(owner string, now int64) bool {
+	if !d.Active() {
+		return false
+	}
+	return d.Job.LeaseOwner == "" || d.Job.LeaseOwner == owner || d.Job.LeaseExpires < now
+}
+
+// Collection is a DocSend space, a folder of documents behind one link.  Each
+// document in the space is captured as a Document of its own.  The status and
+// documents aren't stored with the collection, they're read from its
+// documents
+type Collection struct {
+	ID        bson.ObjectId `json:"id" bson:"_id"`
+	Owner     string        `json:"owner"`
+	SourceURL string        `json:"source_url" bson:"source_url"`
+	Adapter   string        `json:"adapter"`
+	Title     string        `json:"title"`
+	Options   Options       `json:"options"`
+	Created   int64         `json:"created"`
+	Status    Status        `json:"status" bson:"-"`
+	Documents []*Document   `json:"documents" bson:"-"`
+}
+
+// CollectionStatus works out the status of a collection from its documents.
+// It is capturing until every document has finished, then in error if any
+// document failed or was cancelled and otherwise complete
+func CollectionStatus(docs []*Document) Status {
+	status := StatusComplete
+	pending := true
+	for _, doc := range docs {
+		switch doc.Status {
+		case StatusPending:
+			status = StatusCapturing
+		case StatusCapturing, StatusAwaitingInput:
+			status = StatusCapturing
+			pending = false
+		case StatusError, StatusCancelled:
+			pending = false
+			if status == StatusComplete {
+				status = StatusError
+			}
+		default:
+			pending = false
+		}
+	}
+	if status == StatusCapturing && pending {
+		return StatusPending
+	}
+	return status
+}
+
 type Message struct {
 	Type string      `json:"type"`
 	Data interface{} `json:"data"`
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous []string
+		current  []string
+		expected Changes
+	}{
+		{"unchanged", []string{"a", "b", "c"}, []string{"a", "b", "c"},
+			Changes{Added: []int{}, Removed: []int{}, Changed: []int{}}},
+		{"inserted", []string{"a", "b", "c"}, []string{"a", "x", "b", "c"},
+			Changes{Added: []int{2}, Removed: []int{}, Changed: []int{}}},
+		{"appended", []string{"a", "b"}, []string{"a", "b", "c"},
+			Changes{Added: []int{3}, Removed: []int{}, Changed: []int{}}},
+		{"removed", []string{"a", "b", "c"}, []string{"a", "c"},
+			Changes{Added: []int{}, Removed: []int{2}, Changed: []int{}}},
+		{"changed", []string{"a", "b", "c"}, []string{"a", "x", "c"},
+			Changes{Added: []int{}, Removed: []int{}, Changed: []int{2}}},
+		{"changed and inserted", []string{"a", "b", "c"}, []string{"a", "x", "y", "z", "c"},
+			Changes{Added: []int{3, 4}, Removed: []int{}, Changed: []int{2}}},
+		{"changed and removed", []string{"a", "b", "c", "d"}, []string{"a", "x", "d"},
+			Changes{Added: []int{}, Removed: []int{3}, Changed: []int{2}}},
+		{"moved", []string{"a", "b"}, []string{"b", "a"},
+			Changes{Added: []int{2}, Removed: []int{1}, Changed: []int{}}},
+		{"all new", []string{}, []string{"a", "b"},
+			Changes{Added: []int{1, 2}, Removed: []int{}, Changed: []int{}}},
+		{"all gone", []string{"a", "b"}, []string{},
+			Changes{Added: []int{}, Removed: []int{1, 2}, Changed: []int{}}},
+		{"empty", nil, nil,
+			Changes{Added: []int{}, Removed: []int{}, Changed: []int{}}},
+	}
+	for _, tt := range tests {
+		if changes := CompareVersions(tt.previous, tt.current); !reflect.DeepEqual(changes, tt.expected) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.expected, changes)
+		}
+	}
+}
@@ -16,7 +16,7 @@ new Vue({
       this.email = null;
     },
     connectSocket() {
-      connection = new WebSocket('ws://' + window.location.hostname + ':8080/api/status?owner=' + this.email);
+      connection = new WebSocket('ws://' + window.location.host + '/api/status?owner=' + this.email);
       connection.onopen = () => {
         console.log('WebSocket connected');
       };
@@ -31,6 +31,18 @@ new Vue({
         var message = JSON.parse(e.data);
         if (message.type == "PING") {
           connection.send({type: "PONG"});
+        } else if (message.type == "PROGRESS") {
+          var update = message.data;
+          for (let i = 0, n = this.documents.length; i < n; i++) {
+            if (this.documents[i].id == update.id) {
+              Vue.set(this.documents[i], 'progress', update.progress);
+              break;
+            }
+          }
+        } else if (message.type == "CHALLENGE") {
+          this.answer(message.data.id, message.data.challenge);
+        } else if (message.type == "CHANGED" || message.type == "REVOKED") {
+          console.log('Document ' + message.data.id + ' ' + message.type.toLowerCase(), message.data);
         } else {
           var document = message.data;
           for (let i = 0, n = this.documents.length; i < n; i++) {
@@ -63,6 +75,31 @@ new Vue({
     },
     download(id) {
       window.location = '/api/documents/' + id + '/download';
+    },
+    percent(progress) {
+      if (!progress || !progress.pages_total) {
+        return 0;
+      }
+      return Math.round(100 * progress.pages_done / progress.pages_total);
+    },
+    cancel(id) {
+      $.post('/api/documents/' + id + '/cancel');
+    },
+    answer(id, challenge) {
+      var code = window.prompt(challenge.message);
+      if (code) {
+        $.post('/api/documents/' + id + '/challenge', {code: code});
+      }
+    },
+    recapture(id) {
+      $.post('/api/documents/' + id + '/recapture');
+    },
+    watch(item) {
+      $.ajax({
+        url: '/api/documents/' + item.id + '/watch',
+        type: item.watch ? 'DELETE' : 'PUT',
+        data: item.watch ? null : {interval: 'daily'}
+      });
     }
   },
   mounted() {
@@ -0,0 +1,125 @@
+package scraper
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/headzoo/surf/browser"
+)
+
+// GetFunc issues a GET request for a page of the document within the session
+// the document was opened in
+type GetFunc func(url string) (*http.Response, error)
+
+// ChallengeFunc asks the user for the input a gate needs, such as a
+// verification code emailed to them, returning their answer
+type ChallengeFunc func(challenge model.Challenge) (string, error)
+
+// SiteAdapter handles the specifics of a site hosting decks in a web viewer,
+// such as DocSend.  The Scraper opens the document through the adapter, then
+// downloads and assembles the pages it finds
+type SiteAdapter interface {
+
+	// Name identifies the adapter, it's recorded with each document the
+	// adapter captures
+	Name() string
+
+	// Match reports whether the adapter handles the URL
+	Match(u *url.URL) bool
+
+	// Authenticate opens the URL in the Browser, passing the gates the site
+	// puts in front of the document.  The supplied email and passcode are
+	// submitted if asked for, and input only the user can give is asked for
+	// through the ChallengeFunc.  Returns ErrAuthenticationFailed if the
+	// site rejects them
+	Authenticate(bow *browser.Browser, u *url.URL, email string, passcode string, challenge ChallengeFunc) error
+
+	// Pages lists the URL of each page of the document open in the Browser,
+	// in order.  Relative URLs are resolved against the open document
+	Pages(bow *browser.Browser) ([]string, error)
+
+	// FetchPage fetches the metadata for the page at index from one of the
+	// URLs listed by Pages
+	FetchPage(get GetFunc, url string, index int) (*Page, error)
+}
+
+// SpaceAdapter is implemented by a SiteAdapter for a site that can share
+// several documents behind a single link
+type SpaceAdapter interface {
+	SiteAdapter
+
+	// IsSpace reports whether the URL links to a space of documents rather
+	// than a single document
+	IsSpace(u *url.URL) bool
+
+	// Documents lists the documents in the space open in the Browser
+	Documents(bow *browser.Browser, u *url.URL) ([]SpaceDocument, error)
+}
+
+var (
+	adaptersMutex sync.RWMutex
+	adapters      = make([]SiteAdapter, 0)
+)
+
+// RegisterAdapter adds a SiteAdapter to the registry.  Adapters are matched in
+// the order they're registered, and an adapter replaces any registered with
+// the same name
+func RegisterAdapter(adapter SiteAdapter) {
+	adaptersMutex.Lock()
+	defer adaptersMutex.Unlock()
+
+	for i, registered := range adapters {
+		if registered.Name() == adapter.Name() {
+			adapters[i] = adapter
+			return
+		}
+	}
+	adapters = append(adapters, adapter)
+}
+
+// Adapters returns the registered adapters in the order they're matched
+func Adapters() []SiteAdapter {
+	adaptersMutex.RLock()
+	defer adaptersMutex.RUnlock()
+	return append([]SiteAdapter(nil), adapters...)
+}
+
+// LookupAdapter returns the registered adapter with the supplied name, or nil
+// if there is none
+func LookupAdapter(name string) SiteAdapter {
+	for _, adapter := range Adapters() {
+		if adapter.Name() == name {
+			return adapter
+		}
+	}
+	return nil
+}
+
+// MatchAdapter returns the first registered adapter that handles the URL, or
+// nil if there is none
+func MatchAdapter(u *url.URL) SiteAdapter {
+	for _, adapter := range Adapters() {
+		if adapter.Match(u) {
+			return adapter
+		}
+	}
+	return nil
+}
+
+// adapterFor returns the adapter named by the name, or the adapter matching
+// the URL if no name is supplied.  Returns ErrUnsupportedSite if there is no
+// such adapter
+func adapterFor(name string, u *url.URL) (SiteAdapter, error) {
+	var adapter SiteAdapter
+	if name != "" {
+		adapter = LookupAdapter(name)
+	} else {
+		adapter = MatchAdapter(u)
+	}
+	if adapter == nil {
+		return nil, ErrUnsupportedSite
+	}
+	return adapter, nil
+}
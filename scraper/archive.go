@@ -0,0 +1,99 @@
+package scraper
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// Manifest describes the pages of a ZIP archive
+type Manifest struct {
+	SourceURL string         `json:"source_url"`
+	Pages     []ManifestPage `json:"pages"`
+}
+
+// ManifestPage describes a single page image within a ZIP archive.  The link
+// rectangles are fractions of the image's width and height
+type ManifestPage struct {
+	Number int    `json:"number"`
+	Image  string `json:"image"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Links  []Link `json:"links"`
+}
+
+// writeZip writes a ZIP of the numbered page images with a manifest.json
+// describing each page and its links
+func (s *Scraper) writeZip(w io.Writer, source *url.URL, pages []*Page) error {
+
+	manifest := Manifest{
+		SourceURL: source.String(),
+		Pages:     make([]ManifestPage, 0, len(pages)),
+	}
+
+	archive := zip.NewWriter(w)
+	err := s.writeImages(archive, pages, func(index int, name string, width int, height int) {
+		links := pages[index].Links
+		if links == nil {
+			links = []Link{}
+		}
+		manifest.Pages = append(manifest.Pages, ManifestPage{
+			Number: index + 1,
+			Image:  name,
+			Width:  width,
+			Height: height,
+			Links:  links,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	f, err := archive.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// writeCBZ writes a comic book archive of the numbered page images
+func (s *Scraper) writeCBZ(w io.Writer, pages []*Page) error {
+	archive := zip.NewWriter(w)
+	if err := s.writeImages(archive, pages, nil); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// writeImages adds each page image to the archive, numbered so they sort in
+// page order, calling added with the name and dimensions of each
+func (s *Scraper) writeImages(archive *zip.Writer, pages []*Page, added func(index int, name string, width int, height int)) error {
+	for i := range pages {
+		data, config, err := s.pageImage(i)
+		if err != nil {
+			return err
+		}
+
+		// The images are already compressed, so are stored as they are
+		name := fmt.Sprintf("page-%04d.%s", i+1, imageExtension(data))
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+
+		if added != nil {
+			added(i, name, config.Width, config.Height)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package scraper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+// metadataKey - The cache key of the metadata for the page at index
+func (s *Scraper) metadataKey(index int) string {
+	return path.Join(s.CachePrefix, fmt.Sprintf("page-%04d.json", index+1))
+}
+
+// imageKey - The cache key of the image for the page at index
+func (s *Scraper) imageKey(index int) string {
+	return path.Join(s.CachePrefix, fmt.Sprintf("page-%04d.img", index+1))
+}
+
+// optimizedKey - The cache key of the optimized image for the page at index
+func (s *Scraper) optimizedKey(index int) string {
+	return path.Join(s.CachePrefix, fmt.Sprintf("page-%04d.opt", index+1))
+}
+
+// cacheEntry is the metadata cached for a page, along with the URL of the
+// page data it was fetched from
+type cacheEntry struct {
+	URL  string `json:"url"`
+	Page *Page  `json:"page"`
+}
+
+// cachedPage loads the entry for the page at index from the cache, returning
+// nil if it hasn't been downloaded
+func (s *Scraper) cachedPage(index int) (*cacheEntry, error) {
+	exists, err := s.Cache.Exists(s.metadataKey(index))
+	if err != nil || !exists {
+		return nil, err
+	}
+	data, err := s.readCache(s.metadataKey(index))
+	if err != nil {
+		return nil, err
+	}
+	var entry *cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	if entry == nil || entry.Page == nil {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// cachePage writes the metadata for the page at index, fetched from the page
+// data at url, to the cache
+func (s *Scraper) cachePage(page *Page, url string, index int) error {
+	data, err := json.Marshal(&cacheEntry{URL: url, Page: page})
+	if err != nil {
+		return err
+	}
+	return s.Cache.Write(s.metadataKey(index), bytes.NewReader(data))
+}
+
+// readCache reads the object at key fully from the cache
+func (s *Scraper) readCache(key string) ([]byte, error) {
+	reader, err := s.Cache.Read(key)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	return ioutil.ReadAll(reader)
+}
+
+// pageHashes returns the hex SHA-256 of each of the n downloaded page images
+func (s *Scraper) pageHashes(n int) ([]string, error) {
+	hashes := make([]string, n)
+	for i := range hashes {
+		data, err := s.readCache(s.imageKey(i))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		hashes[i] = hex.EncodeToString(sum[:])
+	}
+	return hashes, nil
+}
+
+// equalHashes reports whether two sets of page hashes are the same
+func equalHashes(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// clearCache deletes the cached metadata and images for n pages
+func (s *Scraper) clearCache(n int) {
+	for i := 0; i < n; i++ {
+		keys := []string{s.metadataKey(i), s.imageKey(i)}
+		if s.originalSizes != nil {
+			keys = append(keys, s.optimizedKey(i))
+		}
+		for _, key := range keys {
+			if err := s.Cache.Delete(key); err != nil {
+				logger.Warnf("Failed to delete cached page %s: %s", key, err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aldelucca1/docsend_scraper/scraper/fake"
+)
+
+func TestCachedPageFromAnotherURL(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	server.AddDocument(fake.NewDocument("deck", 3, ""))
+
+	s, _ := newTestScraper(server)
+	cache := s.Cache
+	s.CachePrefix = "cache/deck"
+	s.Options.KeepPages = true
+	if err := s.Scrape(context.Background(), server.DocumentURL("deck"), "a@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first page was cached from another page's data
+	var entry cacheEntry
+	if err := json.Unmarshal(readObject(t, cache, s.metadataKey(0)), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.URL == "" || entry.Page == nil {
+		t.Fatalf("expected the page cached along with its URL, got %+v", entry)
+	}
+	entry.URL = server.DocumentURL("other").String() + "/page_data/1"
+	data, _ := json.Marshal(&entry)
+	if err := cache.Write(s.metadataKey(0), bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	s, _ = newTestScraper(server)
+	s.Cache = cache
+	s.CachePrefix = "cache/deck"
+	if err := s.Scrape(context.Background(), server.DocumentURL("deck"), "a@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		requests int
+	}{
+		{"/images/deck/1", 2},
+		{"/images/deck/2", 1},
+		{"/images/deck/3", 1},
+	}
+	for _, tt := range tests {
+		if n := server.Requests(tt.path); n != tt.requests {
+			t.Errorf("%s: expected %d requests, got %d", tt.path, tt.requests, n)
+		}
+	}
+}
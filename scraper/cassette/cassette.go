@@ -0,0 +1,186 @@
+package cassette
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Version is the version of the cassette format written by a Recorder
+const Version = 1
+
+// IndexFile is the name of the file listing a cassette's interactions
+const IndexFile = "cassette.json"
+
+// Scrubbed replaces credentials and session tokens in a cassette
+const Scrubbed = "SCRUBBED"
+
+// Query parameters that sign or authorise a URL, scrubbed from recorded URLs
+var signingParams = map[string]bool{
+	"expires":              true,
+	"key-pair-id":          true,
+	"policy":               true,
+	"signature":            true,
+	"x-amz-credential":     true,
+	"x-amz-security-token": true,
+	"x-amz-signature":      true,
+}
+
+// Response headers kept in a cassette, the rest vary between recordings
+var keptHeaders = []string{"Content-Type", "Location", "Set-Cookie"}
+
+// Cassette is the index of the requests and responses recorded to a directory
+type Cassette struct {
+	Version      int            `json:"version"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a single request and the response it received
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded request.  The URL has its signing parameters scrubbed
+// and the form holds the posted fields with credentials scrubbed
+type Request struct {
+	Method string     `json:"method"`
+	URL    string     `json:"url"`
+	Form   url.Values `json:"form,omitempty"`
+}
+
+// Response is a recorded response, its body is held in a file of the cassette
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Load reads the cassette recorded to the directory
+func Load(dir string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, IndexFile))
+	if err != nil {
+		return nil, err
+	}
+	c := new(Cassette)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// save writes the cassette's index to the directory
+func (c *Cassette) save(dir string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, IndexFile), append(data, '\n'), 0644)
+}
+
+// key identifies the requests a recorded response is replayed for
+func key(method string, u string) string {
+	return method + " " + u
+}
+
+// scrubURL removes the signing parameters from the URL's query and replaces
+// any of the secrets, keeping the remaining parameters in a stable order
+func scrubURL(u *url.URL, secrets []string) string {
+	c := *u
+	c.Fragment = ""
+	if c.RawQuery != "" {
+		query := c.Query()
+		for name := range query {
+			if signingParams[strings.ToLower(name)] {
+				query[name] = []string{Scrubbed}
+			}
+		}
+		c.RawQuery = query.Encode()
+	}
+	return scrub(c.String(), secrets)
+}
+
+// scrub replaces each of the secrets in the string
+func scrub(s string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.Replace(s, secret, Scrubbed, -1)
+			if escaped := url.QueryEscape(secret); escaped != secret {
+				s = strings.Replace(s, escaped, Scrubbed, -1)
+			}
+		}
+	}
+	return s
+}
+
+// scrubForm copies the posted form with every value that is or holds one of
+// the secrets scrubbed, as well as the fields that look like credentials
+func scrubForm(form url.Values, secrets []string) url.Values {
+	if len(form) == 0 {
+		return nil
+	}
+	scrubbed := make(url.Values, len(form))
+	for name, values := range form {
+		lower := strings.ToLower(name)
+		credential := strings.Contains(lower, "passcode") || strings.Contains(lower, "password") ||
+			strings.Contains(lower, "email") || strings.Contains(lower, "token")
+		for _, value := range values {
+			if credential && value != "" {
+				value = Scrubbed
+			}
+			scrubbed[name] = append(scrubbed[name], scrub(value, secrets))
+		}
+	}
+	return scrubbed
+}
+
+// scrubHeader keeps the response headers replayed by a Player, with cookie
+// values scrubbed
+func scrubHeader(header http.Header) http.Header {
+	kept := make(http.Header)
+	for _, name := range keptHeaders {
+		for _, value := range header[name] {
+			if name == "Set-Cookie" {
+				value = scrubCookie(value)
+			}
+			kept.Add(name, value)
+		}
+	}
+	return kept
+}
+
+// scrubCookie replaces the value of a Set-Cookie header, keeping its name and
+// attributes so the cookie is still set when replayed
+func scrubCookie(header string) string {
+	parts := strings.SplitN(header, ";", 2)
+	name := strings.SplitN(parts[0], "=", 2)[0]
+	parts[0] = name + "=" + Scrubbed
+	return strings.Join(parts, ";")
+}
+
+// extension returns the file extension a body of the content type is stored
+// with
+func extension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/html":
+		return ".html"
+	case "application/json":
+		return ".json"
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "text/plain":
+		return ".txt"
+	}
+	return ".bin"
+}
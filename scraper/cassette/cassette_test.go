@@ -0,0 +1,81 @@
+package cassette
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestScrubURL(t *testing.T) {
+	secrets := []string{"a+b@example.com", "secret"}
+
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+	}{
+		{"plain", "https://docsend.com/view/deck", "https://docsend.com/view/deck"},
+		{"fragment", "https://docsend.com/view/deck#page=2", "https://docsend.com/view/deck"},
+		{"sorted query", "https://docsend.com/view/deck?b=2&a=1", "https://docsend.com/view/deck?a=1&b=2"},
+		{"cloudfront signature", "https://d1.cloudfront.net/1.png?Expires=1&Signature=abc&Key-Pair-Id=K1&w=800",
+			"https://d1.cloudfront.net/1.png?Expires=SCRUBBED&Key-Pair-Id=SCRUBBED&Signature=SCRUBBED&w=800"},
+		{"s3 signature", "https://bucket.s3.amazonaws.com/1.png?X-Amz-Credential=c&X-Amz-Signature=s&X-Amz-Security-Token=t",
+			"https://bucket.s3.amazonaws.com/1.png?X-Amz-Credential=SCRUBBED&X-Amz-Security-Token=SCRUBBED&X-Amz-Signature=SCRUBBED"},
+		{"email in query", "https://docsend.com/view/deck?email=a%2Bb%40example.com", "https://docsend.com/view/deck?email=SCRUBBED"},
+		{"passcode in path", "https://docsend.com/view/secret/page_data/1", "https://docsend.com/view/SCRUBBED/page_data/1"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scrubbed := scrubURL(u, secrets); scrubbed != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, scrubbed)
+		}
+	}
+}
+
+func TestScrubForm(t *testing.T) {
+	secrets := []string{"a@example.com", "secret"}
+
+	tests := []struct {
+		name     string
+		form     url.Values
+		expected url.Values
+	}{
+		{"empty", url.Values{}, nil},
+		{"auth form",
+			url.Values{"link_auth_form[email]": {"a@example.com"}, "link_auth_form[passcode]": {"secret"}, "commit": {"Continue"}},
+			url.Values{"link_auth_form[email]": {Scrubbed}, "link_auth_form[passcode]": {Scrubbed}, "commit": {"Continue"}}},
+		{"credential field names",
+			url.Values{"user_password": {"other"}, "authenticity_token": {"abc"}, "Email": {"b@example.com"}},
+			url.Values{"user_password": {Scrubbed}, "authenticity_token": {Scrubbed}, "Email": {Scrubbed}}},
+		{"empty credentials kept empty",
+			url.Values{"link_auth_form[passcode]": {""}},
+			url.Values{"link_auth_form[passcode]": {""}}},
+		{"secret in other field",
+			url.Values{"note": {"from a@example.com"}, "link_auth_form[accept_nda]": {"0", "1"}},
+			url.Values{"note": {"from " + Scrubbed}, "link_auth_form[accept_nda]": {"0", "1"}}},
+	}
+	for _, tt := range tests {
+		if scrubbed := scrubForm(tt.form, secrets); !reflect.DeepEqual(scrubbed, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, scrubbed)
+		}
+	}
+}
+
+func TestScrubCookie(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"session=abc123", "session=SCRUBBED"},
+		{"session=abc123; Path=/; HttpOnly", "session=SCRUBBED; Path=/; HttpOnly"},
+		{"session=", "session=SCRUBBED"},
+	}
+	for _, tt := range tests {
+		if scrubbed := scrubCookie(tt.header); scrubbed != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.header, tt.expected, scrubbed)
+		}
+	}
+}
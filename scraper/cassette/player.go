@@ -0,0 +1,104 @@
+package cassette
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+// Player is an http.RoundTripper answering requests with the responses
+// recorded to a cassette, without touching the network.  Requests are matched
+// by method and URL, with the URL scrubbed as it was when recorded.  Repeated
+// requests are answered with the recorded responses in order, the last being
+// repeated once they run out.  Requests that weren't recorded are answered
+// with a 404
+type Player struct {
+	dir     string
+	secrets []string
+	mutex   sync.Mutex
+	queues  map[string][]*Interaction
+}
+
+// NewPlayer loads the cassette recorded to the directory.  The secrets should
+// be those the cassette was recorded with, so the URLs requested match those
+// recorded
+func NewPlayer(dir string, secrets ...string) (*Player, error) {
+	c, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	p := &Player{
+		dir:     dir,
+		secrets: secrets,
+		queues:  make(map[string][]*Interaction),
+	}
+	for _, interaction := range c.Interactions {
+		k := key(interaction.Request.Method, interaction.Request.URL)
+		p.queues[k] = append(p.queues[k], interaction)
+	}
+	return p, nil
+}
+
+// RoundTrip answers the request with the next response recorded for it
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	k := key(req.Method, scrubURL(req.URL, p.secrets))
+	interaction := p.next(k)
+	if interaction == nil {
+		logger.Warnf("No recorded response for %s", k)
+		return p.response(req, http.StatusNotFound, http.Header{"Content-Type": {"text/plain"}}, []byte("No recorded response for "+k))
+	}
+
+	var body []byte
+	if interaction.Response.Body != "" {
+		var err error
+		if body, err = ioutil.ReadFile(filepath.Join(p.dir, interaction.Response.Body)); err != nil {
+			return nil, err
+		}
+	}
+	header := make(http.Header)
+	for name, values := range interaction.Response.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	return p.response(req, interaction.Response.Status, header, body)
+}
+
+// next takes the next interaction recorded for the key, or nil if there are
+// none
+func (p *Player) next(k string) *Interaction {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	queue := p.queues[k]
+	if len(queue) == 0 {
+		return nil
+	}
+	if len(queue) > 1 {
+		p.queues[k] = queue[1:]
+	}
+	return queue[0]
+}
+
+// response builds the response to the request
+func (p *Player) response(req *http.Request, status int, header http.Header, body []byte) (*http.Response, error) {
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,113 @@
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Recorder is an http.RoundTripper that sends each request through its base
+// transport and records the request and response to a cassette directory.
+// Credentials, session cookies and URL signatures are scrubbed as they're
+// recorded
+type Recorder struct {
+	dir      string
+	base     http.RoundTripper
+	secrets  []string
+	mutex    sync.Mutex
+	cassette *Cassette
+}
+
+// NewRecorder returns a Recorder writing to the directory, which is created if
+// it doesn't exist, and sending requests through the base transport.  A
+// cassette already in the directory is replaced.  Any of the secrets, such as
+// the email address and passcode a document is captured with, are scrubbed
+// wherever they appear in the recording
+func NewRecorder(dir string, base http.RoundTripper, secrets ...string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	r := &Recorder{
+		dir:      dir,
+		base:     base,
+		secrets:  secrets,
+		cassette: &Cassette{Version: Version, Interactions: make([]*Interaction, 0)},
+	}
+	return r, r.cassette.save(dir)
+}
+
+// RoundTrip sends the request and records it along with its response
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	// Read the posted form, restoring the body for the base transport
+	var form url.Values
+	if req.Body != nil && strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		data, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(data))
+		if form, err = url.ParseQuery(string(data)); err != nil {
+			form = nil
+		}
+	}
+
+	rsp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	rsp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	interaction := &Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    scrubURL(req.URL, r.secrets),
+			Form:   scrubForm(form, r.secrets),
+		},
+		Response: Response{
+			Status: rsp.StatusCode,
+			Header: scrubHeader(rsp.Header),
+		},
+	}
+	if err := r.record(interaction, body, rsp.Header.Get("Content-Type")); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+// record writes the response body and adds the interaction to the index
+func (r *Recorder) record(interaction *Interaction, body []byte, contentType string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(body) > 0 {
+		interaction.Response.Body = fmt.Sprintf("%04d%s", len(r.cassette.Interactions)+1, extension(contentType))
+
+		// Text bodies may echo the credentials, images are kept as they are
+		if ext := filepath.Ext(interaction.Response.Body); ext == ".html" || ext == ".json" || ext == ".txt" {
+			body = []byte(scrub(string(body), r.secrets))
+		}
+		if err := ioutil.WriteFile(filepath.Join(r.dir, interaction.Response.Body), body, 0644); err != nil {
+			return err
+		}
+	}
+
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	return r.cassette.save(r.dir)
+}
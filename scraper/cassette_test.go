@@ -0,0 +1,125 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aldelucca1/docsend_scraper/scraper/cassette"
+	"github.com/aldelucca1/docsend_scraper/scraper/fake"
+	"github.com/aldelucca1/docsend_scraper/store/memory"
+)
+
+var update = flag.Bool("update", false, "re-record the cassettes in testdata from the fake server")
+
+// The credentials the cassettes in testdata were recorded with
+const (
+	cassetteEmail    = "viewer@example.com"
+	cassettePasscode = "hunter2"
+)
+
+// recordCassette captures the fake document with the slug, recording the
+// capture to the cassette directory
+func recordCassette(t *testing.T, server *fake.Server, slug string, dir string) {
+	recorder, err := cassette.NewRecorder(dir, server.Transport(), cassetteEmail, cassettePasscode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewScraperWithClient(memory.NewObjectStore(), &http.Client{Transport: recorder})
+	if err := s.ScrapeTo(context.Background(), server.DocumentURL(slug), cassetteEmail, cassettePasscode, "deck.pdf"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// replayCassette captures the document with the slug from the cassette
+// directory, returning the Scraper and the PDF it wrote
+func replayCassette(t *testing.T, slug string, dir string) (*Scraper, []byte) {
+	player, err := cassette.NewPlayer(dir, cassetteEmail, cassettePasscode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os := memory.NewObjectStore()
+	s := NewScraperWithClient(os, &http.Client{Transport: player})
+	u := &url.URL{Scheme: "https", Host: fake.Host, Path: "/view/" + slug}
+	if err := s.ScrapeTo(context.Background(), u, cassetteEmail, cassettePasscode, "deck.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	return s, readObject(t, os, "deck.pdf")
+}
+
+func TestRecordCassette(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	doc := fake.NewDocument("deck", 2, cassettePasscode)
+	doc.NDA = true
+	server.AddDocument(doc)
+
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	recordCassette(t, server, "deck", dir)
+
+	// The credentials appear nowhere in the recording
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range []string{cassetteEmail, cassettePasscode, "viewer%40example.com"} {
+			if bytes.Contains(data, []byte(secret)) {
+				t.Errorf("expected %s to be scrubbed from %s", secret, file.Name())
+			}
+		}
+	}
+
+	// The recording replays without the server
+	server.Close()
+	s, data := replayCassette(t, "deck", dir)
+	if s.Capture.Pages != 2 {
+		t.Errorf("expected the replayed capture to have 2 pages, got %d", s.Capture.Pages)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		t.Error("expected the replayed capture to write a PDF")
+	}
+}
+
+func TestReplayCassette(t *testing.T) {
+	dir := filepath.Join("testdata", "cassettes", "deck")
+	if *update {
+		server := fake.NewServer()
+		doc := fake.NewDocument("deck", 3, cassettePasscode)
+		doc.Links = [][]fake.Link{{{X: 0.6, Y: 0.8, Width: 0.3, Height: 0.1, URI: "https://example.com/pricing"}}}
+		server.AddDocument(doc)
+		os.RemoveAll(dir)
+		recordCassette(t, server, "deck", dir)
+		server.Close()
+	}
+
+	s, data := replayCassette(t, "deck", dir)
+	if s.Capture.Pages != 3 || len(s.Capture.PageHashes) != 3 {
+		t.Fatalf("expected a 3 page capture, got %+v", s.Capture)
+	}
+	if s.Capture.Rules != "docsend-1" {
+		t.Errorf("expected the pages found by the docsend-1 rules, got %q", s.Capture.Rules)
+	}
+	if n := bytes.Count(data, []byte("<</Type /Page\n")); n != 3 {
+		t.Errorf("expected 3 pages in the replayed PDF, got %d", n)
+	}
+	for _, expected := range []string{"%PDF", "/Title (Slide 3)", "/URI (https://example.com/pricing)"} {
+		if !bytes.Contains(data, []byte(expected)) {
+			t.Errorf("expected %q in the replayed PDF", expected)
+		}
+	}
+}
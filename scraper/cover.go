@@ -0,0 +1,76 @@
+package scraper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// The margin around the text of the cover page in points
+const coverMargin = 36
+
+// addCover adds a page to the PDF describing where the document came from,
+// sized like the first page.  The digest is of the page images as downloaded,
+// so captures of the same slides can be compared whatever their options
+func (s *Scraper) addCover(pdf *gofpdf.Fpdf, meta Metadata, n int) error {
+
+	digest, err := s.imagesDigest(n)
+	if err != nil {
+		return err
+	}
+
+	_, config, err := s.pageImage(0)
+	if err != nil {
+		return err
+	}
+	size, _ := s.Options.pageLayout(config.Width, config.Height)
+	pdf.AddPageFormat("P", size)
+	pdf.Bookmark("Cover", 0, 0)
+
+	// The core fonts only cover the Windows-1252 characters
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	width := size.Wd - 2*coverMargin
+	pdf.SetXY(coverMargin, coverMargin)
+
+	pdf.SetFont("Helvetica", "B", 20)
+	pdf.MultiCell(width, 26, tr(meta.Title), "", "L", false)
+	pdf.Ln(12)
+
+	lines := []struct{ label, value string }{
+		{"Source", meta.Source.String()},
+		{"Captured", meta.Captured.UTC().Format(time.RFC3339)},
+		{"Captured for", meta.Author},
+		{"Pages", fmt.Sprintf("%d", n)},
+		{"SHA-256 of page images", digest},
+	}
+	for _, line := range lines {
+		if line.value == "" {
+			continue
+		}
+		pdf.SetX(coverMargin)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.MultiCell(width, 14, tr(line.label), "", "L", false)
+		pdf.SetX(coverMargin)
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.MultiCell(width, 14, tr(line.value), "", "L", false)
+		pdf.Ln(6)
+	}
+	return pdf.Error()
+}
+
+// imagesDigest returns the hex SHA-256 of the downloaded page images in page
+// order
+func (s *Scraper) imagesDigest(n int) (string, error) {
+	hash := sha256.New()
+	for i := 0; i < n; i++ {
+		data, err := s.readCache(s.imageKey(i))
+		if err != nil {
+			return "", err
+		}
+		hash.Write(data)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,309 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/headzoo/surf/browser"
+)
+
+// DocSendAdapterName is the name of the DocSend SiteAdapter
+const DocSendAdapterName = "docsend"
+
+func init() {
+	RegisterAdapter(&DocSendAdapter{})
+}
+
+// DocSendAdapter is the SiteAdapter for documents and spaces shared from
+// docsend.com
+type DocSendAdapter struct{}
+
+// ruleSets returns the DocSend rule sets in use
+func (a *DocSendAdapter) ruleSets() []RuleSet {
+	return CurrentRules().For(DocSendAdapterName)
+}
+
+// Name returns the name of the adapter
+func (a *DocSendAdapter) Name() string {
+	return DocSendAdapterName
+}
+
+// Match reports whether the URL is on docsend.com or one of its subdomains
+func (a *DocSendAdapter) Match(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	return (u.Scheme == "https" || u.Scheme == "http") &&
+		(host == "docsend.com" || strings.HasSuffix(host, ".docsend.com"))
+}
+
+// MaxVerificationAttempts is the number of verification codes asked for
+// before giving up on a document
+const MaxVerificationAttempts = 3
+
+// maxGates bounds the gates passed in opening a document, in case the site
+// keeps showing new ones
+const maxGates = 8
+
+// Authenticate opens the URL, passing each of DocSend's gates in turn.  The
+// email and passcode are filled into the auth form, or just the email if it
+// only asks for one, NDAs are accepted and a verification code emailed to the
+// viewer is asked for through the ChallengeFunc
+func (a *DocSendAdapter) Authenticate(bow *browser.Browser, u *url.URL, email string, passcode string, challenge ChallengeFunc) error {
+
+	// Open the root URL
+	err := bow.Open(u.String())
+	if err != nil {
+		return err
+	}
+	if bow.StatusCode() != http.StatusOK {
+		return &HTTPError{Message: "Failed to fetch document", StatusCode: bow.StatusCode()}
+	}
+
+	var previous model.Gate
+	attempt := 0
+	for i := 0; i < maxGates; i++ {
+		gate, set, form := a.gate(bow.Dom())
+
+		values := make(map[string]string)
+		switch gate {
+		case "":
+			if bow.StatusCode() != http.StatusOK {
+				return &HTTPError{Message: "Failed to fetch document", StatusCode: bow.StatusCode()}
+			}
+			return nil
+
+		case model.GateEmail, model.GatePasscode:
+
+			// The auth form shown again means the email or passcode was
+			// rejected
+			if previous == model.GateEmail || previous == model.GatePasscode {
+				return ErrAuthenticationFailed
+			}
+			values[set.EmailField] = email
+			if gate == model.GatePasscode {
+				values[set.PasscodeField] = passcode
+			}
+			if set.NDAField != "" {
+				if checkbox := field(form, set.NDAField); checkbox.Length() > 0 {
+					values[set.NDAField] = checkboxValue(checkbox)
+				}
+			}
+
+		case model.GateNDA:
+			if previous == model.GateNDA {
+				return ErrAuthenticationFailed
+			}
+			values[set.NDAField] = checkboxValue(field(form, set.NDAField))
+			if field(form, set.EmailField).Length() > 0 {
+				values[set.EmailField] = email
+			}
+
+		case model.GateVerification:
+			if previous == model.GateVerification {
+				attempt++
+			}
+			if attempt >= MaxVerificationAttempts {
+				return ErrAuthenticationFailed
+			}
+			message := fmt.Sprintf("Enter the verification code DocSend emailed to %s", email)
+			if attempt > 0 {
+				message = fmt.Sprintf("The verification code wasn't accepted, enter the latest code DocSend emailed to %s", email)
+			}
+			code, err := challenge(model.Challenge{Gate: gate, Message: message, Attempt: attempt})
+			if err != nil {
+				return err
+			}
+			values[set.VerificationField] = strings.TrimSpace(code)
+		}
+
+		if err := submitForm(bow, form, values); err != nil {
+			return err
+		}
+		previous = gate
+	}
+	return ErrAuthenticationFailed
+}
+
+// Gate returns the gate shown on the page open in the Browser, or "" if the
+// document is shown
+func (a *DocSendAdapter) Gate(bow *browser.Browser) model.Gate {
+	gate, _, _ := a.gate(bow.Dom())
+	return gate
+}
+
+// gate finds the gate shown on the page, returning the rule set and form that
+// matched it.  The gate is "" once the document is shown
+func (a *DocSendAdapter) gate(dom *goquery.Selection) (model.Gate, RuleSet, *goquery.Selection) {
+	for _, set := range a.ruleSets() {
+		if set.VerificationForm != "" {
+			if form := dom.Find(set.VerificationForm).First(); form.Length() > 0 {
+				return model.GateVerification, set, form
+			}
+		}
+		if set.NDAForm != "" {
+			if form := dom.Find(set.NDAForm).First(); form.Length() > 0 {
+				return model.GateNDA, set, form
+			}
+		}
+		if set.AuthForm != "" {
+			if form := dom.Find(set.AuthForm).First(); form.Length() > 0 {
+				if field(form, set.PasscodeField).Length() > 0 {
+					return model.GatePasscode, set, form
+				}
+				return model.GateEmail, set, form
+			}
+		}
+	}
+	return "", RuleSet{}, nil
+}
+
+// Pages lists the page_data URL of each slide in the viewer, using the first
+// rule set whose selectors find any
+func (a *DocSendAdapter) Pages(bow *browser.Browser) ([]string, error) {
+	for _, set := range a.ruleSets() {
+		if urls := a.pages(bow, set); len(urls) > 0 {
+			return urls, nil
+		}
+	}
+	return nil, ErrNoRulesMatched
+}
+
+// MatchedRules returns the name of the rule set whose selectors find the
+// slides in the viewer, or "" if none do
+func (a *DocSendAdapter) MatchedRules(bow *browser.Browser) string {
+	for _, set := range a.ruleSets() {
+		if len(a.pages(bow, set)) > 0 {
+			return set.Name
+		}
+	}
+	return ""
+}
+
+// pages lists the page_data URLs found by the rule set's selectors
+func (a *DocSendAdapter) pages(bow *browser.Browser, set RuleSet) []string {
+	urls := make([]string, 0)
+	bow.Dom().Find(set.Page).Each(func(_ int, s *goquery.Selection) {
+		if url, ok := s.Attr(set.PageURLAttribute); ok && url != "" {
+			urls = append(urls, url)
+		}
+	})
+	return urls
+}
+
+// FetchPage fetches and decodes the page_data of a slide
+func (a *DocSendAdapter) FetchPage(get GetFunc, url string, index int) (*Page, error) {
+
+	rsp, err := get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, &HTTPError{Message: fmt.Sprintf("Failed to fetch page metadata for page: %d", index+1), StatusCode: rsp.StatusCode}
+	}
+
+	// Decode the response directly from the body, mapping its fields with the
+	// first rule set that finds an image URL in it
+	var data interface{}
+	if err := json.NewDecoder(rsp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	for _, set := range a.ruleSets() {
+		if page := a.page(data, set.PageData); page != nil {
+			return page, nil
+		}
+	}
+	return nil, &RulesError{Page: index + 1}
+}
+
+// page maps the decoded page_data to a Page, or returns nil if the rules find
+// no image URL in it
+func (a *DocSendAdapter) page(data interface{}, rules PageDataRules) *Page {
+	page := &Page{
+		ImageURL:       lookupString(data, rules.ImageURL),
+		DirectImageURL: lookupString(data, rules.DirectImageURL),
+		Links:          make([]Link, 0),
+	}
+	if page.ImageURL == "" {
+		return nil
+	}
+	links, _ := lookup(data, rules.Links).([]interface{})
+	for _, link := range links {
+		page.Links = append(page.Links, Link{
+			X:          lookupFloat(link, rules.Link.X),
+			Y:          lookupFloat(link, rules.Link.Y),
+			Width:      lookupFloat(link, rules.Link.Width),
+			Height:     lookupFloat(link, rules.Link.Height),
+			URI:        lookupString(link, rules.Link.URI),
+			TrackedURL: lookupString(link, rules.Link.TrackedURL),
+		})
+	}
+	return page
+}
+
+// IsSpace reports whether the URL links to a DocSend space rather than a
+// single document.  Space links are of the form /view/s/<id>
+func (a *DocSendAdapter) IsSpace(u *url.URL) bool {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return len(parts) == 3 && parts[0] == "view" && parts[1] == "s" && parts[2] != ""
+}
+
+// Documents lists the links to the documents within the space, in the order
+// they appear without duplicates
+func (a *DocSendAdapter) Documents(bow *browser.Browser, space *url.URL) ([]SpaceDocument, error) {
+	base := bow.Url()
+	if base == nil {
+		base = space
+	}
+	prefix := strings.TrimSuffix(space.Path, "/") + "/"
+
+	// Use the first rule set whose selector finds any documents
+	for _, set := range a.ruleSets() {
+		if set.SpaceDocument == "" {
+			continue
+		}
+		if docs := a.documents(bow, set.SpaceDocument, base, space.Host, prefix); len(docs) > 0 {
+			return docs, nil
+		}
+	}
+	return make([]SpaceDocument, 0), nil
+}
+
+// documents lists the links found by the selector to documents under the
+// space's path
+func (a *DocSendAdapter) documents(bow *browser.Browser, selector string, base *url.URL, host string, prefix string) []SpaceDocument {
+	docs := make([]SpaceDocument, 0)
+	seen := make(map[string]bool)
+	bow.Dom().Find(selector).Each(func(_ int, sel *goquery.Selection) {
+		href, ok := sel.Attr("href")
+		if !ok {
+			return
+		}
+		ref, err := url.Parse(href)
+		if err != nil {
+			return
+		}
+		link := base.ResolveReference(ref)
+		link.RawQuery = ""
+		link.Fragment = ""
+		if link.Host != host || !strings.HasPrefix(link.Path, prefix) || seen[link.Path] {
+			return
+		}
+		seen[link.Path] = true
+
+		title := strings.Join(strings.Fields(sel.Text()), " ")
+		if title == "" {
+			title, _ = sel.Attr("title")
+		}
+		if title == "" {
+			title = path.Base(link.Path)
+		}
+		docs = append(docs, SpaceDocument{Title: title, URL: link})
+	})
+	return docs
+}
@@ -0,0 +1,100 @@
+package scraper
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/scraper/fake"
+)
+
+func TestGate(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		gate model.Gate
+	}{
+		{"viewer", `<div class="item"><img class="page-view" data-url="/page_data/1"></div>`, ""},
+		{"email", `<form class="new_link_auth_form"><input name="link_auth_form[email]"></form>`, model.GateEmail},
+		{"passcode", `<form class="new_link_auth_form"><input name="link_auth_form[email]"><input name="link_auth_form[passcode]"></form>`, model.GatePasscode},
+		{"nda", `<form class="nda_acceptance_form"><input name="link_auth_form[accept_nda]"></form>`, model.GateNDA},
+		{"verification", `<form class="email_verification_form"><input name="email_verification_form[code]"></form>`, model.GateVerification},
+		{"verification before auth", `<form class="new_link_auth_form"></form><form class="email_verification_form"></form>`, model.GateVerification},
+		{"other form", `<form class="search"><input name="q"></form>`, ""},
+	}
+	adapter := &DocSendAdapter{}
+	for _, tt := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><body>" + tt.html + "</body></html>"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gate, _, _ := adapter.gate(doc.Selection); gate != tt.gate {
+			t.Errorf("%s: expected gate %q, got %q", tt.name, tt.gate, gate)
+		}
+	}
+}
+
+func TestInspectGate(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	open := fake.NewDocument("open", 2, "")
+	email := fake.NewDocument("email", 2, "")
+	email.EmailOnly = true
+	passcode := fake.NewDocument("passcode", 2, "secret")
+	nda := fake.NewDocument("nda", 2, "")
+	nda.EmailOnly = true
+	nda.NDA = true
+	verification := fake.NewDocument("verification", 2, "secret")
+	verification.VerificationCode = "123456"
+	for _, doc := range []fake.Document{open, email, passcode, nda, verification} {
+		server.AddDocument(doc)
+	}
+
+	tests := []struct {
+		name  string
+		slug  string
+		email string
+		gate  model.Gate
+		pages int
+	}{
+		{"open", "open", "", model.GateNone, 2},
+		{"email", "email", "", model.GateEmail, 0},
+		{"email passed", "email", "a@example.com", model.GateEmail, 2},
+		{"passcode", "passcode", "", model.GatePasscode, 0},
+		{"passcode passed", "passcode", "a@example.com", model.GatePasscode, 2},
+		{"nda passed", "nda", "a@example.com", model.GateEmail, 2},
+		{"verification", "verification", "", model.GatePasscode, 0},
+		{"verification reached", "verification", "a@example.com", model.GateVerification, 0},
+	}
+	for _, tt := range tests {
+		s, _ := newTestScraper(server)
+		inspection, err := s.Inspect(context.Background(), server.DocumentURL(tt.slug), tt.email, "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inspection.Gate != tt.gate {
+			t.Errorf("%s: expected gate %q, got %q", tt.name, tt.gate, inspection.Gate)
+		}
+		if inspection.Error != "" {
+			t.Errorf("%s: expected no error, got %s", tt.name, inspection.Error)
+		}
+		pages := 0
+		if inspection.Pages != nil {
+			pages = *inspection.Pages
+		}
+		if pages != tt.pages {
+			t.Errorf("%s: expected %d pages counted, got %d", tt.name, tt.pages, pages)
+		}
+	}
+	for _, slug := range []string{"open", "email", "passcode", "nda", "verification"} {
+		if n := server.Requests("/view/" + slug + "/page_data/"); n != 0 {
+			t.Errorf("%s: expected no page data fetched by inspecting, got %d requests", slug, n)
+		}
+	}
+	if n := server.Requests("/images/"); n != 0 {
+		t.Errorf("expected no images fetched by inspecting, got %d requests", n)
+	}
+}
@@ -0,0 +1,112 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// ErrAuthenticationFailed is returned when the site rejects the supplied email
+// and passcode
+var ErrAuthenticationFailed = errors.New("Authentication failed")
+
+// ErrUnsupportedSite is returned when no SiteAdapter handles the URL
+var ErrUnsupportedSite = errors.New("No site adapter handles the URL")
+
+// ErrNoRulesMatched is returned when none of the extraction rules match the
+// document, most likely as the site's markup has changed
+var ErrNoRulesMatched = errors.New("None of the extraction rules match the document")
+
+// ErrInputRequired is returned when a gate needs input from the user, such as
+// a verification code, and the Scraper has no ChallengeHandler to ask for it
+var ErrInputRequired = errors.New("The document needs input from the user, such as a verification code")
+
+// ErrUnchanged is returned when the pages match those of the previous version
+// of the document
+var ErrUnchanged = errors.New("Document unchanged")
+
+// HTTPError is returned when the site responds with an unexpected status
+type HTTPError struct {
+	Message    string
+	StatusCode int
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", e.Message, e.StatusCode)
+}
+
+// Temporary reports whether the request may succeed if retried
+func (e *HTTPError) Temporary() bool {
+	return e.StatusCode >= http.StatusInternalServerError ||
+		e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode == http.StatusRequestTimeout
+}
+
+// Revoked reports whether the error shows the link no longer grants access,
+// either because it was removed or disabled or because the email and passcode
+// it was captured with are rejected
+func Revoked(err error) bool {
+	if err == ErrAuthenticationFailed {
+		return true
+	}
+	if e, ok := err.(*HTTPError); ok {
+		return e.StatusCode == http.StatusForbidden ||
+			e.StatusCode == http.StatusNotFound ||
+			e.StatusCode == http.StatusGone
+	}
+	return false
+}
+
+// RulesError is returned when none of the extraction rules match the page
+// data of a page, most likely as the site's page data has changed.  Like
+// ErrNoRulesMatched it isn't fixed by retrying
+type RulesError struct {
+	Page int
+}
+
+func (e *RulesError) Error() string {
+	return fmt.Sprintf("None of the extraction rules match the page metadata for page: %d", e.Page)
+}
+
+// ImageError is returned when a page image can't be decoded or is in a format
+// that isn't supported
+type ImageError struct {
+	Page        int
+	Message     string
+	Unsupported bool
+}
+
+func (e *ImageError) Error() string {
+	return fmt.Sprintf("Page %d: %s", e.Page, e.Message)
+}
+
+// Temporary reports whether downloading the image again may help.  An image
+// that failed to decode may have been truncated, an unsupported one won't
+// change
+func (e *ImageError) Temporary() bool {
+	return !e.Unsupported
+}
+
+// temporary reports whether the supplied error may not recur if retried.
+// Only temporary HTTP and image errors, failed connections, network errors
+// that time out or are temporary and bodies cut short are retried, anything
+// else is assumed to be permanent
+func temporary(err error) bool {
+	if e, ok := err.(*url.Error); ok {
+		err = e.Err
+	}
+	switch e := err.(type) {
+	case *HTTPError:
+		return e.Temporary()
+	case *ImageError:
+		return e.Temporary()
+	case *net.OpError:
+		return true
+	case net.Error:
+		return e.Temporary() || e.Timeout()
+	}
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
@@ -0,0 +1,48 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+)
+
+// timeoutError is a net.Error that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return false }
+
+func TestTemporary(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		temporary bool
+	}{
+		{"server error", &HTTPError{StatusCode: http.StatusBadGateway}, true},
+		{"rate limited", &HTTPError{StatusCode: http.StatusTooManyRequests}, true},
+		{"not found", &HTTPError{StatusCode: http.StatusNotFound}, false},
+		{"truncated image", &ImageError{Message: "unexpected EOF"}, true},
+		{"unsupported image", &ImageError{Message: "unsupported", Unsupported: true}, false},
+		{"timeout", timeoutError{}, true},
+		{"request timeout", &url.Error{Op: "Get", URL: "https://docsend.com", Err: timeoutError{}}, true},
+		{"connection reset", &url.Error{Op: "Get", URL: "https://docsend.com", Err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}}, true},
+		{"connection closed", &url.Error{Op: "Get", URL: "https://docsend.com", Err: io.EOF}, true},
+		{"body cut short", io.ErrUnexpectedEOF, true},
+		{"cancelled", &url.Error{Op: "Get", URL: "https://docsend.com", Err: context.Canceled}, false},
+		{"authentication", ErrAuthenticationFailed, false},
+		{"no rules", ErrNoRulesMatched, false},
+		{"no page rules", &RulesError{Page: 1}, false},
+		{"unknown", errors.New("invalid character '<' looking for beginning of value"), false},
+	}
+	for _, tt := range tests {
+		if temporary := temporary(tt.err); temporary != tt.temporary {
+			t.Errorf("%s: expected temporary %t, got %t", tt.name, tt.temporary, temporary)
+		}
+	}
+}
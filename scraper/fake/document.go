@@ -0,0 +1,47 @@
+package fake
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+// PNG returns a PNG image of the supplied size filled with the color
+func PNG(width int, height int, c color.Color) []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, filled(width, height, c))
+	return buf.Bytes()
+}
+
+// JPEG returns a JPEG image of the supplied size filled with the color
+func JPEG(width int, height int, c color.Color) []byte {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, filled(width, height, c), &jpeg.Options{Quality: 90})
+	return buf.Bytes()
+}
+
+func filled(width int, height int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: c}, image.Point{}, draw.Src)
+	return img
+}
+
+// NewDocument returns a document of n landscape pages, each a PNG of a
+// different color so their hashes differ.  An empty passcode leaves the
+// document ungated
+func NewDocument(slug string, n int, passcode string) Document {
+	doc := Document{
+		Slug:     slug,
+		Title:    fmt.Sprintf("Deck %s", slug),
+		Passcode: passcode,
+		Pages:    make([][]byte, n),
+	}
+	for i := range doc.Pages {
+		doc.Pages[i] = PNG(160, 90, color.RGBA{R: uint8(i * 37), G: uint8(255 - i*23), B: uint8(i * 11), A: 255})
+	}
+	return doc
+}
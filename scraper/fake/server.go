@@ -0,0 +1,551 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Host is the host the fake server answers for.  The links to its documents
+// are on this host and its Client routes them to the server
+const Host = "docsend.com"
+
+// The cookie marking a session as authenticated for a document or space
+const authCookie = "fake_link_auth"
+
+// Document is a deck served by the fake server
+type Document struct {
+
+	// The slug the document is viewed at, /view/<slug>
+	Slug string
+
+	// The title of the viewer page
+	Title string
+
+	// The passcode the auth form asks for.  If empty, and no other gate is
+	// set, the document isn't gated and the viewer is shown straight away
+	Passcode string
+
+	// Whether the auth form asks for only an email address
+	EmailOnly bool
+
+	// Whether an NDA must be accepted once past the auth form
+	NDA bool
+
+	// The code the viewer must enter to verify their email address, asked
+	// for once past the other gates.  Empty skips the gate
+	VerificationCode string
+
+	// The image of each page in order
+	Pages [][]byte
+
+	// The links placed on each page, indexed like Pages
+	Links [][]Link
+}
+
+// Link is a link placed on a page, positioned as a fraction of the page
+type Link struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	URI    string  `json:"uri"`
+}
+
+// Space is a folder of documents served behind one auth form, viewed at
+// /view/s/<slug> with its documents at /view/s/<slug>/d/<document slug>
+type Space struct {
+	Slug      string
+	Title     string
+	Passcode  string
+	Documents []string
+}
+
+// Failure makes the server misbehave for the requests it matches
+type Failure struct {
+
+	// The prefix of the paths the failure applies to, such as /images/ or
+	// /view/<slug>/page_data/.  Empty matches every path
+	Path string
+
+	// The number of requests that fail before the server behaves again.
+	// Zero fails every matching request
+	Times int
+
+	// The status to respond with instead of the usual response.  Zero keeps
+	// the usual response
+	Status int
+
+	// How long to stall after sending the headers before sending the body
+	Delay time.Duration
+
+	// Whether the response is sent without a Content-Type
+	NoContentType bool
+}
+
+// Server is an offline stand-in for DocSend running on an httptest server.  It
+// serves the auth form, the viewer, the page_data of each page and the page
+// images of the documents added to it
+type Server struct {
+	*httptest.Server
+
+	mutex     sync.Mutex
+	documents map[string]*Document
+	spaces    map[string]*Space
+	failures  []*Failure
+	requests  []string
+}
+
+// NewServer starts a new fake server with no documents.  It should be closed
+// once finished with
+func NewServer() *Server {
+	s := new(Server)
+	s.documents = make(map[string]*Document)
+	s.spaces = make(map[string]*Space)
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// AddDocument adds a document to the server, replacing any at the same slug
+func (s *Server) AddDocument(doc Document) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.documents[doc.Slug] = &doc
+}
+
+// AddSpace adds a space to the server.  Its documents must be added with
+// AddDocument, they're only gated by the space's passcode
+func (s *Server) AddSpace(space Space) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.spaces[space.Slug] = &space
+}
+
+// Fail makes the server fail the requests matching the failure
+func (s *Server) Fail(failure Failure) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures = append(s.failures, &failure)
+}
+
+// Reset removes every failure and forgets the requests made
+func (s *Server) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures = nil
+	s.requests = nil
+}
+
+// Requests returns the number of requests made for paths starting with the
+// prefix
+func (s *Server) Requests(prefix string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n := 0
+	for _, path := range s.requests {
+		if strings.HasPrefix(path, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// DocumentURL returns the link to the document with the supplied slug
+func (s *Server) DocumentURL(slug string) *url.URL {
+	return &url.URL{Scheme: "https", Host: Host, Path: "/view/" + slug}
+}
+
+// SpaceURL returns the link to the space with the supplied slug
+func (s *Server) SpaceURL(slug string) *url.URL {
+	return &url.URL{Scheme: "https", Host: Host, Path: "/view/s/" + slug}
+}
+
+// Client returns a client whose requests are all sent to the server, whatever
+// host they're for
+func (s *Server) Client() *http.Client {
+	return &http.Client{Transport: s.Transport()}
+}
+
+// Transport returns an http.RoundTripper sending every request to the server.
+// The server sees the host and scheme the request was made for
+func (s *Server) Transport() http.RoundTripper {
+	target, _ := url.Parse(s.URL)
+	return &routeTransport{target: target, base: s.Server.Client().Transport}
+}
+
+// routeTransport sends each request to the target server
+type routeTransport struct {
+	target *url.URL
+	base   http.RoundTripper
+}
+
+func (t *routeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	routed := new(http.Request)
+	*routed = *req
+	u := *req.URL
+	routed.URL = &u
+	routed.Host = req.URL.Host
+	routed.URL.Scheme = t.target.Scheme
+	routed.URL.Host = t.target.Host
+
+	routed.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		routed.Header[k] = v
+	}
+	routed.Header.Set("X-Forwarded-Proto", req.URL.Scheme)
+
+	// The response is to the request as it was made, so redirects and
+	// relative links resolve against the host it was made for
+	rsp, err := t.base.RoundTrip(routed)
+	if err != nil {
+		return nil, err
+	}
+	rsp.Request = req
+	return rsp, nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	failure := s.record(r.URL.Path)
+	if failure != nil && failure.Status != 0 {
+		s.respond(w, r, failure, failure.Status, "text/plain", []byte(http.StatusText(failure.Status)))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 3 && parts[0] == "view" && parts[1] == "s":
+		s.serveSpace(w, r, failure, parts[2])
+	case len(parts) == 5 && parts[0] == "view" && parts[1] == "s" && parts[3] == "d":
+		s.serveViewer(w, r, failure, parts[4], parts[2])
+	case len(parts) == 2 && parts[0] == "view":
+		s.serveViewer(w, r, failure, parts[1], "")
+	case len(parts) == 4 && parts[0] == "view" && parts[2] == "page_data":
+		s.servePageData(w, r, failure, parts[1], parts[3])
+	case len(parts) == 3 && parts[0] == "images":
+		s.serveImage(w, r, failure, parts[1], parts[2])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// record logs the request, returning the failure it should suffer if any
+func (s *Server) record(path string) *Failure {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.requests = append(s.requests, path)
+	for i, failure := range s.failures {
+		if !strings.HasPrefix(path, failure.Path) {
+			continue
+		}
+		if failure.Times > 0 {
+			failure.Times--
+			if failure.Times == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+		f := *failure
+		return &f
+	}
+	return nil
+}
+
+// respond writes the response, misbehaving as the failure asks
+func (s *Server) respond(w http.ResponseWriter, r *http.Request, failure *Failure, status int, contentType string, body []byte) {
+	if failure != nil && failure.NoContentType {
+		w.Header()["Content-Type"] = nil
+	} else {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+
+	if failure != nil && failure.Delay > 0 {
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		select {
+		case <-time.After(failure.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	w.Write(body)
+}
+
+// serveSpace serves the auth form or the list of documents in a space
+func (s *Server) serveSpace(w http.ResponseWriter, r *http.Request, failure *Failure, slug string) {
+	s.mutex.Lock()
+	space, ok := s.spaces[slug]
+	s.mutex.Unlock()
+	if !ok {
+		s.respond(w, r, failure, http.StatusNotFound, "text/html", []byte("Not found"))
+		return
+	}
+
+	if !s.authenticate(w, r, "s-"+slug, space.Passcode) {
+		s.render(w, r, failure, authTemplate, map[string]interface{}{"Title": space.Title, "Action": r.URL.Path, "Passcode": true})
+		return
+	}
+
+	s.mutex.Lock()
+	docs := make([]map[string]string, 0, len(space.Documents))
+	for _, docSlug := range space.Documents {
+		title := docSlug
+		if doc, ok := s.documents[docSlug]; ok && doc.Title != "" {
+			title = doc.Title
+		}
+		docs = append(docs, map[string]string{"Title": title, "URL": fmt.Sprintf("/view/s/%s/d/%s", slug, docSlug)})
+	}
+	s.mutex.Unlock()
+	s.render(w, r, failure, spaceTemplate, map[string]interface{}{"Title": space.Title, "Documents": docs})
+}
+
+// serveViewer serves the auth form or the viewer of a document.  Documents
+// in a space are gated by the space's passcode
+func (s *Server) serveViewer(w http.ResponseWriter, r *http.Request, failure *Failure, slug string, spaceSlug string) {
+	s.mutex.Lock()
+	doc, ok := s.documents[slug]
+	var space *Space
+	if spaceSlug != "" {
+		space = s.spaces[spaceSlug]
+		ok = ok && space != nil
+	}
+	s.mutex.Unlock()
+	if !ok {
+		s.respond(w, r, failure, http.StatusNotFound, "text/html", []byte("Not found"))
+		return
+	}
+
+	if space != nil {
+		if !s.authenticate(w, r, "s-"+spaceSlug, space.Passcode) {
+			s.render(w, r, failure, authTemplate, map[string]interface{}{"Title": doc.Title, "Action": r.URL.Path, "Passcode": true})
+			return
+		}
+	} else if gate := s.pass(w, r, doc); gate != nil {
+		s.render(w, r, failure, gate, map[string]interface{}{"Title": doc.Title, "Action": r.URL.Path, "Passcode": !doc.EmailOnly})
+		return
+	}
+
+	pages := make([]string, len(doc.Pages))
+	for i := range doc.Pages {
+		pages[i] = fmt.Sprintf("/view/%s/page_data/%d", slug, i+1)
+	}
+	s.render(w, r, failure, viewerTemplate, map[string]interface{}{"Title": doc.Title, "Pages": pages})
+}
+
+// pass takes the session through the gates of the document, the auth form,
+// the NDA and the verification code in that order.  Each posted form that
+// passes its gate is recorded in the session.  Returns the template of the
+// first gate not yet passed, or nil once they all are
+func (s *Server) pass(w http.ResponseWriter, r *http.Request, doc *Document) *template.Template {
+	realm := "d-" + doc.Slug
+	if granted(r, realm) {
+		return nil
+	}
+
+	gates := []struct {
+		name     string
+		enabled  bool
+		template *template.Template
+		passed   func() bool
+	}{
+		{"auth", doc.Passcode != "" || doc.EmailOnly, authTemplate, func() bool {
+			return r.PostFormValue("link_auth_form[email]") != "" &&
+				(doc.EmailOnly || r.PostFormValue("link_auth_form[passcode]") == doc.Passcode)
+		}},
+		{"nda", doc.NDA, ndaTemplate, func() bool {
+			r.ParseForm()
+			for _, value := range r.PostForm["link_auth_form[accept_nda]"] {
+				if value == "1" {
+					return true
+				}
+			}
+			return false
+		}},
+		{"verification", doc.VerificationCode != "", verificationTemplate, func() bool {
+			return r.PostFormValue("email_verification_form[code]") == doc.VerificationCode
+		}},
+	}
+
+	// Only the first gate not yet passed may be passed by the posted form
+	passed := make([]string, 0)
+	for _, gate := range gates {
+		if !gate.enabled || granted(r, realm+":"+gate.name) {
+			continue
+		}
+		if r.Method == http.MethodPost && len(passed) == 0 && gate.passed() {
+			passed = append(passed, realm+":"+gate.name)
+			continue
+		}
+		s.grant(w, r, passed...)
+		return gate.template
+	}
+	s.grant(w, r, append(passed, realm)...)
+	return nil
+}
+
+// gated reports whether the document is behind any gate
+func (d *Document) gated() bool {
+	return d.Passcode != "" || d.EmailOnly || d.NDA || d.VerificationCode != ""
+}
+
+// authenticate reports whether the session may view the realm.  A posted
+// auth form with the right passcode authenticates the session
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request, realm string, passcode string) bool {
+	if passcode == "" {
+		return true
+	}
+	if r.Method == http.MethodPost {
+		if r.PostFormValue("link_auth_form[email]") != "" && r.PostFormValue("link_auth_form[passcode]") == passcode {
+			s.grant(w, r, realm)
+			return true
+		}
+		return false
+	}
+	return granted(r, realm)
+}
+
+// grant marks the session as authenticated for the realms
+func (s *Server) grant(w http.ResponseWriter, r *http.Request, realms ...string) {
+	if len(realms) == 0 {
+		return
+	}
+	if cookie, err := r.Cookie(authCookie); err == nil {
+		realms = append(realms, strings.Split(cookie.Value, "|")...)
+	}
+	http.SetCookie(w, &http.Cookie{Name: authCookie, Value: strings.Join(realms, "|"), Path: "/"})
+}
+
+// granted reports whether the session has been authenticated for the realm
+func granted(r *http.Request, realm string) bool {
+	cookie, err := r.Cookie(authCookie)
+	if err != nil {
+		return false
+	}
+	for _, value := range strings.Split(cookie.Value, "|") {
+		if value == realm {
+			return true
+		}
+	}
+	return false
+}
+
+// servePageData serves the page_data JSON of a page, which requires an
+// authenticated session if the document is gated
+func (s *Server) servePageData(w http.ResponseWriter, r *http.Request, failure *Failure, slug string, number string) {
+	s.mutex.Lock()
+	doc, ok := s.documents[slug]
+	allowed := ok && (!doc.gated() || granted(r, "d-"+slug))
+	for _, space := range s.spaces {
+		for _, docSlug := range space.Documents {
+			if docSlug == slug && (space.Passcode == "" || granted(r, "s-"+space.Slug)) {
+				allowed = true
+			}
+		}
+	}
+	s.mutex.Unlock()
+
+	n, err := strconv.Atoi(number)
+	if !ok || err != nil || n < 1 || n > len(doc.Pages) {
+		s.respond(w, r, failure, http.StatusNotFound, "application/json", []byte(`{"error":"not found"}`))
+		return
+	}
+	if !allowed {
+		s.respond(w, r, failure, http.StatusForbidden, "application/json", []byte(`{"error":"forbidden"}`))
+		return
+	}
+
+	data := map[string]interface{}{
+		"imageUrl":       s.absolute(r, fmt.Sprintf("/images/%s/%d", slug, n)),
+		"directImageUrl": s.absolute(r, fmt.Sprintf("/images/%s/%d", slug, n)),
+		"documentLinks":  []Link{},
+	}
+	if n <= len(doc.Links) && doc.Links[n-1] != nil {
+		data["documentLinks"] = doc.Links[n-1]
+	}
+	body, _ := json.Marshal(data)
+	s.respond(w, r, failure, http.StatusOK, "application/json", body)
+}
+
+// serveImage serves the image of a page
+func (s *Server) serveImage(w http.ResponseWriter, r *http.Request, failure *Failure, slug string, number string) {
+	s.mutex.Lock()
+	doc, ok := s.documents[slug]
+	s.mutex.Unlock()
+
+	n, err := strconv.Atoi(number)
+	if !ok || err != nil || n < 1 || n > len(doc.Pages) {
+		s.respond(w, r, failure, http.StatusNotFound, "text/plain", []byte("Not found"))
+		return
+	}
+	data := doc.Pages[n-1]
+	s.respond(w, r, failure, http.StatusOK, http.DetectContentType(data), data)
+}
+
+// absolute makes the path absolute on the host and scheme the request was
+// made for
+func (s *Server) absolute(r *http.Request, path string) string {
+	scheme := r.Header.Get("X-Forwarded-Proto")
+	if scheme == "" {
+		scheme = "http"
+	}
+	return scheme + "://" + r.Host + path
+}
+
+// render writes the template as an HTML page
+func (s *Server) render(w http.ResponseWriter, r *http.Request, failure *Failure, t *template.Template, data interface{}) {
+	var body bytes.Buffer
+	if err := t.Execute(&body, data); err != nil {
+		s.respond(w, r, failure, http.StatusInternalServerError, "text/plain", []byte(err.Error()))
+		return
+	}
+	s.respond(w, r, failure, http.StatusOK, "text/html; charset=utf-8", body.Bytes())
+}
+
+var authTemplate = template.Must(template.New("auth").Parse(`<!DOCTYPE html>
+<html><head><title>{{.Title}}</title></head><body>
+<form class="new_link_auth_form" action="{{.Action}}" method="post">
+<input type="email" name="link_auth_form[email]">
+{{if .Passcode}}<input type="password" name="link_auth_form[passcode]">
+{{end}}<input type="submit" value="Continue">
+</form>
+</body></html>`))
+
+var ndaTemplate = template.Must(template.New("nda").Parse(`<!DOCTYPE html>
+<html><head><title>{{.Title}}</title></head><body>
+<form class="nda_acceptance_form" action="{{.Action}}" method="post">
+<p>This document is confidential.</p>
+<input type="hidden" name="link_auth_form[accept_nda]" value="0">
+<input type="checkbox" name="link_auth_form[accept_nda]" value="1">
+<input type="submit" value="Accept">
+</form>
+</body></html>`))
+
+var verificationTemplate = template.Must(template.New("verification").Parse(`<!DOCTYPE html>
+<html><head><title>{{.Title}}</title></head><body>
+<form class="email_verification_form" action="{{.Action}}" method="post">
+<p>Enter the code we emailed you.</p>
+<input type="text" name="email_verification_form[code]">
+<input type="submit" value="Verify">
+</form>
+</body></html>`))
+
+var viewerTemplate = template.Must(template.New("viewer").Parse(`<!DOCTYPE html>
+<html><head><title>{{.Title}}</title></head><body>
+{{range .Pages}}<div class="item"><img class="page-view" data-url="{{.}}"></div>
+{{end}}</body></html>`))
+
+var spaceTemplate = template.Must(template.New("space").Parse(`<!DOCTYPE html>
+<html><head><title>{{.Title}}</title></head><body>
+<ul>{{range .Documents}}<li><a href="{{.URL}}">{{.Title}}</a></li>
+{{end}}</ul>
+</body></html>`))
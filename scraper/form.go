@@ -0,0 +1,87 @@
+package scraper
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/headzoo/surf/browser"
+)
+
+// submitForm submits the form shown in the Browser as a browser would, with
+// the values of its fields replaced or added by those supplied.  Unchecked
+// checkboxes and radio buttons are left out, as are buttons
+func submitForm(bow *browser.Browser, form *goquery.Selection, values map[string]string) error {
+	fields := formValues(form, values)
+
+	// Resolve the action against the page the form is on
+	base := bow.Url()
+	if base == nil {
+		return fmt.Errorf("Failed to submit form, no page is open")
+	}
+	action, err := url.Parse(form.AttrOr("action", ""))
+	if err != nil {
+		return err
+	}
+	target := base.ResolveReference(action)
+
+	if strings.ToUpper(form.AttrOr("method", "GET")) == "POST" {
+		return bow.PostForm(target.String(), fields)
+	}
+	target.RawQuery = fields.Encode()
+	return bow.Open(target.String())
+}
+
+// formValues returns the values a browser would submit for the form, with
+// those supplied replacing or adding to them
+func formValues(form *goquery.Selection, values map[string]string) url.Values {
+	fields := make(url.Values)
+	form.Find("input[name], textarea[name], select[name]").Each(func(_ int, s *goquery.Selection) {
+		name, _ := s.Attr("name")
+		switch {
+		case s.Is("textarea"):
+			fields.Add(name, s.Text())
+		case s.Is("select"):
+			option := s.Find("option[selected]").First()
+			if option.Length() == 0 {
+				option = s.Find("option").First()
+			}
+			value, ok := option.Attr("value")
+			if !ok {
+				value = option.Text()
+			}
+			fields.Add(name, value)
+		default:
+			switch strings.ToLower(s.AttrOr("type", "text")) {
+			case "submit", "button", "image", "reset", "file":
+				return
+			case "checkbox", "radio":
+				if _, checked := s.Attr("checked"); !checked {
+					return
+				}
+			}
+			fields.Add(name, s.AttrOr("value", ""))
+		}
+	})
+	for name, value := range values {
+		fields.Set(name, value)
+	}
+	return fields
+}
+
+// field finds the field of the form with the supplied name
+func field(form *goquery.Selection, name string) *goquery.Selection {
+	return form.Find(fmt.Sprintf("[name=%q]", name))
+}
+
+// checkboxValue returns the value submitted for the checkbox when checked
+func checkboxValue(checkbox *goquery.Selection) string {
+	for i := range checkbox.Nodes {
+		box := checkbox.Eq(i)
+		if strings.ToLower(box.AttrOr("type", "")) == "checkbox" {
+			return box.AttrOr("value", "on")
+		}
+	}
+	return "on"
+}
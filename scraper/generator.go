@@ -1,22 +1,32 @@
 package scraper
 
 import (
+	"bytes"
 	"fmt"
+	"image"
 	"net/http"
 
+	"github.com/aldelucca1/docsend_scraper/model"
 	"github.com/jung-kurt/gofpdf"
 	logger "github.com/sirupsen/logrus"
 )
 
-// Generate a PDF with the given set of Pages
-func (s *Scraper) Generate(pages []*Page) (*gofpdf.Fpdf, error) {
+// Generate a PDF with the given set of Pages, reading each page image from the
+// cache.  The document properties are set from the Metadata and each page is
+// bookmarked
+func (s *Scraper) Generate(meta Metadata, pages []*Page) (*gofpdf.Fpdf, error) {
 
 	// Update the status
 	s.StatusHandler("Generating the PDF document")
 
 	// Create our PDF container
-	pdf := gofpdf.New("L", "pt", "A4", "")
-	pdf.SetMargins(0, 0, 0)
+	pdf := newPDF(meta)
+
+	if s.Options.Cover && len(pages) > 0 {
+		if err := s.addCover(pdf, meta, len(pages)); err != nil {
+			return nil, err
+		}
+	}
 
 	// Add each page
 	for i, page := range pages {
@@ -25,35 +35,137 @@ func (s *Scraper) Generate(pages []*Page) (*gofpdf.Fpdf, error) {
 		if err != nil {
 			return nil, err
 		}
+		pdf.Bookmark(fmt.Sprintf("Slide %d", i+1), 0, 0)
+		s.ProgressHandler(model.Progress{Phase: model.PhaseGenerating, PagesDone: i + 1, PagesTotal: len(pages)})
 	}
 	return pdf, nil
 }
 
-func (s *Scraper) addPage(pdf *gofpdf.Fpdf, page *Page, index int) error {
+// newPDF creates an empty PDF whose properties record where the document came
+// from
+func newPDF(meta Metadata) *gofpdf.Fpdf {
 
-	width, height := pdf.GetPageSize()
+	// Each page is sized as it's added
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
 
-	// Attempt to download the image
-	rsp, err := http.Get(page.ImageURL)
+	source := meta.Source.String()
+	pdf.SetTitle(meta.Title, true)
+	pdf.SetSubject(source, true)
+	pdf.SetKeywords(source, true)
+	pdf.SetAuthor(meta.Author, true)
+	pdf.SetCreator("docsend_scraper", false)
+	pdf.SetCreationDate(meta.Captured)
+	pdf.SetModificationDate(meta.Captured)
+	return pdf
+}
+
+// addPage adds a page to the PDF for the page at index, sized by the layout
+func (s *Scraper) addPage(pdf *gofpdf.Fpdf, page *Page, index int) error {
+
+	// Read the downloaded image
+	data, config, err := s.pageImage(index)
 	if err != nil {
 		return err
 	}
-	if rsp.StatusCode != http.StatusOK {
-		return fmt.Errorf("Failed to fetch image for page: %d", index+1)
-	}
-	defer rsp.Body.Close()
 
 	// Add the Page to the PDF
-	pdf.AddPage()
+	size, r := s.Options.pageLayout(config.Width, config.Height)
+	pdf.AddPageFormat("P", size)
 
 	// Add the image
-	contentType := pdf.ImageTypeFromMime(rsp.Header["Content-Type"][0])
-	pdf.RegisterImageReader(page.ImageURL, contentType, rsp.Body)
-	pdf.Image(page.ImageURL, 0, 0, width, height, false, contentType, 0, "")
+	contentType := pdf.ImageTypeFromMime(http.DetectContentType(data))
+	pdf.RegisterImageReader(page.ImageURL, contentType, bytes.NewReader(data))
+	pdf.Image(page.ImageURL, r.x, r.y, r.width, r.height, false, contentType, 0, "")
 
-	// Add each Link
+	// Add each Link, positioned relative to the image
 	for _, link := range page.Links {
-		pdf.LinkString(width*link.X, height*link.Y, width*link.Width, height*link.Height, link.URI)
+		pdf.LinkString(r.x+r.width*link.X, r.y+r.height*link.Y, r.width*link.Width, r.height*link.Height, link.URI)
 	}
 	return nil
 }
+
+// Paper sizes in points, portrait
+var (
+	sizeA4     = gofpdf.SizeType{Wd: 595.28, Ht: 841.89}
+	sizeLetter = gofpdf.SizeType{Wd: 612, Ht: 792}
+)
+
+// rect is a rectangle on a page in points
+type rect struct {
+	x, y, width, height float64
+}
+
+// pageLayout returns the size of the page for an image of the supplied pixel
+// dimensions and the rectangle the image is drawn in
+func (o Options) pageLayout(width int, height int) (gofpdf.SizeType, rect) {
+	w, h := float64(width), float64(height)
+
+	var paper gofpdf.SizeType
+	switch o.Layout {
+	case model.LayoutA4:
+		paper = sizeA4
+	case model.LayoutLetter:
+		paper = sizeLetter
+	default:
+		// Size the page to the image at the target resolution
+		dpi := o.DPI
+		if dpi <= 0 {
+			dpi = DefaultDPI
+		}
+		scale := 72 / float64(dpi)
+		return gofpdf.SizeType{Wd: w * scale, Ht: h * scale}, rect{0, 0, w * scale, h * scale}
+	}
+
+	// Turn the paper to match the image's orientation
+	if w > h {
+		paper.Wd, paper.Ht = paper.Ht, paper.Wd
+	}
+	return paper, fit(paper, w, h)
+}
+
+// fit scales an image of the supplied dimensions to fit the page, centering it
+func fit(page gofpdf.SizeType, width float64, height float64) rect {
+	scale := page.Wd / width
+	if s := page.Ht / height; s < scale {
+		scale = s
+	}
+	return rect{
+		x:      (page.Wd - width*scale) / 2,
+		y:      (page.Ht - height*scale) / 2,
+		width:  width * scale,
+		height: height * scale,
+	}
+}
+
+// pageImage reads the image for the page at index from the cache along with
+// the dimensions the page is laid out from.  Once the images are optimized
+// that's the optimized image and the dimensions it was downloaded with
+func (s *Scraper) pageImage(index int) ([]byte, image.Config, error) {
+	if s.originalSizes != nil {
+		data, err := s.readCache(s.optimizedKey(index))
+		return data, s.originalSizes[index], err
+	}
+
+	data, err := s.readCache(s.imageKey(index))
+	if err != nil {
+		return nil, image.Config{}, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, image.Config{}, &ImageError{Page: index + 1, Message: "Failed to decode image: " + err.Error()}
+	}
+	return data, config, nil
+}
+
+// imageExtension returns the file extension for the image data
+func imageExtension(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/png":
+		return "png"
+	case "image/gif":
+		return "gif"
+	}
+	return "jpg"
+}
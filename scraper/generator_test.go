@@ -0,0 +1,73 @@
+package scraper
+
+import (
+	"math"
+	"testing"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// near reports whether the two lengths in points are within rounding
+func near(a float64, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
+
+func TestPageLayout(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		width  int
+		height int
+		size   gofpdf.SizeType
+		r      rect
+	}{
+		{"native 72 dpi", Options{Layout: model.LayoutNative, DPI: 72}, 1024, 768,
+			gofpdf.SizeType{Wd: 1024, Ht: 768}, rect{0, 0, 1024, 768}},
+		{"native 144 dpi", Options{Layout: model.LayoutNative, DPI: 144}, 1024, 768,
+			gofpdf.SizeType{Wd: 512, Ht: 384}, rect{0, 0, 512, 384}},
+		{"native default dpi", Options{DPI: 0}, 1024, 768,
+			gofpdf.SizeType{Wd: 1024 * 72 / float64(DefaultDPI), Ht: 768 * 72 / float64(DefaultDPI)},
+			rect{0, 0, 1024 * 72 / float64(DefaultDPI), 768 * 72 / float64(DefaultDPI)}},
+		{"a4 landscape", Options{Layout: model.LayoutA4}, 1600, 900,
+			gofpdf.SizeType{Wd: 841.89, Ht: 595.28}, rect{0, 60.86, 841.89, 473.56}},
+		{"a4 portrait", Options{Layout: model.LayoutA4}, 900, 1600,
+			gofpdf.SizeType{Wd: 595.28, Ht: 841.89}, rect{60.86, 0, 473.56, 841.89}},
+		{"letter landscape", Options{Layout: model.LayoutLetter}, 1024, 768,
+			gofpdf.SizeType{Wd: 792, Ht: 612}, rect{0, 9, 792, 594}},
+		{"letter tall", Options{Layout: model.LayoutLetter}, 500, 1000,
+			gofpdf.SizeType{Wd: 612, Ht: 792}, rect{108, 0, 396, 792}},
+		{"square", Options{Layout: model.LayoutLetter}, 800, 800,
+			gofpdf.SizeType{Wd: 612, Ht: 792}, rect{0, 90, 612, 612}},
+	}
+	for _, tt := range tests {
+		size, r := tt.opts.pageLayout(tt.width, tt.height)
+		if !near(size.Wd, tt.size.Wd) || !near(size.Ht, tt.size.Ht) {
+			t.Errorf("%s: expected a %.2fx%.2f page, got %.2fx%.2f", tt.name, tt.size.Wd, tt.size.Ht, size.Wd, size.Ht)
+		}
+		if !near(r.x, tt.r.x) || !near(r.y, tt.r.y) || !near(r.width, tt.r.width) || !near(r.height, tt.r.height) {
+			t.Errorf("%s: expected the image at %+v, got %+v", tt.name, tt.r, r)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	page := gofpdf.SizeType{Wd: 200, Ht: 100}
+
+	tests := []struct {
+		name   string
+		width  float64
+		height float64
+		r      rect
+	}{
+		{"same aspect", 400, 200, rect{0, 0, 200, 100}},
+		{"wider", 400, 100, rect{0, 25, 200, 50}},
+		{"taller", 100, 100, rect{50, 0, 100, 100}},
+		{"smaller", 20, 10, rect{0, 0, 200, 100}},
+	}
+	for _, tt := range tests {
+		if r := fit(page, tt.width, tt.height); r != tt.r {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.r, r)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package scraper
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	// Register the decoders for the page image formats
+	_ "image/gif"
+
+	_ "github.com/gen2brain/avif"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// The quality lossy images are transcoded to JPEG at
+const transcodeQuality = 90
+
+// normalizeImage checks the image data for the page at index fully decodes and
+// returns it in a format every output can embed.  JPEG, GIF and 8-bit
+// non-interlaced PNG images are returned as they are, anything else is
+// transcoded to JPEG if it was lossy or PNG if not
+func normalizeImage(data []byte, index int) ([]byte, error) {
+
+	// Sniff the format from the image itself rather than trusting headers
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err == image.ErrFormat {
+		return nil, &ImageError{Page: index + 1, Message: "Unrecognised image format " + http.DetectContentType(data), Unsupported: true}
+	}
+	if err != nil {
+		return nil, &ImageError{Page: index + 1, Message: "Failed to decode " + format + " image: " + err.Error()}
+	}
+
+	switch format {
+	case "jpeg", "gif":
+		return data, nil
+	case "png":
+		if embeddablePNG(data) {
+			return data, nil
+		}
+	}
+	return transcode(img, format, index)
+}
+
+// transcode encodes the image as a JPEG if it was lossy, otherwise as an 8-bit
+// PNG.  AVIF images decode to RGBA but are almost always lossy, so those
+// without transparency are transcoded to JPEG too
+func transcode(img image.Image, format string, index int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	_, ycbcr := img.(*image.YCbCr)
+	switch {
+	case ycbcr || format == "avif" && opaque(img):
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: transcodeQuality})
+	case wide(img):
+		// Reduce to 8 bits per channel
+		rgba := image.NewNRGBA(img.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+		err = png.Encode(&buf, rgba)
+	default:
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, &ImageError{Page: index + 1, Message: "Failed to transcode image: " + err.Error()}
+	}
+	return buf.Bytes(), nil
+}
+
+// embeddablePNG reports whether the PNG is 8-bit or less and not interlaced,
+// from its IHDR chunk
+func embeddablePNG(data []byte) bool {
+	if len(data) < 29 {
+		return false
+	}
+	depth, interlace := data[24], data[28]
+	return depth <= 8 && interlace == 0
+}
+
+// wide reports whether the image has more than 8 bits per channel
+func wide(img image.Image) bool {
+	switch img.(type) {
+	case *image.RGBA64, *image.NRGBA64, *image.Gray16:
+		return true
+	}
+	return false
+}
+
+// opaque reports whether the image has no transparent pixels
+func opaque(img image.Image) bool {
+	o, ok := img.(interface {
+		Opaque() bool
+	})
+	return ok && o.Opaque()
+}
@@ -0,0 +1,60 @@
+package scraper
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/aldelucca1/docsend_scraper/scraper/fake"
+)
+
+func TestNormalizeImage(t *testing.T) {
+	readFile := func(name string) []byte {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	jpeg := fake.JPEG(160, 90, color.White)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+		err    bool
+	}{
+		{"jpeg", jpeg, "jpeg", false},
+		{"png", fake.PNG(160, 90, color.White), "png", false},
+		{"avif", readFile("page.avif"), "jpeg", false},
+		{"transparent avif", readFile("transparent.avif"), "png", false},
+		{"unrecognised", []byte("not an image at all"), "", true},
+	}
+	for _, tt := range tests {
+		data, err := normalizeImage(tt.data, 0)
+		if tt.err {
+			if imageErr, ok := err.(*ImageError); !ok || !imageErr.Unsupported {
+				t.Errorf("%s: expected an unsupported image error, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		config, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || format != tt.format {
+			t.Errorf("%s: expected a %s image, got %s: %v", tt.name, tt.format, format, err)
+			continue
+		}
+		if tt.name == "avif" && (config.Width != 64 || config.Height != 36) {
+			t.Errorf("%s: expected a 64x36 image, got %dx%d", tt.name, config.Width, config.Height)
+		}
+	}
+	if data, _ := normalizeImage(jpeg, 0); !bytes.Equal(data, jpeg) {
+		t.Error("expected a JPEG to be kept as it is")
+	}
+}
@@ -0,0 +1,164 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/headzoo/surf/browser"
+)
+
+// GateAdapter is implemented by a SiteAdapter that can tell which gate the
+// page open in the Browser puts in front of the document
+type GateAdapter interface {
+	SiteAdapter
+
+	// Gate returns the gate shown on the open page, or "" if the document
+	// itself is shown
+	Gate(bow *browser.Browser) model.Gate
+}
+
+// Inspection describes what opening a link found, without capturing it
+type Inspection struct {
+
+	// The URL inspected and the adapter that opened it
+	URL     string `json:"url"`
+	Adapter string `json:"adapter"`
+
+	// Whether the site answered and the HTTP status it answered with
+	Reachable  bool `json:"reachable"`
+	StatusCode int  `json:"status_code,omitempty"`
+
+	// The gate in front of the document, or verification if passing the
+	// first gate with the supplied email leads to one
+	Gate model.Gate `json:"gate,omitempty"`
+
+	// The title of the document or space
+	Title string `json:"title,omitempty"`
+
+	// Whether the link is to a space, and the number of pages of a document
+	// or of documents in a space.  Counts are only known once any gate has
+	// been passed
+	Space     bool `json:"space"`
+	Pages     *int `json:"pages,omitempty"`
+	Documents *int `json:"documents,omitempty"`
+
+	// The name of the extraction rule set that found the pages
+	Rules string `json:"rules,omitempty"`
+
+	// Whether the link looks expired or revoked
+	Revoked bool `json:"revoked"`
+
+	// Why the inspection stopped short, if it did
+	Error string `json:"error,omitempty"`
+}
+
+// errInspectionStopped stops authenticating an inspected link at a gate
+// needing the user's input rather than asking them for it
+var errInspectionStopped = errors.New("Inspection stopped at a gate needing input")
+
+// Inspect opens the link at the specified URL and reports whether it's
+// reachable, the gate in front of it, its title and page count and whether it
+// looks revoked.  Gates are passed only if an email is supplied, and never one
+// asking for a verification code.  No page metadata or images are downloaded.
+// Problems with the link are reported in the Inspection, an error is only
+// returned if no adapter handles the URL or the context is cancelled
+func (s *Scraper) Inspect(ctx context.Context, url *url.URL, email string, passcode string) (*Inspection, error) {
+	s.ctx = ctx
+	s.transport.ctx = ctx
+
+	inspection, err := s.inspect(url, email, passcode)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return inspection, err
+}
+
+func (s *Scraper) inspect(url *url.URL, email string, passcode string) (*Inspection, error) {
+	adapter, err := adapterFor(s.Options.Adapter, url)
+	if err != nil {
+		return nil, err
+	}
+	s.adapter = adapter
+
+	inspection := &Inspection{URL: url.String(), Adapter: adapter.Name()}
+	s.StatusHandler("Inspecting " + url.String())
+
+	// Open the link as it is, to see what's in front of the document
+	if err := s.bow.Open(url.String()); err != nil {
+		inspection.Error = err.Error()
+		return inspection, nil
+	}
+	inspection.Reachable = true
+	inspection.StatusCode = s.bow.StatusCode()
+	if inspection.StatusCode != http.StatusOK {
+		err := &HTTPError{Message: "Failed to fetch document", StatusCode: inspection.StatusCode}
+		inspection.Revoked = Revoked(err)
+		inspection.Error = err.Error()
+		return inspection, nil
+	}
+	inspection.Title = strings.TrimSpace(s.bow.Title())
+
+	inspection.Gate = model.GateNone
+	if gates, ok := adapter.(GateAdapter); ok {
+		if gate := gates.Gate(s.bow); gate != "" {
+			inspection.Gate = gate
+		}
+	}
+
+	// Pass the gate with the supplied email and passcode, stopping at a
+	// verification code.  Without an email there's no telling what's behind
+	// it
+	if inspection.Gate != model.GateNone {
+		if email == "" {
+			return inspection, nil
+		}
+		err := adapter.Authenticate(s.bow, url, email, passcode, func(challenge model.Challenge) (string, error) {
+			inspection.Gate = challenge.Gate
+			return "", errInspectionStopped
+		})
+		if err == errInspectionStopped {
+			return inspection, nil
+		}
+		if err != nil {
+			if e, ok := err.(*HTTPError); ok {
+				inspection.StatusCode = e.StatusCode
+				inspection.Revoked = Revoked(e)
+			}
+			inspection.Error = err.Error()
+			return inspection, nil
+		}
+		if title := strings.TrimSpace(s.bow.Title()); title != "" {
+			inspection.Title = title
+		}
+	}
+
+	// Count the documents of a space or the pages of a document from the
+	// viewer, without fetching any of them
+	if spaces, ok := adapter.(SpaceAdapter); ok && spaces.IsSpace(url) {
+		inspection.Space = true
+		docs, err := spaces.Documents(s.bow, url)
+		if err != nil {
+			inspection.Error = err.Error()
+			return inspection, nil
+		}
+		n := len(docs)
+		inspection.Documents = &n
+		return inspection, nil
+	}
+
+	pages, err := adapter.Pages(s.bow)
+	if err != nil {
+		inspection.Error = err.Error()
+		return inspection, nil
+	}
+	n := len(pages)
+	inspection.Pages = &n
+	if rules, ok := adapter.(RulesAdapter); ok {
+		inspection.Rules = rules.MatchedRules(s.bow)
+	}
+	return inspection, nil
+}
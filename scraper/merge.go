@@ -0,0 +1,96 @@
+package scraper
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// MergeSource is a captured document whose pages were kept in the cache to be
+// merged with others
+type MergeSource struct {
+
+	// The title the document is bookmarked with
+	Title string
+
+	// Where the document's pages are cached
+	CachePrefix string
+
+	// The number of pages in the document
+	Pages int
+
+	// Whether the document's page images were optimized, in which case the
+	// optimized images are merged
+	Optimized bool
+}
+
+// Merge generates a single PDF from the cached pages of each source document
+// in order.  Each document is bookmarked with its title and each of its pages
+// is bookmarked beneath it
+func (s *Scraper) Merge(meta Metadata, sources []MergeSource) (*gofpdf.Fpdf, error) {
+
+	// Update the status
+	s.StatusHandler(fmt.Sprintf("Merging %d documents into a PDF document", len(sources)))
+
+	pdf := newPDF(meta)
+
+	total := 0
+	for _, source := range sources {
+		total += source.Pages
+	}
+
+	done := 0
+	for _, source := range sources {
+		s.CachePrefix = source.CachePrefix
+		s.originalSizes = nil
+		if source.Optimized {
+			sizes, err := s.cachedSizes(source.Pages)
+			if err != nil {
+				return nil, err
+			}
+			s.originalSizes = sizes
+		}
+
+		for i := 0; i < source.Pages; i++ {
+			entry, err := s.cachedPage(i)
+			if err != nil {
+				return nil, err
+			}
+			if entry == nil {
+				return nil, fmt.Errorf("Page %d of %s is no longer cached", i+1, source.Title)
+			}
+			if err := s.addPage(pdf, entry.Page, i); err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				pdf.Bookmark(source.Title, 0, 0)
+			}
+			pdf.Bookmark(fmt.Sprintf("Slide %d", i+1), 1, 0)
+
+			done++
+			s.ProgressHandler(model.Progress{Phase: model.PhaseGenerating, PagesDone: done, PagesTotal: total})
+		}
+	}
+	return pdf, pdf.Error()
+}
+
+// cachedSizes reads the dimensions of the n page images as they were
+// downloaded
+func (s *Scraper) cachedSizes(n int) ([]image.Config, error) {
+	sizes := make([]image.Config, n)
+	for i := range sizes {
+		data, err := s.readCache(s.imageKey(i))
+		if err != nil {
+			return nil, err
+		}
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, &ImageError{Page: i + 1, Message: "Failed to decode image: " + err.Error()}
+		}
+		sizes[i] = config
+	}
+	return sizes, nil
+}
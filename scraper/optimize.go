@@ -0,0 +1,191 @@
+package scraper
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"math"
+	"sync"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	// The lowest quality tried in target size mode and the step down between
+	// attempts
+	minQuality  = 10
+	qualityStep = 10
+
+	// The estimated size a page adds to a document besides its image
+	pageOverhead = 2048
+)
+
+// optimize shrinks the page images as configured by Options.Optimize, writing
+// the optimized images to the cache.  In target size mode each quality is
+// first tried without writing anything until the images fit.  Returns nil if
+// the images aren't optimized
+func (s *Scraper) optimize(pages []*Page) (*model.OptimizationResult, error) {
+	opt := s.Options.Optimize
+	if opt == nil {
+		return nil, nil
+	}
+
+	s.StatusHandler("Optimizing page images")
+
+	result := &model.OptimizationResult{Optimization: *opt}
+	quality := opt.Quality
+	if quality == 0 {
+		quality = model.DefaultQuality
+	}
+
+	// Read the size of each image as downloaded, which the pages are laid
+	// out from
+	sizes := make([]image.Config, len(pages))
+	for i := range pages {
+		data, config, err := s.pageImage(i)
+		if err != nil {
+			return nil, err
+		}
+		sizes[i] = config
+		result.OriginalSize += int64(len(data))
+	}
+
+	if opt.TargetSize > 0 {
+		budget := opt.TargetSize - int64(pageOverhead*len(pages))
+		for q := quality; ; q -= qualityStep {
+			if q < minQuality {
+				q = minQuality
+			}
+			s.StatusHandler(fmt.Sprintf("Trying image quality %d for a target size of %d bytes", q, opt.TargetSize))
+			total, err := s.optimizePass(sizes, q, false)
+			if err != nil {
+				return nil, err
+			}
+			quality = q
+			if total <= budget {
+				result.TargetMet = true
+				break
+			}
+			if q == minQuality {
+				s.StatusHandler(fmt.Sprintf("Images don't fit the target size at the lowest quality of %d", q))
+				break
+			}
+		}
+	}
+
+	total, err := s.optimizePass(sizes, quality, true)
+	if err != nil {
+		return nil, err
+	}
+	s.originalSizes = sizes
+
+	result.Quality = quality
+	result.OptimizedSize = total
+	s.StatusHandler(fmt.Sprintf("Optimized page images from %d to %d bytes at quality %d", result.OriginalSize, total, quality))
+	return result, nil
+}
+
+// optimizePass optimizes every page image at the supplied quality, returning
+// their total size.  The optimized images are only written to the cache if
+// write is set
+func (s *Scraper) optimizePass(sizes []image.Config, quality int, write bool) (int64, error) {
+
+	indexes := make([]int, len(sizes))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	var mutex sync.Mutex
+	var total int64
+	done := 0
+	err := s.parallel(indexes, func(i int) error {
+		data, err := s.readCache(s.imageKey(i))
+		if err != nil {
+			return err
+		}
+		data, err = s.optimizeImage(data, sizes[i], quality, i)
+		if err != nil {
+			return err
+		}
+		if write {
+			if err := s.Cache.Write(s.optimizedKey(i), bytes.NewReader(data)); err != nil {
+				return err
+			}
+		}
+
+		mutex.Lock()
+		total += int64(len(data))
+		done++
+		if write {
+			s.ProgressHandler(model.Progress{Phase: model.PhaseOptimizing, PagesDone: done, PagesTotal: len(sizes)})
+		}
+		mutex.Unlock()
+		return nil
+	})
+	return total, err
+}
+
+// optimizeImage downscales, converts and re-encodes a single page image as a
+// JPEG.  The image is kept as it is if it needn't be resized or converted and
+// re-encoding wouldn't make it smaller
+func (s *Scraper) optimizeImage(data []byte, size image.Config, quality int, index int) ([]byte, error) {
+	opt := s.Options.Optimize
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, &ImageError{Page: index + 1, Message: "Failed to decode image: " + err.Error()}
+	}
+
+	// Flatten the image onto white as JPEGs have no transparency, scaling it
+	// as it's drawn
+	scale := s.optimizeScale(size)
+	width := int(math.Max(1, math.Round(float64(size.Width)*scale)))
+	height := int(math.Max(1, math.Round(float64(size.Height)*scale)))
+	rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(rgba, rgba.Bounds(), image.White, image.Point{}, draw.Src)
+	if scale < 1 {
+		xdraw.BiLinear.Scale(rgba, rgba.Bounds(), img, img.Bounds(), xdraw.Over, nil)
+	} else {
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Over)
+	}
+
+	var optimized image.Image = rgba
+	if opt.Grayscale {
+		gray := image.NewGray(rgba.Bounds())
+		draw.Draw(gray, gray.Bounds(), rgba, image.Point{}, draw.Src)
+		optimized = gray
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, optimized, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, &ImageError{Page: index + 1, Message: "Failed to encode image: " + err.Error()}
+	}
+	if scale == 1 && !opt.Grayscale && buf.Len() >= len(data) {
+		return data, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// optimizeScale returns the factor an image of the supplied size is scaled by
+// to meet the maximum resolution and DPI
+func (s *Scraper) optimizeScale(size image.Config) float64 {
+	opt := s.Options.Optimize
+	width, height := float64(size.Width), float64(size.Height)
+
+	scale := 1.0
+	if max := float64(opt.MaxResolution); max > 0 && math.Max(width, height) > max {
+		scale = max / math.Max(width, height)
+	}
+
+	// The resolution of the image as it's placed on the page
+	if max := float64(opt.MaxDPI); max > 0 {
+		_, r := s.Options.pageLayout(size.Width, size.Height)
+		if dpi := width / (r.width / 72); dpi > max {
+			scale = math.Min(scale, max/dpi)
+		}
+	}
+	return scale
+}
@@ -0,0 +1,33 @@
+package scraper
+
+import (
+	"io"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+)
+
+// output prepares the document in the configured format, returning a function
+// that writes it
+func (s *Scraper) output(meta Metadata, pages []*Page) (func(w io.Writer) error, error) {
+	switch s.Options.Format {
+	case model.FormatZIP:
+		return func(w io.Writer) error {
+			return s.writeZip(w, meta.Source, pages)
+		}, nil
+	case model.FormatCBZ:
+		return func(w io.Writer) error {
+			return s.writeCBZ(w, pages)
+		}, nil
+	case model.FormatPPTX:
+		return func(w io.Writer) error {
+			return s.writePPTX(w, pages)
+		}, nil
+	default:
+		// The PDF is generated up front, only its output is streamed
+		pdf, err := s.Generate(meta, pages)
+		if err != nil {
+			return nil, err
+		}
+		return pdf.Output, nil
+	}
+}
@@ -0,0 +1,235 @@
+package scraper
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// EMUs, the unit of OOXML drawings, per point
+const emuPerPoint = 12700
+
+// The bounds PowerPoint places on the slide size, in EMUs
+const (
+	minSlideSize = 914400
+	maxSlideSize = 51206400
+)
+
+const (
+	pptxNamespaces = `xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" ` +
+		`xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main"`
+
+	pptxGroup = `<p:nvGrpSpPr><p:cNvPr id="1" name=""/><p:cNvGrpSpPr/><p:nvPr/></p:nvGrpSpPr>` +
+		`<p:grpSpPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="0" cy="0"/><a:chOff x="0" y="0"/><a:chExt cx="0" cy="0"/></a:xfrm></p:grpSpPr>`
+
+	pptxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="ppt/presentation.xml"/>` +
+		`</Relationships>`
+
+	pptxMaster = xml.Header + `<p:sldMaster ` + pptxNamespaces + `>` +
+		`<p:cSld><p:bg><p:bgRef idx="1001"><a:schemeClr val="bg1"/></p:bgRef></p:bg><p:spTree>` + pptxGroup + `</p:spTree></p:cSld>` +
+		`<p:clrMap bg1="lt1" tx1="dk1" bg2="lt2" tx2="dk2" accent1="accent1" accent2="accent2" accent3="accent3" accent4="accent4" accent5="accent5" accent6="accent6" hlink="hlink" folHlink="folHlink"/>` +
+		`<p:sldLayoutIdLst><p:sldLayoutId id="2147483649" r:id="rId1"/></p:sldLayoutIdLst>` +
+		`</p:sldMaster>`
+
+	pptxMasterRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slideLayout" Target="../slideLayouts/slideLayout1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/theme" Target="../theme/theme1.xml"/>` +
+		`</Relationships>`
+
+	pptxLayout = xml.Header + `<p:sldLayout ` + pptxNamespaces + ` type="blank" preserve="1">` +
+		`<p:cSld name="Blank"><p:spTree>` + pptxGroup + `</p:spTree></p:cSld>` +
+		`<p:clrMapOvr><a:masterClrMapping/></p:clrMapOvr>` +
+		`</p:sldLayout>`
+
+	pptxLayoutRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slideMaster" Target="../slideMasters/slideMaster1.xml"/>` +
+		`</Relationships>`
+
+	pptxSolidFill   = `<a:solidFill><a:schemeClr val="phClr"/></a:solidFill>`
+	pptxLine        = `<a:ln w="9525">` + pptxSolidFill + `</a:ln>`
+	pptxEffectStyle = `<a:effectStyle><a:effectLst/></a:effectStyle>`
+	pptxFont        = `<a:latin typeface="Calibri"/><a:ea typeface=""/><a:cs typeface=""/>`
+
+	pptxTheme = xml.Header + `<a:theme xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" name="Office Theme"><a:themeElements>` +
+		`<a:clrScheme name="Office">` +
+		`<a:dk1><a:sysClr val="windowText" lastClr="000000"/></a:dk1><a:lt1><a:sysClr val="window" lastClr="FFFFFF"/></a:lt1>` +
+		`<a:dk2><a:srgbClr val="1F497D"/></a:dk2><a:lt2><a:srgbClr val="EEECE1"/></a:lt2>` +
+		`<a:accent1><a:srgbClr val="4F81BD"/></a:accent1><a:accent2><a:srgbClr val="C0504D"/></a:accent2>` +
+		`<a:accent3><a:srgbClr val="9BBB59"/></a:accent3><a:accent4><a:srgbClr val="8064A2"/></a:accent4>` +
+		`<a:accent5><a:srgbClr val="4BACC6"/></a:accent5><a:accent6><a:srgbClr val="F79646"/></a:accent6>` +
+		`<a:hlink><a:srgbClr val="0000FF"/></a:hlink><a:folHlink><a:srgbClr val="800080"/></a:folHlink>` +
+		`</a:clrScheme>` +
+		`<a:fontScheme name="Office"><a:majorFont>` + pptxFont + `</a:majorFont><a:minorFont>` + pptxFont + `</a:minorFont></a:fontScheme>` +
+		`<a:fmtScheme name="Office">` +
+		`<a:fillStyleLst>` + pptxSolidFill + pptxSolidFill + pptxSolidFill + `</a:fillStyleLst>` +
+		`<a:lnStyleLst>` + pptxLine + pptxLine + pptxLine + `</a:lnStyleLst>` +
+		`<a:effectStyleLst>` + pptxEffectStyle + pptxEffectStyle + pptxEffectStyle + `</a:effectStyleLst>` +
+		`<a:bgFillStyleLst>` + pptxSolidFill + pptxSolidFill + pptxSolidFill + `</a:bgFillStyleLst>` +
+		`</a:fmtScheme>` +
+		`</a:themeElements></a:theme>`
+)
+
+// writePPTX writes a PowerPoint presentation with one slide per page, showing
+// the page image with a transparent, clickable region over each link.  A
+// presentation has a single slide size, so it's taken from the first page
+// and every image is fitted to it
+func (s *Scraper) writePPTX(w io.Writer, pages []*Page) error {
+
+	archive := zip.NewWriter(w)
+	slide := gofpdf.SizeType{Wd: 960, Ht: 540}
+	types := new(bytes.Buffer)
+	presentationRels := new(bytes.Buffer)
+	slideIds := new(bytes.Buffer)
+
+	for i, page := range pages {
+		data, config, err := s.pageImage(i)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			size, _ := s.Options.pageLayout(config.Width, config.Height)
+			slide = slideSize(size)
+		}
+
+		// Add the image
+		image := fmt.Sprintf("image%d.%s", i+1, imageExtension(data))
+		if err := writeZipFile(archive, "ppt/media/"+image, data); err != nil {
+			return err
+		}
+
+		// Add the slide showing it
+		r := fit(slide, float64(config.Width), float64(config.Height))
+		body := new(bytes.Buffer)
+		rels := new(bytes.Buffer)
+		fmt.Fprintf(body, `%s<p:sld %s><p:cSld><p:spTree>%s`, xml.Header, pptxNamespaces, pptxGroup)
+		fmt.Fprintf(body, `<p:pic><p:nvPicPr><p:cNvPr id="2" name="Page %d"/><p:cNvPicPr><a:picLocks noChangeAspect="1"/></p:cNvPicPr><p:nvPr/></p:nvPicPr>`, i+1)
+		fmt.Fprintf(body, `<p:blipFill><a:blip r:embed="rId2"/><a:stretch><a:fillRect/></a:stretch></p:blipFill>`)
+		fmt.Fprintf(body, `<p:spPr>%s<a:prstGeom prst="rect"><a:avLst/></a:prstGeom></p:spPr></p:pic>`, xfrm(r.x, r.y, r.width, r.height))
+		fmt.Fprintf(rels, `%s<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`, xml.Header)
+		fmt.Fprintf(rels, `<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slideLayout" Target="../slideLayouts/slideLayout1.xml"/>`)
+		fmt.Fprintf(rels, `<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="../media/%s"/>`, image)
+
+		// Links without a URI would be relationships with no target, which
+		// PowerPoint refuses to open
+		j := 0
+		for _, link := range page.Links {
+			if link.URI == "" {
+				continue
+			}
+			rid := fmt.Sprintf("rId%d", j+3)
+			fmt.Fprintf(body, `<p:sp><p:nvSpPr><p:cNvPr id="%d" name="Link %d"><a:hlinkClick r:id="%s"/></p:cNvPr><p:cNvSpPr/><p:nvPr/></p:nvSpPr>`, j+3, j+1, rid)
+			fmt.Fprintf(body, `<p:spPr>%s<a:prstGeom prst="rect"><a:avLst/></a:prstGeom><a:noFill/><a:ln><a:noFill/></a:ln></p:spPr></p:sp>`,
+				xfrm(r.x+r.width*link.X, r.y+r.height*link.Y, r.width*link.Width, r.height*link.Height))
+			fmt.Fprintf(rels, `<Relationship Id="%s" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="%s" TargetMode="External"/>`, rid, escapeXML(link.URI))
+			j++
+		}
+
+		fmt.Fprintf(body, `</p:spTree></p:cSld><p:clrMapOvr><a:masterClrMapping/></p:clrMapOvr></p:sld>`)
+		fmt.Fprintf(rels, `</Relationships>`)
+
+		name := fmt.Sprintf("slide%d.xml", i+1)
+		if err := writeZipFile(archive, "ppt/slides/"+name, body.Bytes()); err != nil {
+			return err
+		}
+		if err := writeZipFile(archive, "ppt/slides/_rels/"+name+".rels", rels.Bytes()); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(types, `<Override PartName="/ppt/slides/%s" ContentType="application/vnd.openxmlformats-officedocument.presentationml.slide+xml"/>`, name)
+		fmt.Fprintf(presentationRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slide" Target="slides/%s"/>`, i+3, name)
+		fmt.Fprintf(slideIds, `<p:sldId id="%d" r:id="rId%d"/>`, 256+i, i+3)
+	}
+
+	// Add the presentation, which is written last as it lists the slides
+	presentation := fmt.Sprintf(`%s<p:presentation %s>`+
+		`<p:sldMasterIdLst><p:sldMasterId id="2147483648" r:id="rId1"/></p:sldMasterIdLst>`+
+		`<p:sldIdLst>%s</p:sldIdLst>`+
+		`<p:sldSz cx="%d" cy="%d"/><p:notesSz cx="6858000" cy="9144000"/>`+
+		`</p:presentation>`, xml.Header, pptxNamespaces, slideIds.String(), emu(slide.Wd), emu(slide.Ht))
+
+	rels := fmt.Sprintf(`%s<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`+
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slideMaster" Target="slideMasters/slideMaster1.xml"/>`+
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/theme" Target="theme/theme1.xml"/>`+
+		`%s</Relationships>`, xml.Header, presentationRels.String())
+
+	contentTypes := fmt.Sprintf(`%s<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`+
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`+
+		`<Default Extension="xml" ContentType="application/xml"/>`+
+		`<Default Extension="png" ContentType="image/png"/>`+
+		`<Default Extension="jpg" ContentType="image/jpeg"/>`+
+		`<Default Extension="gif" ContentType="image/gif"/>`+
+		`<Override PartName="/ppt/presentation.xml" ContentType="application/vnd.openxmlformats-officedocument.presentationml.presentation.main+xml"/>`+
+		`<Override PartName="/ppt/slideMasters/slideMaster1.xml" ContentType="application/vnd.openxmlformats-officedocument.presentationml.slideMaster+xml"/>`+
+		`<Override PartName="/ppt/slideLayouts/slideLayout1.xml" ContentType="application/vnd.openxmlformats-officedocument.presentationml.slideLayout+xml"/>`+
+		`<Override PartName="/ppt/theme/theme1.xml" ContentType="application/vnd.openxmlformats-officedocument.theme+xml"/>`+
+		`%s</Types>`, xml.Header, types.String())
+
+	parts := []struct {
+		name string
+		data string
+	}{
+		{"ppt/presentation.xml", presentation},
+		{"ppt/_rels/presentation.xml.rels", rels},
+		{"ppt/slideMasters/slideMaster1.xml", pptxMaster},
+		{"ppt/slideMasters/_rels/slideMaster1.xml.rels", pptxMasterRels},
+		{"ppt/slideLayouts/slideLayout1.xml", pptxLayout},
+		{"ppt/slideLayouts/_rels/slideLayout1.xml.rels", pptxLayoutRels},
+		{"ppt/theme/theme1.xml", pptxTheme},
+		{"_rels/.rels", pptxRootRels},
+		{"[Content_Types].xml", contentTypes},
+	}
+	for _, part := range parts {
+		if err := writeZipFile(archive, part.name, []byte(part.data)); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// slideSize returns the page size scaled, keeping its aspect ratio, to within
+// the slide sizes PowerPoint supports
+func slideSize(page gofpdf.SizeType) gofpdf.SizeType {
+	scale := 1.0
+	if long := math.Max(page.Wd, page.Ht) * emuPerPoint; long > maxSlideSize {
+		scale = maxSlideSize / long
+	}
+	if short := math.Min(page.Wd, page.Ht) * emuPerPoint * scale; short < minSlideSize {
+		scale *= minSlideSize / short
+	}
+	return gofpdf.SizeType{Wd: page.Wd * scale, Ht: page.Ht * scale}
+}
+
+// xfrm returns the transform placing a shape at the supplied rectangle, in
+// points
+func xfrm(x float64, y float64, width float64, height float64) string {
+	return fmt.Sprintf(`<a:xfrm><a:off x="%d" y="%d"/><a:ext cx="%d" cy="%d"/></a:xfrm>`, emu(x), emu(y), emu(width), emu(height))
+}
+
+// emu converts points to EMUs
+func emu(points float64) int64 {
+	return int64(points*emuPerPoint + 0.5)
+}
+
+// escapeXML escapes the string for use in an XML attribute
+func escapeXML(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// writeZipFile adds a file with the supplied contents to the archive
+func writeZipFile(archive *zip.Writer, name string, data []byte) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
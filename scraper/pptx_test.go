@@ -0,0 +1,73 @@
+package scraper
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/aldelucca1/docsend_scraper/store/memory"
+)
+
+func TestWritePPTXLinks(t *testing.T) {
+	s := NewScraper(memory.NewObjectStore())
+
+	data := new(bytes.Buffer)
+	if err := png.Encode(data, image.NewRGBA(image.Rect(0, 0, 160, 90))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Cache.Write(s.imageKey(0), data); err != nil {
+		t.Fatal(err)
+	}
+	pages := []*Page{{Links: []Link{
+		{X: 0.1, Y: 0.1, Width: 0.2, Height: 0.1, URI: "https://example.com/?a=1&b=2"},
+		{X: 0.5, Y: 0.5, Width: 0.2, Height: 0.1},
+		{X: 0.7, Y: 0.7, Width: 0.2, Height: 0.1, URI: "https://example.com/pricing"},
+	}}}
+
+	out := new(bytes.Buffer)
+	if err := s.writePPTX(out, pages); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, f := range archive.File {
+		reader, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(data)
+	}
+
+	rels := files["ppt/slides/_rels/slide1.xml.rels"]
+	if strings.Contains(rels, `Target=""`) {
+		t.Errorf("expected no relationship for the link without a URI: %s", rels)
+	}
+	for _, expected := range []string{
+		`Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://example.com/?a=1&amp;b=2"`,
+		`Id="rId4" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://example.com/pricing"`,
+	} {
+		if !strings.Contains(rels, expected) {
+			t.Errorf("expected the relationship %s in %s", expected, rels)
+		}
+	}
+
+	slide := files["ppt/slides/slide1.xml"]
+	if n := strings.Count(slide, "<a:hlinkClick"); n != 2 {
+		t.Errorf("expected 2 clickable links on the slide, got %d", n)
+	}
+	if strings.Contains(slide, `name="Link 3"`) {
+		t.Error("expected the links to be numbered without the skipped one")
+	}
+}
@@ -0,0 +1,25 @@
+package scraper
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// countingReader adds the number of bytes read to count, calling onRead with
+// the new total if set
+type countingReader struct {
+	io.Reader
+	count  *int64
+	onRead func(total int64)
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		total := atomic.AddInt64(r.count, int64(n))
+		if r.onRead != nil {
+			r.onRead(total)
+		}
+	}
+	return n, err
+}
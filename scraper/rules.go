@@ -0,0 +1,249 @@
+package scraper
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/headzoo/surf/browser"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// RulesVersion is the version of the rules file format understood
+const RulesVersion = 1
+
+// Rules hold the extraction rules of each SiteAdapter, keyed by the adapter's
+// name.  Each adapter tries its rule sets in order, so the rules for the
+// current markup of a site come first, followed by those for older markup
+// still served
+type Rules struct {
+	Version  int                  `yaml:"version"`
+	Adapters map[string][]RuleSet `yaml:"adapters"`
+}
+
+// RuleSet is the set of selectors, form fields and page data mappings that
+// match one generation of a site's markup
+type RuleSet struct {
+
+	// The name of the rule set, recorded with the documents it matches
+	Name string `yaml:"name"`
+
+	// The CSS selector of the auth form and the names of its email and
+	// passcode fields
+	AuthForm      string `yaml:"auth_form"`
+	EmailField    string `yaml:"email_field"`
+	PasscodeField string `yaml:"passcode_field"`
+
+	// The name of the checkbox accepting an NDA, either in the auth form or
+	// in an NDA form of its own, and the CSS selector of that form
+	NDAField string `yaml:"nda_field"`
+	NDAForm  string `yaml:"nda_form"`
+
+	// The CSS selector of the form asking for the code emailed to verify the
+	// viewer's address and the name of its code field
+	VerificationForm  string `yaml:"verification_form"`
+	VerificationField string `yaml:"verification_field"`
+
+	// The CSS selector of the element of each page in the viewer and the
+	// attribute holding the URL of its page data
+	Page             string `yaml:"page"`
+	PageURLAttribute string `yaml:"page_url_attribute"`
+
+	// The CSS selector of the links to the documents in a space
+	SpaceDocument string `yaml:"space_document"`
+
+	// Where the fields of a Page are found in the page data
+	PageData PageDataRules `yaml:"page_data"`
+}
+
+// PageDataRules map the fields of the page data JSON to a Page.  Each is the
+// name of a field, or a dotted path to a field of a nested object
+type PageDataRules struct {
+	ImageURL       string    `yaml:"image_url"`
+	DirectImageURL string    `yaml:"direct_image_url"`
+	Links          string    `yaml:"links"`
+	Link           LinkRules `yaml:"link"`
+}
+
+// LinkRules map the fields of each link in the page data to a Link
+type LinkRules struct {
+	X          string `yaml:"x"`
+	Y          string `yaml:"y"`
+	Width      string `yaml:"width"`
+	Height     string `yaml:"height"`
+	URI        string `yaml:"uri"`
+	TrackedURL string `yaml:"tracked_url"`
+}
+
+// RulesAdapter is implemented by a SiteAdapter whose extraction rules are
+// configurable, reporting the name of the rule set that matched the document
+// open in the Browser
+type RulesAdapter interface {
+	SiteAdapter
+
+	MatchedRules(bow *browser.Browser) string
+}
+
+// DefaultRules returns the built in rules, which match DocSend's markup when
+// they were written
+func DefaultRules() *Rules {
+	return &Rules{
+		Version: RulesVersion,
+		Adapters: map[string][]RuleSet{
+			DocSendAdapterName: []RuleSet{
+				RuleSet{
+					Name:              "docsend-1",
+					AuthForm:          "form.new_link_auth_form",
+					EmailField:        "link_auth_form[email]",
+					PasscodeField:     "link_auth_form[passcode]",
+					NDAField:          "link_auth_form[accept_nda]",
+					NDAForm:           "form.nda_acceptance_form",
+					VerificationForm:  "form.email_verification_form",
+					VerificationField: "email_verification_form[code]",
+					Page:              "div.item img.page-view",
+					PageURLAttribute:  "data-url",
+					SpaceDocument:     "a[href]",
+					PageData: PageDataRules{
+						ImageURL:       "imageUrl",
+						DirectImageURL: "directImageUrl",
+						Links:          "documentLinks",
+						Link: LinkRules{
+							X:          "x",
+							Y:          "y",
+							Width:      "width",
+							Height:     "height",
+							URI:        "uri",
+							TrackedURL: "trackedUrl",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+var (
+	rulesMutex sync.RWMutex
+	rules      = DefaultRules()
+)
+
+// SetRules replaces the extraction rules used by every Scraper.  Captures in
+// progress pick up the new rules as they next use them.  Nil restores the
+// built in rules
+func SetRules(r *Rules) {
+	if r == nil {
+		r = DefaultRules()
+	}
+	rulesMutex.Lock()
+	defer rulesMutex.Unlock()
+	rules = r
+}
+
+// CurrentRules returns the extraction rules in use
+func CurrentRules() *Rules {
+	rulesMutex.RLock()
+	defer rulesMutex.RUnlock()
+	return rules
+}
+
+// For returns the rule sets of the named adapter, falling back to the built
+// in rule sets if the rules have none for it
+func (r *Rules) For(adapter string) []RuleSet {
+	if sets := r.Adapters[adapter]; len(sets) > 0 {
+		return sets
+	}
+	return DefaultRules().Adapters[adapter]
+}
+
+// LoadRules reads and validates the rules file at the supplied path
+func LoadRules(path string) (*Rules, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRules(data)
+}
+
+// ParseRules parses and validates the YAML encoded rules
+func ParseRules(data []byte) (*Rules, error) {
+	r := new(Rules)
+	if err := yaml.UnmarshalStrict(data, r); err != nil {
+		return nil, err
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Validate checks the rules are of a version that is understood and that each
+// rule set can extract a document
+func (r *Rules) Validate() error {
+	if r.Version != RulesVersion {
+		return fmt.Errorf("rules version must be %d, got %d", RulesVersion, r.Version)
+	}
+	for adapter, sets := range r.Adapters {
+		names := make(map[string]bool)
+		for i, set := range sets {
+			if set.Name == "" {
+				return fmt.Errorf("adapters.%s[%d].name must be set", adapter, i)
+			}
+			if names[set.Name] {
+				return fmt.Errorf("adapters.%s has more than one rule set named %q", adapter, set.Name)
+			}
+			names[set.Name] = true
+
+			required := []struct{ field, value string }{
+				{"page", set.Page},
+				{"page_url_attribute", set.PageURLAttribute},
+				{"page_data.image_url", set.PageData.ImageURL},
+			}
+			if set.AuthForm != "" {
+				required = append(required,
+					struct{ field, value string }{"email_field", set.EmailField},
+					struct{ field, value string }{"passcode_field", set.PasscodeField})
+			}
+			if set.NDAForm != "" {
+				required = append(required, struct{ field, value string }{"nda_field", set.NDAField})
+			}
+			if set.VerificationForm != "" {
+				required = append(required, struct{ field, value string }{"verification_field", set.VerificationField})
+			}
+			for _, r := range required {
+				if r.value == "" {
+					return fmt.Errorf("adapters.%s[%s].%s must be set", adapter, set.Name, r.field)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// lookup returns the value at the dotted path within the decoded JSON object,
+// or nil if there is none
+func lookup(data interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		object, ok := data.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		data = object[key]
+	}
+	return data
+}
+
+// lookupString returns the string at the dotted path, or "" if there is none
+func lookupString(data interface{}, path string) string {
+	s, _ := lookup(data, path).(string)
+	return s
+}
+
+// lookupFloat returns the number at the dotted path, or 0 if there is none
+func lookupFloat(data interface{}, path string) float64 {
+	f, _ := lookup(data, path).(float64)
+	return f
+}
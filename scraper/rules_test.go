@@ -0,0 +1,179 @@
+package scraper
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/aldelucca1/docsend_scraper/scraper/fake"
+)
+
+// validRules is a rules file with one rule set for the docsend adapter
+const validRules = `version: 1
+adapters:
+  docsend:
+    - name: custom
+      page: div.item img.page-view
+      page_url_attribute: data-url
+      page_data:
+        image_url: imageUrl
+`
+
+func TestParseRules(t *testing.T) {
+	example, err := ioutil.ReadFile("../rules.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"example", string(example), ""},
+		{"minimal", validRules, ""},
+		{"no adapters", "version: 1\n", ""},
+		{"version", strings.Replace(validRules, "version: 1", "version: 2", 1), "rules version must be 1, got 2"},
+		{"unknown field", strings.Replace(validRules, "page: ", "pages: ", 1), "field pages not found"},
+		{"no name", strings.Replace(validRules, "name: custom", "auth_form: form", 1), "adapters.docsend[0].name must be set"},
+		{"duplicate name", validRules + strings.SplitN(validRules, "docsend:\n", 2)[1], `adapters.docsend has more than one rule set named "custom"`},
+		{"no page", strings.Replace(validRules, "page: div.item img.page-view", "page: ''", 1), "adapters.docsend[custom].page must be set"},
+		{"no image url", strings.Replace(validRules, "image_url: imageUrl", "links: documentLinks", 1), "adapters.docsend[custom].page_data.image_url must be set"},
+		{"auth form without fields", validRules + "      auth_form: form.auth\n", "adapters.docsend[custom].email_field must be set"},
+		{"nda form without field", validRules + "      nda_form: form.nda\n", "adapters.docsend[custom].nda_field must be set"},
+		{"verification form without field", validRules + "      verification_form: form.verify\n", "adapters.docsend[custom].verification_field must be set"},
+		{"not yaml", "version: [1", "yaml"},
+	}
+	for _, tt := range tests {
+		_, err := ParseRules([]byte(tt.data))
+		if tt.err == "" && err != nil {
+			t.Errorf("%s: expected valid rules, got %v", tt.name, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestDefaultRulesValid(t *testing.T) {
+	if err := DefaultRules().Validate(); err != nil {
+		t.Errorf("expected the built in rules to be valid, got %v", err)
+	}
+}
+
+func TestRulesFor(t *testing.T) {
+	rules, err := ParseRules([]byte(validRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sets := rules.For(DocSendAdapterName); len(sets) != 1 || sets[0].Name != "custom" {
+		t.Errorf("expected the custom rule set, got %+v", sets)
+	}
+
+	// Adapters without rules fall back to the built in ones
+	empty, err := ParseRules([]byte("version: 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sets := empty.For(DocSendAdapterName); len(sets) != 1 || sets[0].Name != "docsend-1" {
+		t.Errorf("expected the built in rule set, got %+v", sets)
+	}
+}
+
+func TestScrapeWithRules(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	server.AddDocument(fake.NewDocument("deck", 2, "secret"))
+	defer SetRules(nil)
+
+	// The first rule set matches markup the site no longer serves, so the
+	// pages are found by the second
+	rules, err := ParseRules([]byte(`version: 1
+adapters:
+  docsend:
+    - name: docsend-2
+      auth_form: form.link_auth
+      email_field: email
+      passcode_field: passcode
+      page: div.slide img
+      page_url_attribute: data-src
+      page_data:
+        image_url: image.url
+    - name: docsend-1
+      auth_form: form.new_link_auth_form
+      email_field: link_auth_form[email]
+      passcode_field: link_auth_form[passcode]
+      page: div.item img.page-view
+      page_url_attribute: data-url
+      page_data:
+        image_url: imageUrl
+        links: documentLinks
+        link:
+          x: x
+          y: y
+          width: width
+          height: height
+          uri: uri
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetRules(rules)
+	if CurrentRules() != rules {
+		t.Fatal("expected the rules set to be current")
+	}
+
+	s, _ := newTestScraper(server)
+	if err := s.ScrapeTo(context.Background(), server.DocumentURL("deck"), "a@example.com", "secret", "deck.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Capture.Pages != 2 || s.Capture.Rules != "docsend-1" {
+		t.Errorf("expected 2 pages found by the docsend-1 rules, got %d by %q", s.Capture.Pages, s.Capture.Rules)
+	}
+
+	// No rule set matching the viewer fails the capture
+	rules, err = ParseRules([]byte(`version: 1
+adapters:
+  docsend:
+    - name: docsend-2
+      auth_form: form.new_link_auth_form
+      email_field: link_auth_form[email]
+      passcode_field: link_auth_form[passcode]
+      page: div.slide img
+      page_url_attribute: data-src
+      page_data:
+        image_url: image.url
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetRules(rules)
+	s, _ = newTestScraper(server)
+	if err := s.ScrapeTo(context.Background(), server.DocumentURL("deck"), "a@example.com", "secret", "deck.pdf"); err != ErrNoRulesMatched {
+		t.Errorf("expected ErrNoRulesMatched, got %v", err)
+	}
+
+	// Nor does a rule set finding no image in the page data
+	rules, err = ParseRules([]byte(`version: 1
+adapters:
+  docsend:
+    - name: docsend-3
+      auth_form: form.new_link_auth_form
+      email_field: link_auth_form[email]
+      passcode_field: link_auth_form[passcode]
+      page: div.item img.page-view
+      page_url_attribute: data-url
+      page_data:
+        image_url: image.url
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetRules(rules)
+	s, _ = newTestScraper(server)
+	err = s.ScrapeTo(context.Background(), server.DocumentURL("deck"), "a@example.com", "secret", "deck.pdf")
+	if e, ok := err.(*RulesError); !ok || e.Page < 1 {
+		t.Errorf("expected a RulesError naming the page, got %v", err)
+	}
+}
@@ -1,38 +1,103 @@
 package scraper
 
 import (
-	"encoding/json"
-	"errors"
+	"bytes"
+	"context"
 	"fmt"
+	"image"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/aldelucca1/docsend_scraper/model"
 	"github.com/aldelucca1/docsend_scraper/store"
+	"github.com/aldelucca1/docsend_scraper/store/memory"
 	"github.com/headzoo/surf"
 	"github.com/headzoo/surf/agent"
 	"github.com/headzoo/surf/browser"
+	logger "github.com/sirupsen/logrus"
 )
 
 var (
-	NoopStatusHandler = func(msg string) {}
+	NoopStatusHandler   = func(msg string) {}
+	NoopProgressHandler = func(progress model.Progress) {}
 )
 
 // Scraper is an instance of a web scraper
 type Scraper struct {
 	bow           *browser.Browser
+	client        *http.Client
+	ctx           context.Context
+	transport     *contextTransport
 	os            store.ObjectStore
+	adapter       SiteAdapter
 	StatusHandler StatusHandler
+	Options       Options
+
+	// ProgressHandler receives the progress of the capture as it changes
+	ProgressHandler ProgressHandler
+
+	// ChallengeHandler asks the user for the input a gate needs, such as a
+	// verification code emailed to them.  Without one such gates fail with
+	// ErrInputRequired
+	ChallengeHandler ChallengeFunc
+
+	// The number of image bytes downloaded by the scrape in progress
+	downloaded int64
+
+	// Cache holds downloaded page metadata and images under CachePrefix so
+	// an interrupted scrape only fetches the missing pages when retried.
+	// Defaults to an in-memory store
+	Cache       store.ObjectStore
+	CachePrefix string
+
+	// PageRetries is the number of times fetching a single page is retried
+	PageRetries int
+
+	// Capture describes the document produced by the last successful scrape
+	Capture *model.Capture
+
+	// The size of each page image as downloaded, set once the images have
+	// been optimized
+	originalSizes []image.Config
 }
 
-// NewScraper returns a new Scraper object
+// NewScraper returns a new Scraper object making its requests with the base
+// client
 func NewScraper(os store.ObjectStore) *Scraper {
+	return NewScraperWithClient(os, baseClient)
+}
+
+// NewScraperWithClient returns a new Scraper object making its requests
+// through the supplied client's Transport, which defaults to
+// http.DefaultTransport, with the client's Timeout.  The Scraper keeps its own
+// cookies so the client's Jar isn't used
+func NewScraperWithClient(os store.ObjectStore, base *http.Client) *Scraper {
 
 	s := new(Scraper)
 	s.StatusHandler = NoopStatusHandler
+	s.ProgressHandler = NoopProgressHandler
+	s.Options = DefaultOptions()
 	s.os = os
+	s.Cache = memory.NewObjectStore()
+	s.PageRetries = 3
+	s.ctx = context.Background()
+
+	// Share the session cookies between the Browser, which handles the auth
+	// form, and the client used to download pages concurrently
+	jar, _ := cookiejar.New(nil)
+	roundTripper := base.Transport
+	if roundTripper == nil {
+		roundTripper = http.DefaultTransport
+	}
+	transport := newLimitTransport(roundTripper)
 
 	// Setup our Browser instance
 	bow := surf.NewBrowser()
@@ -42,123 +107,414 @@ func NewScraper(os store.ObjectStore) *Scraper {
 		browser.MetaRefreshHandling: false,
 		browser.FollowRedirects:     true,
 	})
+	bow.SetCookieJar(jar)
+
+	// The Browser doesn't take a context, so its requests are made with the
+	// context of the scrape in progress by the transport
+	s.transport = &contextTransport{base: transport, ctx: s.ctx}
+	bow.SetTransport(s.transport)
 	s.bow = bow
 
+	s.client = &http.Client{
+		Jar:       jar,
+		Transport: transport,
+		Timeout:   base.Timeout,
+	}
+
 	return s
 }
 
 // Scrape the specified URL downloading each page image and producing a
-// downloadable PDF document
-func (s *Scraper) Scrape(url *url.URL, email string, passcode string) error {
+// downloadable document in the configured format
+func (s *Scraper) Scrape(ctx context.Context, url *url.URL, email string, passcode string) error {
+	return s.ScrapeTo(ctx, url, email, passcode, path.Join(email, path.Base(url.Path)+"."+s.Options.Format.Extension()))
+}
+
+// ScrapeTo scrapes the specified URL, writing the produced document to the
+// supplied path within the object store.  If the context is cancelled the
+// scrape stops and the context's error is returned
+func (s *Scraper) ScrapeTo(ctx context.Context, url *url.URL, email string, passcode string, dst string) error {
+	s.ctx = ctx
+	s.transport.ctx = ctx
+	s.downloaded = 0
+	s.Capture = nil
+	s.originalSizes = nil
+
+	err := s.scrape(url, email, passcode, dst)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func (s *Scraper) scrape(url *url.URL, email string, passcode string, dst string) error {
 
 	// Update the status
 	s.StatusHandler("Started capturing document")
+	captured := time.Now()
+	s.ProgressHandler(model.Progress{Phase: model.PhaseAuthenticating})
 
-	// Open the root URL
-	err := s.bow.Open(url.String())
+	if err := s.open(url, email, passcode); err != nil {
+		return err
+	}
+
+	// Fetch the Page data
+	urls, err := s.adapter.Pages(s.bow)
 	if err != nil {
 		return err
 	}
-	if s.bow.StatusCode() != http.StatusOK {
-		return errors.New("Failed to fetch document")
+	rules := s.matchedRules()
+	pages, err := s.FetchPages(s.resolve(urls))
+	if err != nil {
+		return err
 	}
 
-	// If we have the auth form, set the supplied email and passcode
-	form, err := s.bow.Form("form.new_link_auth_form")
-	if err == nil {
-
-		// Update the status
-		s.StatusHandler("Authenticating with supplied email and passcode")
-
-		// Submit the auth form
-		form.Input("link_auth_form[email]", email)
-		form.Input("link_auth_form[passcode]", passcode)
-		err = form.Submit()
-		if err != nil {
-			return err
-		}
-
-		// Check if we get past after Submitting
-		if _, err = s.bow.Form("form.new_link_auth_form"); err == nil {
-			return errors.New("Authentication failed")
-		}
+	// Fingerprint the pages so versions of the document can be compared
+	hashes, err := s.pageHashes(len(pages))
+	if err != nil {
+		return err
+	}
+	if s.Options.Previous != nil && equalHashes(s.Options.Previous, hashes) {
+		s.StatusHandler("No pages have changed since the previous version")
+		s.clearCache(len(pages))
+		return ErrUnchanged
 	}
 
-	// Fetch the Page data
-	pages, err := s.FetchPages(s.extractImgSrc(s.bow.Dom()))
+	// Shrink the page images if asked to
+	optimization, err := s.optimize(pages)
 	if err != nil {
 		return err
 	}
 
-	// Generate the PDF
-	pdf, err := s.Generate(pages)
+	// Generate the document
+	meta := Metadata{
+		Title:    s.title(url),
+		Source:   url,
+		Author:   email,
+		Captured: captured,
+	}
+	output, err := s.output(meta, pages)
 	if err != nil {
 		return err
 	}
 
+	// Don't write the document if the scrape was cancelled while generating it
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
 	// Update the status
-	s.StatusHandler("Writing PDF document to object storage")
+	s.StatusHandler(fmt.Sprintf("Writing %s document to object storage", strings.ToUpper(s.Options.Format.Extension())))
 
-	// Write the file
+	// Write the file, reporting progress every megabyte
 	pr, pw := io.Pipe()
 	go func() {
-		pdf.OutputAndClose(pw)
+		pw.CloseWithError(output(pw))
 	}()
 
-	return s.os.Write(path.Join(email, path.Base(url.Path)+".pdf"), pr)
+	var written, reported int64
+	s.ProgressHandler(model.Progress{Phase: model.PhaseUploading})
+	upload := &countingReader{Reader: pr, count: &written, onRead: func(total int64) {
+		if total-reported >= 1<<20 {
+			reported = total
+			s.ProgressHandler(model.Progress{Phase: model.PhaseUploading, Bytes: total})
+		}
+	}}
+	if err := s.os.Write(dst, upload); err != nil {
+		pr.CloseWithError(err)
+
+		// Don't leave a partially written document behind
+		if err := s.os.Delete(dst); err != nil {
+			logger.Warnf("Failed to delete partial document %s: %s", dst, err.Error())
+		}
+		return err
+	}
+	s.ProgressHandler(model.Progress{Phase: model.PhaseUploading, Bytes: written})
+
+	s.Capture = &model.Capture{
+		Pages:        len(pages),
+		Size:         written,
+		Optimization: optimization,
+		PageHashes:   hashes,
+		Rules:        rules,
+	}
+
+	// The document is complete, the cached pages are no longer needed
+	// unless they're kept to be merged with other documents
+	if !s.Options.KeepPages {
+		s.clearCache(len(pages))
+	}
+	return nil
+}
+
+// open opens the URL through the SiteAdapter named by the options or matching
+// the URL, authenticating with the supplied email and passcode
+func (s *Scraper) open(url *url.URL, email string, passcode string) error {
+	adapter, err := adapterFor(s.Options.Adapter, url)
+	if err != nil {
+		return err
+	}
+	s.adapter = adapter
+
+	s.StatusHandler(fmt.Sprintf("Opening %s with the %s adapter", url.String(), adapter.Name()))
+	return adapter.Authenticate(s.bow, url, email, passcode, s.challenge)
 }
 
-// FetchPages downloads the Page information for each page container found in
-// DOM
+// challenge asks the ChallengeHandler for the input a gate needs
+func (s *Scraper) challenge(challenge model.Challenge) (string, error) {
+	if s.ChallengeHandler == nil {
+		return "", ErrInputRequired
+	}
+	return s.ChallengeHandler(challenge)
+}
+
+// matchedRules returns the name of the extraction rule set that matched the
+// open document, if the adapter's rules are configurable
+func (s *Scraper) matchedRules() string {
+	adapter, ok := s.adapter.(RulesAdapter)
+	if !ok {
+		return ""
+	}
+	rules := adapter.MatchedRules(s.bow)
+	if rules != "" {
+		s.StatusHandler(fmt.Sprintf("Extracting pages with the %s rules", rules))
+	}
+	return rules
+}
+
+// title returns the title of the open page, falling back to the document
+// slug if it has none
+func (s *Scraper) title(url *url.URL) string {
+	if title := strings.TrimSpace(s.bow.Title()); title != "" {
+		return title
+	}
+	return path.Base(url.Path)
+}
+
+// FetchPages downloads the Page information and image for each page container
+// found in DOM.  Pages already in the cache aren't downloaded again
 func (s *Scraper) FetchPages(urls []string) ([]*Page, error) {
 	n := len(urls)
 	pages := make([]*Page, n)
-	for i, url := range urls {
-		s.StatusHandler(fmt.Sprintf("Fetching page %d of %d", i+1, n))
 
-		page, err := s.fetch(url, i)
+	// Load the pages downloaded by a previous attempt.  A page cached from
+	// another URL belongs to a different page and is downloaded again
+	missing := make([]int, 0, n)
+	for i := range urls {
+		entry, err := s.cachedPage(i)
 		if err != nil {
 			return nil, err
 		}
+		if entry != nil && entry.URL == urls[i] {
+			pages[i] = entry.Page
+		} else {
+			missing = append(missing, i)
+		}
+	}
+	cached := n - len(missing)
+	if cached > 0 {
+		s.StatusHandler(fmt.Sprintf("Resuming with %d of %d pages already downloaded", cached, n))
+	} else {
+		s.StatusHandler(fmt.Sprintf("Downloading %d pages", n))
+	}
+
+	// Fetch the metadata for each missing page
+	var mutex sync.Mutex
+	done := cached
+	s.ProgressHandler(model.Progress{Phase: model.PhaseFetchingMetadata, PagesDone: done, PagesTotal: n})
+	err := s.parallel(missing, func(i int) error {
+		page, err := s.fetchMetadata(urls[i], i)
+		if err != nil {
+			return err
+		}
 		pages[i] = page
+
+		mutex.Lock()
+		done++
+		s.ProgressHandler(model.Progress{Phase: model.PhaseFetchingMetadata, PagesDone: done, PagesTotal: n})
+		mutex.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return pages, nil
-}
 
-func (s *Scraper) fetch(url string, index int) (*Page, error) {
+	// Then download the image for each missing page
+	done = cached
+	s.ProgressHandler(model.Progress{Phase: model.PhaseDownloadingImages, PagesDone: done, PagesTotal: n})
+	err = s.parallel(missing, func(i int) error {
+		page, err := s.fetchImage(urls[i], pages[i], i)
+		if err != nil {
+			return err
+		}
+		pages[i] = page
 
-	err := s.bow.Open(url)
+		// Write the metadata last, its presence marks the page as complete
+		if err := s.cachePage(page, urls[i], i); err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		done++
+		s.ProgressHandler(model.Progress{Phase: model.PhaseDownloadingImages, PagesDone: done, PagesTotal: n, Bytes: atomic.LoadInt64(&s.downloaded)})
+		mutex.Unlock()
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	if s.bow.StatusCode() != http.StatusOK {
-		return nil, fmt.Errorf("Failed to fetch page metadata for page: %d", index+1)
+	return pages, nil
+}
+
+// parallel calls fn for each of the supplied page indexes with a bounded pool
+// of workers.  The first error stops the pool and is returned
+func (s *Scraper) parallel(indexes []int, fn func(index int) error) error {
+
+	queue := make(chan int, len(indexes))
+	for _, i := range indexes {
+		queue <- i
 	}
+	close(queue)
 
-	// Set up the pipe to write data directly into the Reader.
-	pr, pw := io.Pipe()
+	concurrency := s.Options.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
 
-	// Download the data through the json decoder
-	go func() {
-		s.bow.Download(pw)
-	}()
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var firstErr error
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				mutex.Lock()
+				if firstErr == nil {
+					firstErr = s.ctx.Err()
+				}
+				failed := firstErr != nil
+				mutex.Unlock()
+				if failed {
+					return
+				}
+
+				if err := fn(i); err != nil {
+					mutex.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}
 
-	// Decode the read stream
+// fetchMetadata downloads the metadata for a single page, retrying temporary
+// failures
+func (s *Scraper) fetchMetadata(url string, index int) (*Page, error) {
 	var page *Page
-	err = json.NewDecoder(pr).Decode(&page)
+	err := s.withRetries(index, func(attempt int) (err error) {
+		page, err = s.fetch(url, index)
+		return err
+	})
+	return page, err
+}
+
+// fetchImage downloads the image for a single page into the cache, retrying
+// temporary failures.  The image URLs in the metadata expire, so each retry
+// fetches the metadata again, returning the page it was downloaded with
+func (s *Scraper) fetchImage(url string, page *Page, index int) (*Page, error) {
+	err := s.withRetries(index, func(attempt int) (err error) {
+		if attempt > 0 {
+			if page, err = s.fetch(url, index); err != nil {
+				return err
+			}
+		}
+		return s.downloadImage(page, index)
+	})
+	return page, err
+}
+
+// withRetries calls fn until it succeeds, returns an error that isn't
+// temporary or the page retries are exhausted
+func (s *Scraper) withRetries(index int, fn func(attempt int) error) error {
+	var err error
+	for attempt := 0; attempt <= s.PageRetries; attempt++ {
+		if attempt > 0 {
+			s.StatusHandler(fmt.Sprintf("Retrying page %d after error: %s", index+1, err.Error()))
+			select {
+			case <-time.After(time.Duration(attempt) * time.Second):
+			case <-s.ctx.Done():
+				return s.ctx.Err()
+			}
+		}
+		if err = fn(attempt); err == nil || !temporary(err) {
+			break
+		}
+	}
+	return err
+}
+
+// downloadImage downloads the image for a page into the cache, transcoding it
+// if it's in a format that can't be embedded as it is
+func (s *Scraper) downloadImage(page *Page, index int) error {
+	rsp, err := s.get(page.ImageURL)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return &HTTPError{Message: fmt.Sprintf("Failed to fetch image for page: %d", index+1), StatusCode: rsp.StatusCode}
+	}
+
+	data, err := ioutil.ReadAll(&countingReader{Reader: rsp.Body, count: &s.downloaded})
+	if err != nil {
+		return err
+	}
+	data, err = normalizeImage(data, index)
+	if err != nil {
+		return err
 	}
+	return s.Cache.Write(s.imageKey(index), bytes.NewReader(data))
+}
 
-	return page, nil
+// fetch fetches the metadata for the page at index through the SiteAdapter
+func (s *Scraper) fetch(url string, index int) (*Page, error) {
+	return s.adapter.FetchPage(s.get, url, index)
 }
 
-func (s *Scraper) extractImgSrc(dom *goquery.Selection) []string {
-	imgs := dom.Find("div.item").Find("img.page-view").Map(func(_ int, s *goquery.Selection) string {
-		if url, ok := s.Attr("data-url"); ok {
-			return url
+// get issues a GET request through the shared client, presenting as the same
+// browser that opened the document
+func (s *Scraper) get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(s.ctx)
+	req.Header.Set("User-Agent", agent.Chrome())
+	if referer := s.bow.Url(); referer != nil {
+		req.Header.Set("Referer", referer.String())
+	}
+	return s.client.Do(req)
+}
+
+// resolve makes each of the supplied URLs absolute relative to the open page
+func (s *Scraper) resolve(urls []string) []string {
+	base := s.bow.Url()
+	if base == nil {
+		return urls
+	}
+	resolved := make([]string, len(urls))
+	for i, u := range urls {
+		resolved[i] = u
+		if ref, err := url.Parse(u); err == nil {
+			resolved[i] = base.ResolveReference(ref).String()
 		}
-		return ""
-	})
-	return imgs
+	}
+	return resolved
 }
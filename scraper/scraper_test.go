@@ -0,0 +1,207 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/scraper/fake"
+	"github.com/aldelucca1/docsend_scraper/store"
+	"github.com/aldelucca1/docsend_scraper/store/memory"
+)
+
+// newTestScraper returns a Scraper capturing from the fake server into an
+// in-memory object store
+func newTestScraper(server *fake.Server) (*Scraper, store.ObjectStore) {
+	os := memory.NewObjectStore()
+	return NewScraperWithClient(os, server.Client()), os
+}
+
+// readObject reads the object at the path in the object store
+func readObject(t *testing.T, os store.ObjectStore, path string) []byte {
+	reader, err := os.Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := new(bytes.Buffer)
+	if _, err := data.ReadFrom(reader); err != nil {
+		t.Fatal(err)
+	}
+	return data.Bytes()
+}
+
+func TestScrape(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	doc := fake.NewDocument("deck", 3, "secret")
+	doc.Links = [][]fake.Link{{{X: 0.1, Y: 0.1, Width: 0.2, Height: 0.1, URI: "https://example.com"}}}
+	server.AddDocument(doc)
+
+	s, os := newTestScraper(server)
+	var progress []model.Progress
+	s.ProgressHandler = func(p model.Progress) {
+		progress = append(progress, p)
+	}
+	if err := s.Scrape(context.Background(), server.DocumentURL("deck"), "a@example.com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Capture == nil || s.Capture.Pages != 3 || len(s.Capture.PageHashes) != 3 {
+		t.Fatalf("expected a 3 page capture, got %+v", s.Capture)
+	}
+	if s.Capture.PageHashes[0] == s.Capture.PageHashes[1] {
+		t.Error("expected the pages to hash differently")
+	}
+	if n := server.Requests("/view/deck/page_data/"); n != 3 {
+		t.Errorf("expected each page's data to be fetched once, got %d requests", n)
+	}
+	if n := server.Requests("/images/deck/"); n != 3 {
+		t.Errorf("expected each page's image to be fetched once, got %d requests", n)
+	}
+
+	data := readObject(t, os, "a@example.com/deck.pdf")
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		t.Errorf("expected a PDF, got %q", data[:16])
+	}
+	if !bytes.Contains(data, []byte("https://example.com")) {
+		t.Error("expected the PDF to link to the page's link")
+	}
+	if last := progress[len(progress)-1]; last.Phase != model.PhaseUploading || last.Bytes != int64(len(data)) {
+		t.Errorf("expected the upload of %d bytes to be the last progress, got %+v", len(data), last)
+	}
+}
+
+func TestScrapeAuthenticationFailed(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	server.AddDocument(fake.NewDocument("deck", 2, "secret"))
+
+	tests := []struct {
+		name     string
+		email    string
+		passcode string
+	}{
+		{"wrong passcode", "a@example.com", "guess"},
+		{"no passcode", "a@example.com", ""},
+		{"no email", "", "secret"},
+	}
+	for _, tt := range tests {
+		s, os := newTestScraper(server)
+		err := s.ScrapeTo(context.Background(), server.DocumentURL("deck"), tt.email, tt.passcode, "deck.pdf")
+		if err != ErrAuthenticationFailed {
+			t.Errorf("%s: expected ErrAuthenticationFailed, got %v", tt.name, err)
+		}
+		if exists, _ := os.Exists("deck.pdf"); exists {
+			t.Errorf("%s: expected no document written", tt.name)
+		}
+	}
+	if n := server.Requests("/view/deck/page_data/"); n != 0 {
+		t.Errorf("expected no pages fetched without authenticating, got %d requests", n)
+	}
+}
+
+func TestScrapeRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		failure  fake.Failure
+		path     string
+		requests int
+		status   int
+	}{
+		{"image server error", fake.Failure{Path: "/images/deck/2", Times: 1, Status: http.StatusServiceUnavailable}, "/images/deck/2", 2, 0},
+		{"page data server error", fake.Failure{Path: "/view/deck/page_data/1", Times: 1, Status: http.StatusBadGateway}, "/view/deck/page_data/1", 2, 0},
+		{"rate limited", fake.Failure{Path: "/images/deck/1", Times: 1, Status: http.StatusTooManyRequests}, "/images/deck/1", 2, 0},
+		{"image not found", fake.Failure{Path: "/images/deck/2", Status: http.StatusNotFound}, "/images/deck/2", 1, http.StatusNotFound},
+		{"page data forbidden", fake.Failure{Path: "/view/deck/page_data/2", Status: http.StatusForbidden}, "/view/deck/page_data/2", 1, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		server := fake.NewServer()
+		server.AddDocument(fake.NewDocument("deck", 2, ""))
+		server.Fail(tt.failure)
+
+		s, _ := newTestScraper(server)
+		err := s.ScrapeTo(context.Background(), server.DocumentURL("deck"), "a@example.com", "", "deck.pdf")
+		if tt.status == 0 && err != nil {
+			t.Errorf("%s: expected the capture to succeed once retried, got %v", tt.name, err)
+		}
+		if tt.status != 0 {
+			if e, ok := err.(*HTTPError); !ok || e.StatusCode != tt.status {
+				t.Errorf("%s: expected an HTTP %d error, got %v", tt.name, tt.status, err)
+			}
+		}
+		if n := server.Requests(tt.path); n != tt.requests {
+			t.Errorf("%s: expected %d requests for %s, got %d", tt.name, tt.requests, tt.path, n)
+		}
+		server.Close()
+	}
+}
+
+func TestScrapeSniffsImages(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	doc := fake.NewDocument("deck", 2, "")
+	doc.Pages[1] = fake.JPEG(160, 90, color.White)
+	server.AddDocument(doc)
+	server.Fail(fake.Failure{Path: "/images/", NoContentType: true})
+
+	s, os := newTestScraper(server)
+	s.Options.Format = model.FormatZIP
+	if err := s.ScrapeTo(context.Background(), server.DocumentURL("deck"), "a@example.com", "", "deck.zip"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Capture.Pages != 2 {
+		t.Errorf("expected 2 pages, got %d", s.Capture.Pages)
+	}
+
+	// The images are named by the format sniffed from their content
+	data := readObject(t, os, "deck.zip")
+	for _, name := range []string{"page-0001.png", "page-0002.jpg"} {
+		if !bytes.Contains(data, []byte(name)) {
+			t.Errorf("expected %s in the archive", name)
+		}
+	}
+}
+
+func TestScrapeSlowBody(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	server.AddDocument(fake.NewDocument("deck", 2, ""))
+
+	// A body slower than the client's timeout is retried
+	client := server.Client()
+	client.Timeout = 200 * time.Millisecond
+	server.Fail(fake.Failure{Path: "/images/deck/1", Times: 1, Delay: time.Second})
+	server.Fail(fake.Failure{Path: "/images/deck/2", Times: 1, Delay: 50 * time.Millisecond})
+
+	s := NewScraperWithClient(memory.NewObjectStore(), client)
+	if err := s.ScrapeTo(context.Background(), server.DocumentURL("deck"), "a@example.com", "", "deck.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if n := server.Requests("/images/deck/1"); n != 2 {
+		t.Errorf("expected the timed out image to be fetched again, got %d requests", n)
+	}
+	if n := server.Requests("/images/deck/2"); n != 1 {
+		t.Errorf("expected the slow image to be fetched once, got %d requests", n)
+	}
+}
+
+func TestScrapeCancelled(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	server.AddDocument(fake.NewDocument("deck", 2, ""))
+	server.Fail(fake.Failure{Path: "/images/", Delay: time.Minute})
+
+	s, os := newTestScraper(server)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := s.ScrapeTo(ctx, server.DocumentURL("deck"), "a@example.com", "", "deck.pdf"); err != context.DeadlineExceeded {
+		t.Errorf("expected the capture to stop with the context, got %v", err)
+	}
+	if exists, _ := os.Exists("deck.pdf"); exists {
+		t.Error("expected no document written")
+	}
+}
@@ -0,0 +1,64 @@
+package scraper
+
+import (
+	"context"
+	"net/url"
+)
+
+// Space is a folder of documents shared behind one link, such as a DocSend
+// space
+type Space struct {
+
+	// The title of the space
+	Title string
+
+	// The documents in the space in the order they're listed
+	Documents []SpaceDocument
+}
+
+// SpaceDocument is a single document listed in a Space
+type SpaceDocument struct {
+
+	// The title the document is listed with
+	Title string
+
+	// The link to the document within the space
+	URL *url.URL
+}
+
+// IsSpace reports whether the URL links to a space of documents on a site
+// whose adapter supports them
+func IsSpace(u *url.URL) bool {
+	adapter, ok := MatchAdapter(u).(SpaceAdapter)
+	return ok && adapter.IsSpace(u)
+}
+
+// ListSpace opens the space at the specified URL, authenticating with the
+// supplied email and passcode, and lists the documents in it
+func (s *Scraper) ListSpace(ctx context.Context, url *url.URL, email string, passcode string) (*Space, error) {
+	s.ctx = ctx
+	s.transport.ctx = ctx
+
+	space, err := s.listSpace(url, email, passcode)
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return space, err
+}
+
+func (s *Scraper) listSpace(url *url.URL, email string, passcode string) (*Space, error) {
+	if err := s.open(url, email, passcode); err != nil {
+		return nil, err
+	}
+	adapter, ok := s.adapter.(SpaceAdapter)
+	if !ok || !adapter.IsSpace(url) {
+		return nil, ErrUnsupportedSite
+	}
+
+	s.StatusHandler("Listing the documents in the space")
+	docs, err := adapter.Documents(s.bow, url)
+	if err != nil {
+		return nil, err
+	}
+	return &Space{Title: s.title(url), Documents: docs}, nil
+}
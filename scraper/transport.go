@@ -0,0 +1,127 @@
+package scraper
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// DefaultMaxHostConnections is the default cap on concurrent connections to a
+// single host, shared by every Scraper in the process
+const DefaultMaxHostConnections = 8
+
+var limiter = newHostLimiter(DefaultMaxHostConnections)
+
+// SetMaxHostConnections sets the cap on concurrent connections to a single host
+// shared by every Scraper in the process.  It should be called before any
+// scrape starts
+func SetMaxHostConnections(n int) {
+	limiter = newHostLimiter(n)
+}
+
+var baseClient = &http.Client{}
+
+// SetBaseClient sets the client every Scraper created by NewScraper makes its
+// requests with, for instance to send them through a proxy or to a fake
+// server.  A nil client restores the default.  It should be called before any
+// scrape starts
+func SetBaseClient(client *http.Client) {
+	if client == nil {
+		client = &http.Client{}
+	}
+	baseClient = client
+}
+
+// hostLimiter hands out a fixed number of slots per host
+type hostLimiter struct {
+	max   int
+	mutex sync.Mutex
+	slots map[string]chan struct{}
+}
+
+func newHostLimiter(max int) *hostLimiter {
+	return &hostLimiter{
+		max:   max,
+		slots: make(map[string]chan struct{}),
+	}
+}
+
+// acquire blocks until a slot for the host is free or the context is done
+func (l *hostLimiter) acquire(ctx context.Context, host string) error {
+	l.mutex.Lock()
+	slots, ok := l.slots[host]
+	if !ok {
+		slots = make(chan struct{}, l.max)
+		l.slots[host] = slots
+	}
+	l.mutex.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a slot for the host
+func (l *hostLimiter) release(host string) {
+	l.mutex.Lock()
+	slots := l.slots[host]
+	l.mutex.Unlock()
+
+	<-slots
+}
+
+// limitTransport is an http.RoundTripper that holds a host slot from sending
+// the request until the response body is closed
+type limitTransport struct {
+	base    http.RoundTripper
+	limiter *hostLimiter
+}
+
+func newLimitTransport(base http.RoundTripper) *limitTransport {
+	return &limitTransport{base: base, limiter: limiter}
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if err := t.limiter.acquire(req.Context(), host); err != nil {
+		return nil, err
+	}
+
+	rsp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.limiter.release(host)
+		return nil, err
+	}
+	rsp.Body = &releaseBody{ReadCloser: rsp.Body, release: func() {
+		t.limiter.release(host)
+	}}
+	return rsp, nil
+}
+
+// releaseBody releases its host slot exactly once when closed
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// contextTransport is an http.RoundTripper that sends each request with its
+// context, for clients such as the Browser that can't be given one
+type contextTransport struct {
+	base http.RoundTripper
+	ctx  context.Context
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
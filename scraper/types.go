@@ -1,8 +1,88 @@
 package scraper
 
+import (
+	"net/url"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+)
+
 // StatusHandler is a handler function for status updates
 type StatusHandler func(message string)
 
+// ProgressHandler is a handler function for progress updates
+type ProgressHandler func(progress model.Progress)
+
+// DefaultConcurrency is the default number of pages fetched at once
+const DefaultConcurrency = 4
+
+// DefaultDPI is the default resolution of page images, at which one pixel is
+// one point
+const DefaultDPI = 72
+
+// Options configures how a Scraper captures a document
+type Options struct {
+
+	// The name of the SiteAdapter the document is captured with, matched
+	// from the URL if empty
+	Adapter string
+
+	// The number of pages fetched at once
+	Concurrency int
+
+	// The file format of the generated document
+	Format model.Format
+
+	// How page images are placed on the pages of the document
+	Layout model.Layout
+
+	// The resolution of the page images for the native layout, in dots per
+	// inch
+	DPI int
+
+	// How page images are optimized before the document is generated, nil
+	// to embed them as downloaded
+	Optimize *model.Optimization
+
+	// Whether a PDF starts with a cover page describing where it came from
+	Cover bool
+
+	// The page hashes of the previous version of the document.  If set and
+	// the downloaded pages match, the scrape stops with ErrUnchanged
+	// without writing a document
+	Previous []string
+
+	// Whether the downloaded pages are kept in the cache once the document
+	// is written, so it can be merged with others
+	KeepPages bool
+}
+
+// DefaultOptions returns the Options with the default values
+func DefaultOptions() Options {
+	return Options{
+		Concurrency: DefaultConcurrency,
+		Format:      model.FormatPDF,
+		Layout:      model.LayoutNative,
+		DPI:         DefaultDPI,
+	}
+}
+
+// Metadata describes where a captured document came from
+type Metadata struct {
+
+	// The title of the page the document was viewed in
+	Title string
+
+	// The link the document was captured from
+	Source *url.URL
+
+	// The email address the document was captured for
+	Author string
+
+	// When the capture started
+	Captured time.Time
+}
+
 // Link represents a Link within a Page
 type Link struct {
 	X          float64 `json:"x"`
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+
+	"github.com/aldelucca1/docsend_scraper/store"
+	"github.com/aldelucca1/docsend_scraper/task"
+	logger "github.com/sirupsen/logrus"
+)
+
+// cachedTask is a capture whose downloaded pages are cleared from the cache
+// once it can no longer be resumed from them, when it fails for good or is
+// cancelled.  Pages are kept while a retry or a restart may still use them,
+// and a successful capture clears its own
+type cachedTask struct {
+	task.Task
+	store  store.Datastore
+	os     store.ObjectStore
+	owner  string
+	prefix string
+}
+
+// newCachedTask wraps the capture of the version of the document cached under
+// prefix.  It must run within the capture's lease, held as owner
+func newCachedTask(t task.Task, datastore store.Datastore, os store.ObjectStore, owner string, prefix string) task.Task {
+	return &cachedTask{Task: t, store: datastore, os: os, owner: owner, prefix: prefix}
+}
+
+func (t *cachedTask) Execute(ctx context.Context, status chan<- task.TaskStatus) error {
+	err := t.Task.Execute(ctx, status)
+	if err == nil {
+		return nil
+	}
+
+	// A capture whose lease was taken over after it expired is resumed
+	// elsewhere from the same pages
+	doc, getErr := t.store.GetDocument(t.ID())
+	if getErr != nil {
+		logger.Warnf("Failed to read document %s, keeping its cached pages: %s", t.ID(), getErr.Error())
+		return err
+	}
+	if doc.Active() && doc.Job.LeaseOwner != t.owner {
+		return err
+	}
+
+	clearCache(t.os, t.prefix)
+	return err
+}
+
+// clearCache deletes the pages cached under the prefix
+func clearCache(os store.ObjectStore, prefix string) {
+	if err := os.DeletePrefix(prefix); err != nil {
+		logger.Warnf("Failed to delete cached pages %s: %s", prefix, err.Error())
+	}
+}
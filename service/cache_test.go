@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/scraper"
+	"github.com/aldelucca1/docsend_scraper/scraper/fake"
+	"github.com/aldelucca1/docsend_scraper/store"
+	"github.com/aldelucca1/docsend_scraper/store/memory"
+	"github.com/aldelucca1/docsend_scraper/task"
+)
+
+// failingTask is a task.Task standing in for a capture of the document with
+// id that fails with err
+type failingTask struct {
+	id  string
+	err error
+}
+
+func (t failingTask) ID() string {
+	return t.id
+}
+
+func (t failingTask) Execute(ctx context.Context, status chan<- task.TaskStatus) error {
+	return t.err
+}
+
+func TestCachedTask(t *testing.T) {
+	failed := errors.New("failed")
+	tests := []struct {
+		name    string
+		owner   string
+		status  model.Status
+		err     error
+		cleared bool
+	}{
+		{"complete", testInstance, model.StatusCapturing, nil, false},
+		{"failed", testInstance, model.StatusCapturing, failed, true},
+		{"cancelled", testInstance, model.StatusCancelled, context.Canceled, true},
+		{"cancelled elsewhere", "other", model.StatusCancelled, task.ErrLeased, true},
+		{"taken over", "other", model.StatusCapturing, task.ErrLeased, false},
+	}
+	for _, tt := range tests {
+		datastore := memory.NewStore()
+		os := memory.NewObjectStore()
+		doc, err := datastore.InsertDocument(&model.Document{Owner: "a@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := doc.ID.Hex()
+		if _, err := datastore.AcquireLease(id, tt.owner, time.Now().Add(time.Hour).UnixNano()/int64(time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := datastore.UpdateStatus(id, tt.status, ""); err != nil {
+			t.Fatal(err)
+		}
+
+		prefix := task.CachePrefix(id, 1)
+		sibling := task.CachePrefix(id, 10)
+		for _, key := range []string{path.Join(prefix, "page-0001.json"), path.Join(sibling, "page-0001.json")} {
+			if err := os.Write(key, strings.NewReader("{}")); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		cached := newCachedTask(failingTask{id: id, err: tt.err}, datastore, os, testInstance, prefix)
+		if err := cached.Execute(context.Background(), nil); err != tt.err {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+		}
+		if exists, _ := os.Exists(path.Join(prefix, "page-0001.json")); exists == tt.cleared {
+			t.Errorf("%s: expected the cached pages cleared %v, they exist %v", tt.name, tt.cleared, exists)
+		}
+		if exists, _ := os.Exists(path.Join(sibling, "page-0001.json")); !exists {
+			t.Errorf("%s: expected the cached pages of another version kept", tt.name)
+		}
+	}
+}
+
+// waitForCache waits for the first page of the document's version to be
+// cached or cleared
+func waitForCache(t *testing.T, os store.ObjectStore, id string, version int, cached bool) {
+	key := path.Join(task.CachePrefix(id, version), "page-0001.json")
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if exists, _ := os.Exists(key); exists == cached {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected page 1 of document %s cached %v", id, cached)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCacheClearedOnFailure(t *testing.T) {
+	s := newTestService(t)
+	defer s.close()
+	s.server.AddDocument(fake.NewDocument("deck", 3, ""))
+
+	// The second page never downloads while the others do
+	s.server.Fail(fake.Failure{Path: "/images/deck/2", Status: http.StatusNotFound})
+
+	doc, err := s.GenerateDocument(s.server.DocumentURL("deck").String(), "a@example.com", "", model.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.waitForStatus(t, doc.ID.Hex(), model.StatusError)
+
+	if s.server.Requests("/images/deck/1") == 0 {
+		t.Fatal("expected the first page to be downloaded")
+	}
+	waitForCache(t, s.os, doc.ID.Hex(), 1, false)
+}
+
+func TestCacheClearedOnCancel(t *testing.T) {
+	s := newTestService(t)
+	defer s.close()
+	s.server.AddDocument(fake.NewDocument("deck", 3, ""))
+
+	// Stall the second page so the capture is cancelled part way through
+	s.server.Fail(fake.Failure{Path: "/images/deck/2", Delay: 5 * time.Second})
+
+	doc, err := s.GenerateDocument(s.server.DocumentURL("deck").String(), "a@example.com", "", model.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := doc.ID.Hex()
+	waitForCache(t, s.os, id, 1, true)
+
+	if _, err := s.CancelDocument(id); err != nil {
+		t.Fatal(err)
+	}
+	waitForCache(t, s.os, id, 1, false)
+}
+
+func TestCacheOfReplacedCollectionVersionCleared(t *testing.T) {
+	server := fake.NewServer()
+	server.AddDocument(fake.NewDocument("deck", 2, ""))
+	datastore := memory.NewStore()
+	doc, err := datastore.InsertDocument(&model.Document{
+		Owner:        "a@example.com",
+		CollectionID: "collection",
+		SourceURL:    server.DocumentURL("deck").String(),
+		Adapter:      scraper.DocSendAdapterName,
+		Options:      model.Options{Format: model.FormatPDF, Layout: model.LayoutNative, DPI: 72},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := doc.ID.Hex()
+
+	// The pending document is captured on start, keeping its pages
+	s := newTestServiceWithStore(t, datastore, server)
+	defer s.close()
+	s.waitForStatus(t, id, model.StatusComplete)
+	waitForCache(t, s.os, id, 1, true)
+
+	if _, err := s.RecaptureDocument(id, ""); err != nil {
+		t.Fatal(err)
+	}
+	doc = s.waitForStatus(t, id, model.StatusComplete)
+	if doc.Version != 2 {
+		t.Fatalf("expected version 2, got %d", doc.Version)
+	}
+	waitForCache(t, s.os, id, 1, false)
+	waitForCache(t, s.os, id, 2, true)
+}
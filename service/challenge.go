@@ -0,0 +1,121 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/store"
+	logger "github.com/sirupsen/logrus"
+)
+
+// ErrNotAwaitingInput is returned when answering the challenge of a document
+// whose capture isn't waiting on input
+var ErrNotAwaitingInput = errors.New("Document capture isn't waiting on input")
+
+// AnswerChallenge answers the challenge the capture of the document is
+// waiting on, such as the verification code DocSend emailed to its owner.
+// The capture picks up the answer wherever it's running
+func (s *Service) AnswerChallenge(id string, answer string) (*model.Document, error) {
+
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return nil, &InvalidRequestError{Message: "The code is required"}
+	}
+
+	// Encrypt the answer at rest like passcodes.  Without a secret key it's
+	// stored as it is, it's cleared as soon as the capture takes it
+	stored := answer
+	if s.cipher != nil {
+		encrypted, err := s.cipher.encrypt(answer)
+		if err != nil {
+			return nil, err
+		}
+		stored = encrypted
+	}
+
+	s.statusMutex.Lock()
+	doc, err := s.store.GetDocument(id)
+	if err == nil && doc.Status != model.StatusAwaitingInput {
+		err = ErrNotAwaitingInput
+	}
+	if err == nil {
+		doc, err = s.store.AnswerChallenge(id, stored)
+		if err == store.ErrNotFound {
+			err = ErrNotAwaitingInput
+		}
+	}
+	s.statusMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("Received the answer to the challenge of document %s", id)
+
+	s.pushDocument(doc)
+	return doc, nil
+}
+
+// handleTaskChallenge records that the task is waiting on the user to answer
+// a challenge and prompts them for it
+func (s *Service) handleTaskChallenge(id string, challenge model.Challenge) {
+
+	logger.Infof("Task %s is waiting on input: %s", id, challenge.Message)
+
+	challenge.Created = time.Now().UnixNano() / int64(time.Millisecond)
+	doc, err := s.updateTask(id, func() (*model.Document, error) {
+		return s.store.AwaitInput(id, challenge, challenge.Message)
+	})
+	if err != nil {
+		logger.Errorf("Failed to store document challenge: %s", err.Error())
+		return
+	}
+	if doc != nil {
+		s.pushDocument(doc)
+		s.pushChallenge(doc.Owner, model.ChallengeEvent{ID: id, Challenge: challenge})
+	}
+}
+
+func (s *Service) pushChallenge(owner string, event model.ChallengeEvent) {
+	s.push(owner, model.Message{Type: "CHALLENGE", Data: event})
+}
+
+// documentAnswerer is a task.Answerer reading the answers to challenges from
+// the documents in the Datastore, so they reach captures running on any
+// instance sharing it
+type documentAnswerer struct {
+	store  store.Datastore
+	cipher *passcodeCipher
+
+	// Held while the document is read and its answer taken, so a
+	// cancellation isn't overwritten.  It's the Service's statusMutex
+	mutex *sync.Mutex
+}
+
+// Answer returns the answer to the challenge of the document with the
+// supplied id, or "" if it hasn't been answered yet.  The answer is taken,
+// moving the document back to capturing, so it's never left in the Datastore
+func (a *documentAnswerer) Answer(id string) (string, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	doc, err := a.store.GetDocument(id)
+	if err != nil {
+		return "", err
+	}
+	if doc.Status != model.StatusAwaitingInput || doc.Job.Answer == "" {
+		return "", nil
+	}
+	answer := doc.Job.Answer
+	if a.cipher != nil {
+		if answer, err = a.cipher.decrypt(answer); err != nil {
+			return "", err
+		}
+	}
+	if _, err := a.store.UpdateStatus(id, model.StatusCapturing, ""); err != nil {
+		return "", err
+	}
+	return answer, nil
+}
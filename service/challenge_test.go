@@ -0,0 +1,142 @@
+package service
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/scraper/fake"
+	"github.com/aldelucca1/docsend_scraper/store/memory"
+	"github.com/aldelucca1/docsend_scraper/task"
+)
+
+func TestDocumentAnswerer(t *testing.T) {
+	key, err := newPasscodeCipher("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		cipher *passcodeCipher
+	}{
+		{"plaintext", nil},
+		{"encrypted", key},
+	}
+	for _, tt := range tests {
+		datastore := memory.NewStore()
+		s := &Service{store: datastore, cipher: tt.cipher}
+		answerer := &documentAnswerer{store: datastore, cipher: tt.cipher, mutex: &s.statusMutex}
+
+		doc, err := datastore.InsertDocument(&model.Document{Owner: "a@example.com", SourceURL: "https://docsend.com/view/deck"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := doc.ID.Hex()
+		if _, err := datastore.AwaitInput(id, model.Challenge{Gate: model.GateVerification}, "Enter the code"); err != nil {
+			t.Fatal(err)
+		}
+
+		if answer, err := answerer.Answer(id); answer != "" || err != nil {
+			t.Errorf("%s: expected no answer yet, got %q, %v", tt.name, answer, err)
+		}
+		if _, err := s.AnswerChallenge(id, " 123456 "); err != nil {
+			t.Fatal(err)
+		}
+		stored, _ := datastore.GetDocument(id)
+		if tt.cipher != nil && stored.Job.Answer == "123456" {
+			t.Errorf("%s: expected the answer encrypted at rest", tt.name)
+		}
+
+		// Taking the answer clears it from the datastore
+		if answer, err := answerer.Answer(id); answer != "123456" || err != nil {
+			t.Errorf("%s: expected the answer, got %q, %v", tt.name, answer, err)
+		}
+		stored, _ = datastore.GetDocument(id)
+		if stored.Job.Answer != "" || stored.Challenge != nil || stored.Status != model.StatusCapturing {
+			t.Errorf("%s: expected the answer taken and the capture resumed, got %s with %+v", tt.name, stored.Status, stored.Job)
+		}
+		if answer, _ := answerer.Answer(id); answer != "" {
+			t.Errorf("%s: expected the answer to be taken once, got %q", tt.name, answer)
+		}
+		if _, err := s.AnswerChallenge(id, "654321"); err != ErrNotAwaitingInput {
+			t.Errorf("%s: expected ErrNotAwaitingInput once resumed, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestDocumentAnswererCancelled(t *testing.T) {
+	datastore := memory.NewStore()
+	answerer := &documentAnswerer{store: datastore, mutex: new(sync.Mutex)}
+
+	doc, err := datastore.InsertDocument(&model.Document{Owner: "a@example.com", SourceURL: "https://docsend.com/view/deck"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := doc.ID.Hex()
+	datastore.AwaitInput(id, model.Challenge{Gate: model.GateVerification}, "Enter the code")
+	datastore.AnswerChallenge(id, "123456")
+	datastore.UpdateStatus(id, model.StatusCancelled, "Cancelled by request")
+
+	if answer, _ := answerer.Answer(id); answer != "" {
+		t.Errorf("expected no answer for a cancelled capture, got %q", answer)
+	}
+	if doc, _ := datastore.GetDocument(id); doc.Status != model.StatusCancelled {
+		t.Errorf("expected the document to stay cancelled, got %s", doc.Status)
+	}
+}
+
+func TestVerificationChallenge(t *testing.T) {
+	interval := task.AnswerPollInterval
+	task.AnswerPollInterval = 10 * time.Millisecond
+	defer func() {
+		task.AnswerPollInterval = interval
+	}()
+
+	s := newTestService(t)
+	defer s.close()
+	doc := fake.NewDocument("deck", 2, "")
+	doc.EmailOnly = true
+	doc.VerificationCode = "123456"
+	s.server.AddDocument(doc)
+
+	generated, err := s.GenerateDocument(s.server.DocumentURL("deck").String(), "a@example.com", "", model.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := generated.ID.Hex()
+
+	awaiting := s.waitForStatus(t, id, model.StatusAwaitingInput)
+	if awaiting.Challenge == nil || awaiting.Challenge.Gate != model.GateVerification {
+		t.Fatalf("expected a verification challenge, got %+v", awaiting.Challenge)
+	}
+
+	// A rejected code is asked for again
+	if _, err := s.AnswerChallenge(id, "000000"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		doc, _ := s.GetDocument(id)
+		if doc.Status == model.StatusAwaitingInput && doc.Challenge.Attempt == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the code to be asked for again, got %s with %+v", doc.Status, doc.Challenge)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := s.AnswerChallenge(id, "123456"); err != nil {
+		t.Fatal(err)
+	}
+	completed := s.waitForStatus(t, id, model.StatusComplete, model.StatusError)
+	if completed.Status != model.StatusComplete {
+		t.Fatalf("expected the capture to complete, got %s: %+v", completed.Status, completed.StatusDetails)
+	}
+	stored, _ := s.store.GetDocument(id)
+	if stored.Job.Answer != "" {
+		t.Error("expected no answer left in the datastore")
+	}
+}
@@ -23,9 +23,12 @@ func NewClient(ws *websocket.Conn) Client {
 	return Client{ws, ch, close}
 }
 
+// listen writes the messages sent to the client and reads those it sends
+// until the connection is closed
 func (c *Client) listen() {
 	go c.listenToWrite()
 	c.listenToRead()
+	close(c.close)
 }
 
 func (c *Client) listenToWrite() {
@@ -36,31 +39,38 @@ func (c *Client) listenToWrite() {
 			websocket.JSON.Send(c.connection, msg)
 
 		case <-c.close:
-			c.close <- true
 			return
 		}
 	}
 }
 
+// listenToRead reads messages from the client until the connection is closed
+// or fails
 func (c *Client) listenToRead() {
 	logger.Debug("Listening read from client")
 	for {
-		select {
-		case <-c.close:
-			c.close <- true
+		var msg model.Message
+		err := websocket.JSON.Receive(c.connection, &msg)
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			logger.Debugf("Failed to read from client: %s", err.Error())
 			return
-
-		default:
-			var msg model.Message
-			err := websocket.JSON.Receive(c.connection, &msg)
-			logger.Debugf("Received: %+v", msg)
-			if err == io.EOF {
-				c.close <- true
-			} else if err != nil {
-				// c.server.Err(err)
-			} else if msg.Type == "PING" {
-				c.ch <- model.Message{Type: "PONG", Data: nil}
-			}
 		}
+		logger.Debugf("Received: %+v", msg)
+		if msg.Type == "PING" {
+			c.send(model.Message{Type: "PONG", Data: nil})
+		}
+	}
+}
+
+// send queues the message to be written to the client, dropping it if the
+// client isn't keeping up, and reports whether it was queued
+func (c *Client) send(msg model.Message) bool {
+	select {
+	case c.ch <- msg:
+		return true
+	default:
+		return false
 	}
 }
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"golang.org/x/net/websocket"
+)
+
+// receive reads the next message sent to the websocket client
+func receive(t *testing.T, ws *websocket.Conn) model.Message {
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg model.Message
+	if err := websocket.JSON.Receive(ws, &msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// connected reports whether the owner has a client connected
+func (s *Service) connected(owner string) bool {
+	s.connectionsMutex.RLock()
+	defer s.connectionsMutex.RUnlock()
+	_, ok := s.connections[owner]
+	return ok
+}
+
+func TestClientConnection(t *testing.T) {
+	s := &Service{connections: make(map[string]*Client)}
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		s.AddClientConnection("a@example.com", conn)
+	}))
+	defer server.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http"), "", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	if msg := receive(t, ws); msg.Type != "PING" {
+		t.Errorf("expected a PING on connecting, got %s", msg.Type)
+	}
+	if err := websocket.JSON.Send(ws, model.Message{Type: "PING"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(t, ws); msg.Type != "PONG" {
+		t.Errorf("expected a PONG, got %s", msg.Type)
+	}
+
+	s.pushProgress("a@example.com", model.ProgressUpdate{ID: "doc"})
+	s.pushProgress("b@example.com", model.ProgressUpdate{ID: "other"})
+	if msg := receive(t, ws); msg.Type != "PROGRESS" {
+		t.Errorf("expected the owner's progress, got %s", msg.Type)
+	}
+
+	// Closing the connection removes the client
+	ws.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.connected("a@example.com") {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the closed client to be removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPushSlowClient(t *testing.T) {
+	s := &Service{connections: make(map[string]*Client)}
+
+	tests := []struct {
+		name string
+		push func()
+	}{
+		{"progress", func() { s.pushProgress("a@example.com", model.ProgressUpdate{ID: "doc"}) }},
+		{"document", func() { s.pushDocument(&model.Document{Owner: "a@example.com"}) }},
+		{"change", func() { s.pushChange("a@example.com", model.ChangeEvent{ID: "doc"}) }},
+		{"revoked", func() { s.pushRevoked("a@example.com", model.ChangeEvent{ID: "doc"}) }},
+	}
+	for _, tt := range tests {
+		client := &Client{ch: make(chan model.Message, 2)}
+		s.connections["a@example.com"] = client
+
+		// Pushing to a client that isn't reading drops messages rather
+		// than blocking the capture or the watch scheduler
+		done := make(chan bool)
+		go func() {
+			for i := 0; i < 10; i++ {
+				tt.push()
+			}
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: expected pushing to a slow client not to block", tt.name)
+		}
+		if n := len(client.ch); n != 2 {
+			t.Errorf("%s: expected the client's buffer to be filled, got %d messages", tt.name, n)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/scraper"
+	"github.com/aldelucca1/docsend_scraper/task"
+	logger "github.com/sirupsen/logrus"
+)
+
+// ErrCollectionIncomplete is returned when downloading a collection whose
+// documents haven't all been captured
+var ErrCollectionIncomplete = errors.New("Not every document in the collection has been captured")
+
+// GenerateCollection lists the documents in the DocSend space at the source
+// url and captures each of them as a document of the collection.  The space is
+// listed before returning so an invalid link or passcode is reported
+// immediately
+func (s *Service) GenerateCollection(ctx context.Context, urlStr string, email string, passcode string, options model.Options) (*model.Collection, error) {
+
+	url, adapter, err := parseSourceURL(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	if !scraper.IsSpace(url) {
+		return nil, &InvalidRequestError{Message: "The URL doesn't link to a space"}
+	}
+
+	options, err = s.documentOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	lister := scraper.NewScraper(s.os)
+	lister.Options.Adapter = adapter.Name()
+	space, err := lister.ListSpace(ctx, url, email, passcode)
+	if err == scraper.ErrAuthenticationFailed {
+		return nil, &InvalidRequestError{Message: err.Error()}
+	}
+	if e, ok := err.(*scraper.HTTPError); ok {
+		return nil, &InvalidRequestError{Message: e.Error()}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(space.Documents) == 0 {
+		return nil, &InvalidRequestError{Message: "The space has no documents"}
+	}
+
+	collection := &model.Collection{
+		Owner:     email,
+		SourceURL: url.String(),
+		Adapter:   adapter.Name(),
+		Title:     space.Title,
+		Options:   options,
+	}
+	collection, err = s.store.InsertCollection(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("Capturing %d documents of collection %s", len(space.Documents), collection.ID.Hex())
+
+	collection.Documents = make([]*model.Document, 0, len(space.Documents))
+	for _, listed := range space.Documents {
+		doc := &model.Document{
+			Owner:        email,
+			SourceURL:    listed.URL.String(),
+			Adapter:      adapter.Name(),
+			Title:        listed.Title,
+			CollectionID: collection.ID.Hex(),
+			Options:      options,
+		}
+		if err := s.setPasscode(doc, passcode); err != nil {
+			return nil, err
+		}
+		doc, err = s.store.InsertDocument(doc)
+		if err != nil {
+			return nil, err
+		}
+		s.pushDocument(doc)
+		s.dispatch(doc, listed.URL, passcode)
+		collection.Documents = append(collection.Documents, doc)
+	}
+	collection.Status = model.CollectionStatus(collection.Documents)
+
+	return collection, nil
+}
+
+// ListCollections lists the collections for the given user along with their
+// documents
+func (s *Service) ListCollections(user string) ([]*model.Collection, error) {
+	collections, err := s.store.GetCollections(user)
+	if err != nil {
+		return nil, err
+	}
+	for _, collection := range collections {
+		if err := s.loadDocuments(collection); err != nil {
+			return nil, err
+		}
+	}
+	return collections, nil
+}
+
+// GetCollection gets the collection along with its documents
+func (s *Service) GetCollection(id string) (*model.Collection, error) {
+	collection, err := s.store.GetCollection(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadDocuments(collection); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+// loadDocuments reads the collection's documents, setting its status from
+// them
+func (s *Service) loadDocuments(collection *model.Collection) error {
+	docs, err := s.store.GetCollectionDocuments(collection.ID.Hex())
+	if err != nil {
+		return err
+	}
+	collection.Documents = docs
+	collection.Status = model.CollectionStatus(docs)
+	return nil
+}
+
+// DownloadCollection reads the latest version of each document in the
+// collection, either as a ZIP of the documents or as a single PDF merged from
+// their pages.  Every document must have been captured
+func (s *Service) DownloadCollection(id string, format model.Format) (*model.Collection, io.Reader, error) {
+
+	if format != model.FormatZIP && format != model.FormatPDF {
+		return nil, nil, &InvalidRequestError{Message: "format must be zip or pdf"}
+	}
+
+	collection, err := s.GetCollection(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if collection.Status != model.StatusComplete {
+		return nil, nil, ErrCollectionIncomplete
+	}
+
+	logger.Infof("Download collection %s as %s", id, format)
+
+	if format == model.FormatPDF {
+		reader, err := s.mergeCollection(collection)
+		return collection, reader, err
+	}
+	return collection, s.zipCollection(collection), nil
+}
+
+// zipCollection streams a ZIP of the latest version of each document in the
+// collection, numbered in the order they're listed in the space
+func (s *Service) zipCollection(collection *model.Collection) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		archive := zip.NewWriter(pw)
+		for i, doc := range collection.Documents {
+			if err := s.zipDocument(archive, doc, i); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(archive.Close())
+	}()
+	return pr
+}
+
+// zipDocument copies the latest version of the document into the archive
+func (s *Service) zipDocument(archive *zip.Writer, doc *model.Document, index int) error {
+	reader, err := s.os.Read(doc.OutputPath())
+	if err != nil {
+		return err
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	name := fmt.Sprintf("%02d-%s.%s", index+1, documentSlug(doc), doc.Options.Format.Extension())
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, reader)
+	return err
+}
+
+// mergeCollection generates a single PDF from the cached pages of the latest
+// version of each document in the collection
+func (s *Service) mergeCollection(collection *model.Collection) (io.Reader, error) {
+
+	sources := make([]scraper.MergeSource, len(collection.Documents))
+	for i, doc := range collection.Documents {
+		if doc.Capture == nil {
+			return nil, ErrCollectionIncomplete
+		}
+		title := doc.Title
+		if title == "" {
+			title = documentSlug(doc)
+		}
+		sources[i] = scraper.MergeSource{
+			Title:       title,
+			CachePrefix: task.CachePrefix(doc.ID.Hex(), doc.Version),
+			Pages:       doc.Capture.Pages,
+			Optimized:   doc.Capture.Optimization != nil,
+		}
+	}
+
+	source, err := url.Parse(collection.SourceURL)
+	if err != nil {
+		return nil, err
+	}
+	meta := scraper.Metadata{
+		Title:    collection.Title,
+		Source:   source,
+		Author:   collection.Owner,
+		Captured: time.Unix(0, collection.Created*int64(time.Millisecond)),
+	}
+
+	m := scraper.NewScraper(s.os)
+	m.Options = s.scraperOptions(collection.Options)
+	m.Cache = s.os
+	pdf, err := m.Merge(meta, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(pdf.Output(pw))
+	}()
+	return pr, nil
+}
+
+// documentSlug returns the last element of the document's source URL
+func documentSlug(doc *model.Document) string {
+	source, err := url.Parse(doc.SourceURL)
+	if err != nil {
+		return doc.ID.Hex()
+	}
+	return path.Base(source.Path)
+}
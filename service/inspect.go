@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+
+	"github.com/aldelucca1/docsend_scraper/scraper"
+	logger "github.com/sirupsen/logrus"
+)
+
+// InspectLink opens the link at the source url and reports what's behind it,
+// its gate, title and page count and whether it looks revoked, without
+// capturing it.  Nothing is stored, so any link can be checked before it's
+// captured
+func (s *Service) InspectLink(ctx context.Context, urlStr string, email string, passcode string) (*scraper.Inspection, error) {
+
+	url, adapter, err := parseSourceURL(urlStr)
+	if err != nil {
+		return nil, err
+	}
+
+	inspector := scraper.NewScraper(s.os)
+	inspector.Options.Adapter = adapter.Name()
+	inspection, err := inspector.Inspect(ctx, url, email, passcode)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("Inspected %s: gate %s, revoked %t", inspection.URL, inspection.Gate, inspection.Revoked)
+	return inspection, nil
+}
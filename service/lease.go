@@ -0,0 +1,33 @@
+package service
+
+import (
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/store"
+	"github.com/aldelucca1/docsend_scraper/task"
+	logger "github.com/sirupsen/logrus"
+)
+
+// documentLeaser is a task.Leaser that records leases on the documents in the
+// Datastore, so captures are exclusive across every instance sharing it
+type documentLeaser struct {
+	store store.Datastore
+	owner string
+}
+
+// Acquire or renew the lease on the document with the supplied id
+func (l *documentLeaser) Acquire(id string, ttl time.Duration) error {
+	expires := time.Now().Add(ttl).UnixNano() / int64(time.Millisecond)
+	_, err := l.store.AcquireLease(id, l.owner, expires)
+	if err == store.ErrLeaseHeld {
+		return task.ErrLeased
+	}
+	return err
+}
+
+// Release the lease on the document with the supplied id
+func (l *documentLeaser) Release(id string) {
+	if err := l.store.ReleaseLease(id, l.owner); err != nil {
+		logger.Warnf("Failed to release lease on document %s: %s", id, err.Error())
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+)
+
+// errPasscodeUnavailable is returned when a document needs a passcode that
+// wasn't persisted, either because no secret key is configured or it changed
+var errPasscodeUnavailable = errors.New("passcode unavailable")
+
+// passcodeCipher encrypts passcodes at rest with AES-256-GCM using a key
+// derived from the configured secret
+type passcodeCipher struct {
+	aead cipher.AEAD
+}
+
+// newPasscodeCipher creates a passcodeCipher for the supplied secret.  Returns
+// nil if the secret is empty, in which case passcodes aren't persisted
+func newPasscodeCipher(secret string) (*passcodeCipher, error) {
+	if secret == "" {
+		return nil, nil
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &passcodeCipher{aead: aead}, nil
+}
+
+// encrypt seals the plaintext, returning the base64 encoded nonce and
+// ciphertext
+func (c *passcodeCipher) encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a value produced by encrypt
+func (c *passcodeCipher) decrypt(encoded string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	n := c.aead.NonceSize()
+	if len(sealed) < n {
+		return "", errPasscodeUnavailable
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return "", errPasscodeUnavailable
+	}
+	return string(plaintext), nil
+}
+
+// setPasscode records the passcode on the document's job, encrypted if a
+// secret key is configured
+func (s *Service) setPasscode(doc *model.Document, passcode string) error {
+	doc.Job.HasPasscode = passcode != ""
+	if passcode == "" || s.cipher == nil {
+		return nil
+	}
+	encrypted, err := s.cipher.encrypt(passcode)
+	if err != nil {
+		return err
+	}
+	doc.Job.Passcode = encrypted
+	return nil
+}
+
+// getPasscode recovers the passcode from the document's job
+func (s *Service) getPasscode(doc *model.Document) (string, error) {
+	if !doc.Job.HasPasscode {
+		return "", nil
+	}
+	if doc.Job.Passcode == "" || s.cipher == nil {
+		return "", errPasscodeUnavailable
+	}
+	return s.cipher.decrypt(doc.Job.Passcode)
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"os"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/scraper"
+	logger "github.com/sirupsen/logrus"
+)
+
+// loadRules loads the configured rules file, returning its modification time
+// so changes to it can be detected
+func (s *Service) loadRules() (time.Time, error) {
+	path := s.config.Scraper.RulesFile
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	rules, err := scraper.LoadRules(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	scraper.SetRules(rules)
+
+	logger.Infof("Loaded extraction rules from %s", path)
+	return info.ModTime(), nil
+}
+
+// rulesReloader - A go routine responsible for reloading the rules file when
+// it changes.  Rules that fail to load are logged and the rules already
+// loaded kept in use
+//
+// To stop this go routine, pass a stopped chan to the stopRulesChannel. When
+// this routine completes it will notify the passed stopped channel
+func (s *Service) rulesReloader(modified time.Time) {
+
+	ticker := time.NewTicker(s.config.Scraper.RulesReloadInterval)
+	defer ticker.Stop()
+
+	path := s.config.Scraper.RulesFile
+	for {
+		select {
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil {
+				logger.Errorf("Failed to check rules file %s: %s", path, err.Error())
+				continue
+			}
+			if info.ModTime().Equal(modified) {
+				continue
+			}
+			modified = info.ModTime()
+
+			if _, err := s.loadRules(); err != nil {
+				logger.Errorf("Failed to reload rules file %s, keeping the current rules: %s", path, err.Error())
+			}
+
+		case stoppedChan := <-s.stopRulesChannel:
+			stoppedChan <- true
+			return
+		}
+	}
+}
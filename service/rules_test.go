@@ -0,0 +1,106 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/scraper"
+	"github.com/aldelucca1/docsend_scraper/scraper/fake"
+	"github.com/aldelucca1/docsend_scraper/store/memory"
+)
+
+// testRules is a rules file with a single docsend rule set named NAME
+const testRules = `version: 1
+adapters:
+  docsend:
+    - name: NAME
+      auth_form: form.new_link_auth_form
+      email_field: link_auth_form[email]
+      passcode_field: link_auth_form[passcode]
+      page: div.item img.page-view
+      page_url_attribute: data-url
+      page_data:
+        image_url: imageUrl
+`
+
+// writeRules writes the rules file, dating it so a reload notices the change
+func writeRules(t *testing.T, path string, data string, modified time.Time) {
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitForRules waits for the named docsend rule set to be in use
+func waitForRules(t *testing.T, name string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sets := scraper.CurrentRules().For(scraper.DocSendAdapterName)
+		if sets[0].Name == name {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the %s rules to be loaded, got %s", name, sets[0].Name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRulesReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer scraper.SetRules(nil)
+
+	path := filepath.Join(dir, "rules.yaml")
+	modified := time.Now().Add(-time.Hour)
+	writeRules(t, path, strings.Replace(testRules, "NAME", "first", 1), modified)
+
+	cfg := testConfig()
+	cfg.Scraper.RulesFile = path
+	cfg.Scraper.RulesReloadInterval = 10 * time.Millisecond
+	s := startTestService(t, cfg, memory.NewStore(), fake.NewServer())
+	defer s.close()
+
+	// The rules are loaded before the service starts
+	waitForRules(t, "first")
+
+	// Rules that fail to load leave those loaded in use
+	modified = modified.Add(time.Minute)
+	writeRules(t, path, strings.Replace(testRules, "NAME", "", 1), modified)
+	time.Sleep(100 * time.Millisecond)
+	waitForRules(t, "first")
+
+	// Fixed rules are picked up
+	modified = modified.Add(time.Minute)
+	writeRules(t, path, strings.Replace(testRules, "NAME", "second", 1), modified)
+	waitForRules(t, "second")
+}
+
+func TestStartInvalidRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer scraper.SetRules(nil)
+
+	path := filepath.Join(dir, "rules.yaml")
+	writeRules(t, path, "version: 2\n", time.Now())
+
+	cfg := testConfig()
+	cfg.Scraper.RulesFile = path
+	s := NewServiceWithStores(cfg, memory.NewStore(), memory.NewObjectStore())
+	if err := s.Start(); err == nil {
+		s.Stop()
+		t.Error("expected the service not to start with invalid rules")
+	}
+}
@@ -5,42 +5,104 @@ import (
 	"fmt"
 	"io"
 	"net/url"
-	"path"
+	"sync"
+	"time"
 
+	"github.com/aldelucca1/docsend_scraper/config"
 	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/scraper"
 	"github.com/aldelucca1/docsend_scraper/store"
+	"github.com/aldelucca1/docsend_scraper/store/boltdb"
 	"github.com/aldelucca1/docsend_scraper/store/fs"
+	"github.com/aldelucca1/docsend_scraper/store/memory"
 	"github.com/aldelucca1/docsend_scraper/store/mongo"
+	"github.com/aldelucca1/docsend_scraper/store/s3"
 	"github.com/aldelucca1/docsend_scraper/task"
 	logger "github.com/sirupsen/logrus"
 	"golang.org/x/net/websocket"
 )
 
+// ErrNotCancellable is returned when cancelling a document whose capture has
+// already finished
+var ErrNotCancellable = errors.New("Document capture has already finished")
+
+// ErrCaptureInProgress is returned when capturing a new version of a document
+// that is still being captured
+var ErrCaptureInProgress = errors.New("Document is already being captured")
+
+// InvalidRequestError is returned when the arguments of a request are invalid
+type InvalidRequestError struct {
+	Message string
+}
+
+func (e *InvalidRequestError) Error() string {
+	return e.Message
+}
+
 // Service is a controller for handling inbound requests
 type Service struct {
+	config            *config.Config
 	store             store.Datastore
 	os                store.ObjectStore
 	dispatcher        *task.NonBlockingDispatcher
+	leaser            *documentLeaser
+	answerer          *documentAnswerer
+	cipher            *passcodeCipher
+	statusMutex       sync.Mutex
 	stopStatusChannel chan chan bool
-	connections       map[string]Client
+	stopWatchChannel  chan chan bool
+	stopRulesChannel  chan chan bool
+	connectionsMutex  sync.RWMutex
+	connections       map[string]*Client
 }
 
 // NewService creates a new intialized instance of a Service
-func NewService() *Service {
+func NewService(config *config.Config) *Service {
+	return NewServiceWithStores(config, createStore(config.Datastore), createObjectStore(config.ObjectStore))
+}
+
+// NewServiceWithStores creates a new intialized instance of a Service backed
+// by the supplied Datastore and ObjectStore
+func NewServiceWithStores(config *config.Config, store store.Datastore, os store.ObjectStore) *Service {
 	svc := new(Service)
-	svc.store = createStore()
-	svc.os = fs.NewStore(fs.NewConfig())
-	svc.dispatcher = task.NewNonBlockingDispatcher(10)
-	svc.connections = make(map[string]Client)
+	svc.config = config
+	svc.store = store
+	svc.os = os
+	svc.dispatcher = task.NewNonBlockingDispatcher(config.Dispatcher.Workers)
+	svc.leaser = &documentLeaser{store: store, owner: config.Queue.InstanceID}
+
+	scraper.SetMaxHostConnections(config.Scraper.MaxHostConnections)
+
+	cipher, err := newPasscodeCipher(config.Queue.SecretKey)
+	if err != nil {
+		logger.Errorf("Failed to create passcode cipher, passcodes won't be persisted: %s", err.Error())
+	}
+	svc.cipher = cipher
+	svc.answerer = &documentAnswerer{store: store, cipher: cipher, mutex: &svc.statusMutex}
+
+	svc.connections = make(map[string]*Client)
 	return svc
 }
 
-// AddClientConnection adds a new client connection
+// AddClientConnection adds a new client connection, serving it until it's
+// closed.  A later connection for the same owner replaces it
 func (s *Service) AddClientConnection(owner string, conn *websocket.Conn) {
 	defer conn.Close()
 	client := NewClient(conn)
-	s.connections[owner] = client
-	client.ch <- model.Message{Type: "PING", Data: nil}
+
+	s.connectionsMutex.Lock()
+	s.connections[owner] = &client
+	s.connectionsMutex.Unlock()
+
+	defer func() {
+		s.connectionsMutex.Lock()
+		if s.connections[owner] == &client {
+			delete(s.connections, owner)
+		}
+		s.connectionsMutex.Unlock()
+	}()
+
+	client.send(model.Message{Type: "PING", Data: nil})
 	client.listen()
 }
 
@@ -48,6 +110,20 @@ func (s *Service) AddClientConnection(owner string, conn *websocket.Conn) {
 // store and starting our task dispatcher
 func (s *Service) Start() error {
 
+	// Load the extraction rules before anything is captured with them
+	var rulesModified time.Time
+	if s.config.Scraper.RulesFile != "" {
+		modified, err := s.loadRules()
+		if err != nil {
+			return err
+		}
+		rulesModified = modified
+	}
+
+	if s.cipher == nil {
+		logger.Warn("No queue secret key configured, set queue.secret_key or QUEUE_SECRET_KEY so captures needing a passcode can be resumed after a restart")
+	}
+
 	err := s.store.Connect()
 	if err != nil {
 		return err
@@ -58,13 +134,51 @@ func (s *Service) Start() error {
 	// Start our Dispatcher
 	s.dispatcher.Start()
 	go s.dispatcherStatusHandler()
+
+	// Resume any captures interrupted by a previous shutdown before any new
+	// capture can be requested, so only interrupted captures are resumed
+	s.recoverTasks()
+
+	// Start checking watched documents
+	s.stopWatchChannel = make(chan chan bool, 1)
+	go s.watchScheduler()
+
+	// Reload the extraction rules as they change
+	if s.config.Scraper.RulesFile != "" {
+		s.stopRulesChannel = make(chan chan bool, 1)
+		go s.rulesReloader(rulesModified)
+	}
 	return nil
 }
 
+// Open connects to the datastore alone, for reading documents without
+// capturing any, e.g. from the command line.  Nothing is queued, resumed or
+// checked, so it never takes over the captures of a running instance.  A
+// Service opened this way is closed with Close rather than Stop
+func (s *Service) Open() error {
+	return s.store.Connect()
+}
+
+// Close closes the connection to the datastore of a Service opened with Open
+func (s *Service) Close() {
+	s.store.Close()
+}
+
 // Stop stops the Dispatcher, waiting for any in flight work to complete and
 // closes the connection to the underlying datastore
 func (s *Service) Stop() {
 
+	// Stop the scheduler first so no checks are dispatched while stopping
+	watchStopped := make(chan bool, 1)
+	s.stopWatchChannel <- watchStopped
+	<-watchStopped
+
+	if s.stopRulesChannel != nil {
+		rulesStopped := make(chan bool, 1)
+		s.stopRulesChannel <- rulesStopped
+		<-rulesStopped
+	}
+
 	if s.dispatcher != nil {
 		s.dispatcher.Stop()
 	}
@@ -76,9 +190,45 @@ func (s *Service) Stop() {
 	s.store.Close()
 }
 
-func createStore() store.Datastore {
-	config := mongo.NewConfig()
-	return mongo.NewStore(config)
+// createStore creates the configured Datastore
+func createStore(c config.DatastoreConfig) store.Datastore {
+	switch c.Type {
+	case "bolt":
+		cfg := boltdb.NewConfig().
+			WithPath(c.Bolt.Path)
+		return boltdb.NewStore(cfg)
+	case "memory":
+		return memory.NewStore()
+	default:
+		cfg := mongo.NewConfig().
+			WithEndpoints(c.Mongo.Endpoints).
+			WithReplicaSet(c.Mongo.ReplicaSet).
+			WithDatabase(c.Mongo.Database).
+			WithCredentials(c.Mongo.Username, c.Mongo.Password)
+		return mongo.NewStore(cfg)
+	}
+}
+
+// createObjectStore creates the configured ObjectStore
+func createObjectStore(c config.ObjectStoreConfig) store.ObjectStore {
+	switch c.Type {
+	case "memory":
+		return memory.NewObjectStore()
+	case "s3":
+		cfg := s3.NewConfig().
+			WithEndpoint(c.S3.Endpoint).
+			WithRegion(c.S3.Region).
+			WithSecure(!c.S3.DisableSSL).
+			WithBucket(c.S3.Bucket).
+			WithPrefix(c.S3.Prefix).
+			WithPartSize(c.S3.PartSize).
+			WithCredentials(c.S3.AccessKey, c.S3.SecretKey)
+		return s3.NewStore(cfg)
+	default:
+		cfg := fs.NewConfig().
+			WithOutputPath(c.FS.OutputPath)
+		return fs.NewStore(cfg)
+	}
 }
 
 // dispatcherStatusHandler - A go routine reponsible for listening for events
@@ -128,44 +278,183 @@ func (s *Service) dispatcherStatusHandler() {
 
 func (s *Service) handleTaskStatus(status task.TaskStatus) {
 
+	if status.Progress != nil {
+		s.handleTaskProgress(status.Task.ID(), *status.Progress)
+		return
+	}
+	if status.Capture != nil {
+		s.handleTaskCapture(status.Task.ID(), *status.Capture)
+		return
+	}
+	if status.Unchanged {
+		s.handleTaskUnchanged(status.Task.ID())
+		return
+	}
+	if status.Challenge != nil {
+		s.handleTaskChallenge(status.Task.ID(), *status.Challenge)
+		return
+	}
+
 	logger.Infof("Task %s has updated its status: %s", status.Task.ID(), status.Message)
 
-	doc, err := s.store.UpdateStatus(status.Task.ID(), model.StatusCapturing, status.Message)
+	doc, err := s.updateTaskStatus(status.Task.ID(), model.StatusCapturing, status.Message)
 	if err != nil {
 		logger.Errorf("Failed to store document status update: %s", err.Error())
 		return
 	}
-	s.pushDocument(doc)
+	if doc != nil {
+		s.pushDocument(doc)
+	}
+}
+
+func (s *Service) handleTaskProgress(id string, progress model.Progress) {
+
+	logger.Debugf("Task %s progress: %s %d/%d pages, %d bytes", id, progress.Phase, progress.PagesDone, progress.PagesTotal, progress.Bytes)
+
+	doc, err := s.updateTask(id, func() (*model.Document, error) {
+		return s.store.UpdateProgress(id, progress)
+	})
+	if err != nil {
+		logger.Errorf("Failed to store document progress update: %s", err.Error())
+		return
+	}
+	if doc != nil {
+		s.pushProgress(doc.Owner, model.ProgressUpdate{ID: id, Progress: progress})
+	}
+}
+
+func (s *Service) handleTaskCapture(id string, capture model.Capture) {
+
+	logger.Infof("Task %s produced a %d page document of %d bytes", id, capture.Pages, capture.Size)
+
+	var changes *model.Changes
+	var replaced int
+	doc, err := s.updateTask(id, func() (*model.Document, error) {
+		doc, err := s.store.GetDocument(id)
+		if err != nil {
+			return nil, err
+		}
+		replaced = doc.Version
+
+		number := doc.NextVersion()
+		version := model.Version{
+			Number:  number,
+			Path:    doc.VersionPath(number),
+			Created: time.Now().UnixNano() / int64(time.Millisecond),
+			Capture: capture,
+		}
+
+		// Compare the pages with the previous version
+		if previous, ok := doc.GetVersion(doc.Version); ok && previous.Capture.PageHashes != nil {
+			c := model.CompareVersions(previous.Capture.PageHashes, capture.PageHashes)
+			version.Changes = &c
+			if !c.Empty() {
+				changes = &c
+			}
+			logger.Infof("Version %d of document %s has %d pages added, %d removed and %d changed", number, id, len(c.Added), len(c.Removed), len(c.Changed))
+		}
+		return s.store.AddVersion(id, version)
+	})
+	if err != nil {
+		logger.Errorf("Failed to store document version: %s", err.Error())
+		return
+	}
+
+	// Only the pages of the latest version of a document in a collection
+	// are merged, those kept for the version it replaces aren't needed
+	if doc != nil && doc.CollectionID != "" && replaced > 0 {
+		clearCache(s.os, task.CachePrefix(id, replaced))
+	}
+	if doc != nil && changes != nil {
+		s.pushChange(doc.Owner, model.ChangeEvent{ID: id, Version: doc.Version, Changes: changes})
+	}
 }
 
 func (s *Service) handleTaskComplete(task task.Task) {
 
 	logger.Infof("Task %s completed successfully", task.ID())
 
-	doc, err := s.store.UpdateStatus(task.ID(), model.StatusComplete, "Completed successfully")
+	doc, err := s.updateTaskStatus(task.ID(), model.StatusComplete, "Completed successfully")
 	if err != nil {
 		logger.Errorf("Failed to store document status update: %s", err.Error())
 		return
 	}
-	s.pushDocument(doc)
+	if doc != nil {
+		s.pushDocument(doc)
+	}
 }
 
 func (s *Service) handleTaskError(taskerror task.Failure) {
 
 	logger.Infof("Task %s failed with error: %s", taskerror.Task.ID(), taskerror.Error.Error())
 
-	doc, err := s.store.UpdateStatus(taskerror.Task.ID(), model.StatusError, fmt.Sprintf("Failed with error: %s", taskerror.Error.Error()))
+	if scraper.Revoked(taskerror.Error) {
+		if s.handleRevoked(taskerror.Task.ID(), taskerror.Error) {
+			return
+		}
+	}
+
+	doc, err := s.updateTaskStatus(taskerror.Task.ID(), model.StatusError, fmt.Sprintf("Failed with error: %s", taskerror.Error.Error()))
 	if err != nil {
 		logger.Errorf("Failed to store document status update: %s", err.Error())
 		return
 	}
-	s.pushDocument(doc)
+	if doc != nil {
+		s.pushDocument(doc)
+	}
 }
 
-func (s *Service) pushDocument(doc *model.Document) {
-	if conn, ok := s.connections[doc.Owner]; ok {
-		conn.ch <- model.Message{Type: "UPDATE", Data: doc}
+// updateTaskStatus stores a status update reported by a task
+func (s *Service) updateTaskStatus(id string, status model.Status, message string) (*model.Document, error) {
+	return s.updateTask(id, func() (*model.Document, error) {
+		return s.store.UpdateStatus(id, status, message)
+	})
+}
+
+// updateTask applies an update reported by a task.  Updates for documents
+// that have been cancelled are dropped, returning a nil document
+func (s *Service) updateTask(id string, update func() (*model.Document, error)) (*model.Document, error) {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	doc, err := s.store.GetDocument(id)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Status == model.StatusCancelled {
+		return nil, nil
+	}
+	return update()
+}
+
+// push sends the message to the owner's client if one is connected.  It never
+// blocks, a message is dropped if the client isn't keeping up
+func (s *Service) push(owner string, msg model.Message) {
+	s.connectionsMutex.RLock()
+	client, ok := s.connections[owner]
+	s.connectionsMutex.RUnlock()
+	if !ok {
+		return
 	}
+	if !client.send(msg) {
+		logger.Debugf("Dropped %s message for slow client of %s", msg.Type, owner)
+	}
+}
+
+func (s *Service) pushDocument(doc *model.Document) {
+	s.push(doc.Owner, model.Message{Type: "UPDATE", Data: doc})
+}
+
+func (s *Service) pushProgress(owner string, update model.ProgressUpdate) {
+	s.push(owner, model.Message{Type: "PROGRESS", Data: update})
+}
+
+func (s *Service) pushChange(owner string, event model.ChangeEvent) {
+	s.push(owner, model.Message{Type: "CHANGED", Data: event})
+}
+
+func (s *Service) pushRevoked(owner string, event model.ChangeEvent) {
+	s.push(owner, model.Message{Type: "REVOKED", Data: event})
 }
 
 // ListDocuments lists the set of document metadata for the given user
@@ -178,41 +467,344 @@ func (s *Service) GetDocument(id string) (*model.Document, error) {
 	return s.store.GetDocument(id)
 }
 
-// GenerateDocument generates the PDF from the specified source url
-func (s *Service) GenerateDocument(urlStr string, email string, passcode string) (*model.Document, error) {
+// GenerateDocument generates the PDF from the specified source url.  Options
+// left unset take the configured defaults
+func (s *Service) GenerateDocument(urlStr string, email string, passcode string, options model.Options) (*model.Document, error) {
 
-	url, err := url.Parse(urlStr)
+	url, adapter, err := parseSourceURL(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	if scraper.IsSpace(url) {
+		return nil, &InvalidRequestError{Message: "The URL links to a space, capture it with /api/collections"}
+	}
+
+	options, err = s.documentOptions(options)
 	if err != nil {
 		return nil, err
 	}
 
-	if url.Scheme != "https" && url.Host != "docsend.com" {
-		return nil, errors.New("Invalid URL")
+	doc := &model.Document{
+		Owner:     email,
+		SourceURL: url.String(),
+		Adapter:   adapter.Name(),
+		Options:   options,
+	}
+	if err := s.setPasscode(doc, passcode); err != nil {
+		return nil, err
 	}
 
-	doc, err := s.store.InsertDocument(url.String(), email)
+	doc, err = s.store.InsertDocument(doc)
 	if err != nil {
 		return nil, err
 	}
 
-	scrape := task.NewScrapeTask(s.os, doc.ID.Hex(), url, email, passcode)
-	s.dispatcher.Dispatch(scrape)
+	s.dispatch(doc, url, passcode)
 
 	return doc, nil
 }
 
-// DownloadDocument reads the document from the object store and sends it to the
-// user
-func (s *Service) DownloadDocument(id string) (io.Reader, error) {
+// parseSourceURL parses the link a capture is requested for, returning the
+// SiteAdapter that handles it
+func parseSourceURL(urlStr string) (*url.URL, scraper.SiteAdapter, error) {
+
+	url, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	adapter := scraper.MatchAdapter(url)
+	if adapter == nil {
+		return nil, nil, &InvalidRequestError{Message: "Invalid URL, no site adapter handles it"}
+	}
+	return url, adapter, nil
+}
+
+// documentOptions fills in the options left unset with the configured
+// defaults and validates them
+func (s *Service) documentOptions(options model.Options) (model.Options, error) {
+
+	defaults := s.config.Scraper.Options()
+	if options.Format == "" {
+		options.Format = defaults.Format
+	}
+	if options.Layout == "" {
+		options.Layout = defaults.Layout
+	}
+	if options.DPI == 0 {
+		options.DPI = defaults.DPI
+	}
+	if options.Optimize == nil {
+		options.Optimize = defaults.Optimize
+	} else if *options.Optimize == (model.Optimization{}) {
+		// An empty optimization turns off the configured default
+		options.Optimize = nil
+	}
+	if err := options.Validate(); err != nil {
+		return options, &InvalidRequestError{Message: err.Error()}
+	}
+	return options, nil
+}
+
+// CancelDocument cancels the capture of the document, whether it is queued or
+// running.  Captures running on another instance stop when they next renew
+// their lease
+func (s *Service) CancelDocument(id string) (*model.Document, error) {
+
+	s.statusMutex.Lock()
+	doc, err := s.store.GetDocument(id)
+	if err == nil && !doc.Cancellable() {
+		err = ErrNotCancellable
+	}
+	if err == nil {
+		doc, err = s.store.UpdateStatus(id, model.StatusCancelled, "Cancelled by request")
+	}
+	s.statusMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("Cancelling document %s", id)
+
+	s.dispatcher.Cancel(id)
+	s.pushDocument(doc)
+	return doc, nil
+}
+
+// RecaptureDocument captures a new version of the document from its source
+// URL with its original options.  The passcode the document was captured with
+// is used unless a new one is supplied
+func (s *Service) RecaptureDocument(id string, passcode string) (*model.Document, error) {
+
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
 
 	doc, err := s.store.GetDocument(id)
 	if err != nil {
 		return nil, err
 	}
+	if doc.Cancellable() {
+		return nil, ErrCaptureInProgress
+	}
+	return s.startVersion(doc, passcode, false)
+}
+
+// startVersion queues the capture of the next version of the document.  A
+// check only records the version if its pages changed.  Must be called with
+// the statusMutex held
+func (s *Service) startVersion(doc *model.Document, passcode string, check bool) (*model.Document, error) {
+	id := doc.ID.Hex()
+
+	url, err := url.Parse(doc.SourceURL)
+	if err != nil {
+		return nil, err
+	}
+	if passcode == "" {
+		if passcode, err = s.getPasscode(doc); err != nil {
+			return nil, &InvalidRequestError{Message: "The passcode is required to capture the document again"}
+		}
+	}
+
+	// Documents captured before versioning become their own first version
+	if doc.Version == 0 && doc.Capture != nil {
+		legacy := model.Version{
+			Number:  1,
+			Path:    doc.OutputPath(),
+			Created: doc.LastUpdated,
+			Capture: *doc.Capture,
+		}
+		if doc, err = s.store.AddVersion(id, legacy); err != nil {
+			return nil, err
+		}
+	}
+
+	job := &model.Document{}
+	if err := s.setPasscode(job, passcode); err != nil {
+		return nil, err
+	}
+	job.Job.Check = check
+
+	number := doc.NextVersion()
+	message := fmt.Sprintf("Capturing version %d", number)
+	if check {
+		message = fmt.Sprintf("Checking for changes since version %d", doc.Version)
+	}
+	doc, err = s.store.StartVersion(id, job.Job, message)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("%s of document %s", message, id)
 
-	src := path.Join(doc.Owner, path.Base(doc.SourceURL)+".pdf")
+	s.pushDocument(doc)
+	s.dispatch(doc, url, passcode)
+	return doc, nil
+}
+
+// ListVersions lists the versions of the document, oldest first
+func (s *Service) ListVersions(id string) ([]model.Version, error) {
+	doc, err := s.store.GetDocument(id)
+	if err != nil {
+		return nil, err
+	}
+	versions := doc.Versions
+	if versions == nil {
+		versions = []model.Version{}
+	}
+	return versions, nil
+}
+
+// dispatch queues the capture of the next version of the supplied document
+func (s *Service) dispatch(doc *model.Document, url *url.URL, passcode string) {
+	options := s.scraperOptions(doc.Options)
+	options.Adapter = doc.Adapter
+
+	// Documents in a collection keep their pages so they can be merged
+	options.KeepPages = doc.CollectionID != ""
+	if previous, ok := doc.GetVersion(doc.Version); ok && doc.Job.Check {
+		options.Previous = previous.Capture.PageHashes
+	}
+
+	// Lease the document while it's queued so no other instance resumes it
+	// as interrupted.  Running the capture renews the lease
+	if err := s.leaser.Acquire(doc.ID.Hex(), s.config.Queue.LeaseTTL); err != nil {
+		if err == task.ErrLeased {
+			logger.Infof("Document %s is being captured by another instance", doc.ID.Hex())
+			return
+		}
+		logger.Warnf("Failed to lease document %s: %s", doc.ID.Hex(), err.Error())
+	}
+
+	number := doc.NextVersion()
+	var t task.Task
+	t = task.NewScrapeTask(s.os, doc.ID.Hex(), number, url, doc.VersionPath(number), doc.Owner, passcode, options, s.answerer, s.config.Scraper.InputTimeout)
+	t = task.NewRetryTask(t, s.retryPolicy())
+	t = newCachedTask(t, s.store, s.os, s.leaser.owner, task.CachePrefix(doc.ID.Hex(), number))
+	t = task.NewLeasedTask(t, s.leaser, s.config.Queue.LeaseTTL)
+	s.dispatcher.Dispatch(t)
+}
+
+// scraperOptions builds the scraper.Options from the configuration and the
+// document's options
+func (s *Service) scraperOptions(doc model.Options) scraper.Options {
+	options := scraper.DefaultOptions()
+	options.Concurrency = s.config.Scraper.Concurrency
+	options.Format = doc.Format
+	options.Layout = doc.Layout
+	options.DPI = doc.DPI
+	options.Optimize = doc.Optimize
+	options.Cover = doc.Cover
+	return options
+}
+
+// retryPolicy builds the task.RetryPolicy from the configuration
+func (s *Service) retryPolicy() task.RetryPolicy {
+	policy := task.DefaultRetryPolicy()
+	policy.MaxAttempts = s.config.Retry.MaxAttempts
+	policy.InitialBackoff = s.config.Retry.InitialBackoff
+	policy.MaxBackoff = s.config.Retry.MaxBackoff
+	policy.Multiplier = s.config.Retry.Multiplier
+	policy.Jitter = s.config.Retry.Jitter
+	return policy
+}
+
+// recoverTasks re-queues the captures left active by a previous run of this
+// instance, or by an instance whose lease on them has expired.  Those that were
+// waiting on input start again, asking for it afresh.  Captures whose passcode
+// can't be recovered are failed so the user can resubmit them
+func (s *Service) recoverTasks() {
+
+	docs, err := s.store.GetDocumentsByStatus(model.StatusPending, model.StatusCapturing, model.StatusAwaitingInput)
+	if err != nil {
+		logger.Errorf("Failed to find interrupted documents: %s", err.Error())
+		return
+	}
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	for _, doc := range docs {
+
+		// Skip captures queued or running on another instance, which renews
+		// its lease on them
+		if doc.Job.LeaseOwner != s.leaser.owner && doc.Job.LeaseExpires >= now {
+			continue
+		}
+
+		// Claim the capture so instances starting together don't both
+		// resume it
+		if err := s.leaser.Acquire(doc.ID.Hex(), s.config.Queue.LeaseTTL); err != nil {
+			if err != task.ErrLeased {
+				logger.Errorf("Failed to lease interrupted document %s: %s", doc.ID.Hex(), err.Error())
+			}
+			continue
+		}
+
+		url, err := url.Parse(doc.SourceURL)
+		if err != nil {
+			s.handleRecoveryError(doc, "Failed to resume after a restart: invalid source URL")
+			continue
+		}
+		passcode, err := s.getPasscode(doc)
+		if err != nil {
+			s.handleRecoveryError(doc, "Interrupted by a restart, please resubmit with the passcode")
+			continue
+		}
+
+		logger.Infof("Re-queuing interrupted document %s", doc.ID.Hex())
+
+		updated, err := s.store.UpdateStatus(doc.ID.Hex(), model.StatusPending, "Re-queued after a restart")
+		if err != nil {
+			logger.Errorf("Failed to store document status update: %s", err.Error())
+			continue
+		}
+		s.pushDocument(updated)
+		s.dispatch(updated, url, passcode)
+	}
+}
+
+func (s *Service) handleRecoveryError(doc *model.Document, message string) {
+
+	logger.Infof("Document %s can't be resumed: %s", doc.ID.Hex(), message)
+
+	// Nothing will resume from the pages a previous run downloaded
+	clearCache(s.os, task.CachePrefix(doc.ID.Hex(), doc.NextVersion()))
+
+	updated, err := s.store.UpdateStatus(doc.ID.Hex(), model.StatusError, message)
+	if err != nil {
+		logger.Errorf("Failed to store document status update: %s", err.Error())
+		return
+	}
+	s.pushDocument(updated)
+}
+
+// DownloadDocument reads the latest version of the document from the object
+// store, along with the document's metadata
+func (s *Service) DownloadDocument(id string) (*model.Document, io.Reader, error) {
+	return s.DownloadVersion(id, 0)
+}
+
+// DownloadVersion reads the supplied version of the document from the object
+// store, along with the document's metadata.  A version of 0 is the latest
+func (s *Service) DownloadVersion(id string, number int) (*model.Document, io.Reader, error) {
+
+	doc, err := s.store.GetDocument(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	src := doc.OutputPath()
+	if number != 0 {
+		version, ok := doc.GetVersion(number)
+		if !ok {
+			return nil, nil, store.ErrNotFound
+		}
+		src = version.Path
+	}
 
 	logger.Infof("Download document at: %s", src)
 
-	return s.os.Read(src)
+	reader, err := s.os.Read(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, reader, nil
 }
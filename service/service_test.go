@@ -0,0 +1,389 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/config"
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/scraper"
+	"github.com/aldelucca1/docsend_scraper/scraper/fake"
+	"github.com/aldelucca1/docsend_scraper/store"
+	"github.com/aldelucca1/docsend_scraper/store/memory"
+	"github.com/aldelucca1/docsend_scraper/task"
+)
+
+// testInstance is the queue.instance_id of the services under test
+const testInstance = "test"
+
+// testService is a started Service backed by in-memory stores, capturing from
+// a fake DocSend server
+type testService struct {
+	*Service
+	server *fake.Server
+}
+
+func newTestService(t *testing.T) *testService {
+	return newTestServiceWithStore(t, memory.NewStore(), fake.NewServer())
+}
+
+// newTestServiceWithStore starts a Service on the supplied Datastore, which
+// may hold documents left by a previous run, capturing from the server
+func newTestServiceWithStore(t *testing.T, datastore store.Datastore, server *fake.Server) *testService {
+	return startTestService(t, testConfig(), datastore, server)
+}
+
+// testConfig returns the configuration of the services under test
+func testConfig() *config.Config {
+	cfg := config.NewConfig()
+	cfg.Datastore.Type = "memory"
+	cfg.ObjectStore.Type = "memory"
+	cfg.Queue.InstanceID = testInstance
+	cfg.Dispatcher.Workers = 2
+	cfg.Retry.InitialBackoff = 10 * time.Millisecond
+	cfg.Retry.MaxBackoff = 10 * time.Millisecond
+	return cfg
+}
+
+// startTestService starts a Service with the configuration on the supplied
+// Datastore, capturing from the server
+func startTestService(t *testing.T, cfg *config.Config, datastore store.Datastore, server *fake.Server) *testService {
+	scraper.SetBaseClient(server.Client())
+
+	s := NewServiceWithStores(cfg, datastore, memory.NewObjectStore())
+	if err := s.Start(); err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return &testService{Service: s, server: server}
+}
+
+func (s *testService) close() {
+	s.Stop()
+	s.server.Close()
+	scraper.SetBaseClient(nil)
+}
+
+// waitForStatus waits for the document to reach one of the statuses
+func (s *testService) waitForStatus(t *testing.T, id string, statuses ...model.Status) *model.Document {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		doc, err := s.GetDocument(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, status := range statuses {
+			if doc.Status == status {
+				return doc
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("document %s still %s, expected %v: %+v", id, doc.Status, statuses, doc.StatusDetails)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// insertLeased inserts a pending document for the fake document with the
+// slug, leased by the owner until the expiry
+func insertLeased(t *testing.T, datastore store.Datastore, server *fake.Server, slug string, owner string, expires time.Time) string {
+	doc, err := datastore.InsertDocument(&model.Document{
+		Owner:     "a@example.com",
+		SourceURL: server.DocumentURL(slug).String(),
+		Adapter:   scraper.DocSendAdapterName,
+		Options:   model.Options{Format: model.FormatPDF, Layout: model.LayoutNative, DPI: 72},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner != "" {
+		if _, err := datastore.AcquireLease(doc.ID.Hex(), owner, expires.UnixNano()/int64(time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return doc.ID.Hex()
+}
+
+func TestRecoverTasks(t *testing.T) {
+	server := fake.NewServer()
+	datastore := memory.NewStore()
+	for _, slug := range []string{"live", "expired", "own", "unleased"} {
+		server.AddDocument(fake.NewDocument(slug, 2, ""))
+	}
+	server.AddDocument(fake.NewDocument("passcode", 2, "secret"))
+
+	live := insertLeased(t, datastore, server, "live", "other", time.Now().Add(time.Hour))
+	expired := insertLeased(t, datastore, server, "expired", "other", time.Now().Add(-time.Minute))
+	own := insertLeased(t, datastore, server, "own", testInstance, time.Now().Add(time.Hour))
+	unleased := insertLeased(t, datastore, server, "unleased", "", time.Time{})
+	protected, err := datastore.InsertDocument(&model.Document{
+		Owner:     "a@example.com",
+		SourceURL: server.DocumentURL("passcode").String(),
+		Adapter:   scraper.DocSendAdapterName,
+		Job:       model.Job{HasPasscode: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	passcode := protected.ID.Hex()
+
+	s := newTestServiceWithStore(t, datastore, server)
+	defer s.close()
+
+	// A capture leased by another running instance is left to it
+	doc, err := s.GetDocument(live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Status != model.StatusPending || doc.StatusDetails[0].Message != "Request Submitted" {
+		t.Errorf("expected the capture leased elsewhere to be left alone, got %s: %+v", doc.Status, doc.StatusDetails)
+	}
+
+	// Those interrupted are resumed and captured once each
+	for slug, id := range map[string]string{"expired": expired, "own": own, "unleased": unleased} {
+		doc := s.waitForStatus(t, id, model.StatusComplete, model.StatusError)
+		if doc.Status != model.StatusComplete {
+			t.Errorf("%s: expected the interrupted capture to complete, got %s: %+v", slug, doc.Status, doc.StatusDetails)
+		}
+		if n := server.Requests("/view/" + slug + "/page_data/1"); n != 1 {
+			t.Errorf("%s: expected the document to be captured once, fetched page 1 %d times", slug, n)
+		}
+	}
+
+	// Without a secret key the passcode can't be recovered
+	doc = s.waitForStatus(t, passcode, model.StatusError)
+	if doc.StatusDetails[0].Message != "Interrupted by a restart, please resubmit with the passcode" {
+		t.Errorf("expected the passcode protected capture to be failed, got %q", doc.StatusDetails[0].Message)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	datastore := memory.NewStore()
+	server.AddDocument(fake.NewDocument("own", 2, ""))
+	server.AddDocument(fake.NewDocument("unleased", 2, ""))
+	own := insertLeased(t, datastore, server, "own", testInstance, time.Now().Add(time.Hour))
+	unleased := insertLeased(t, datastore, server, "unleased", "", time.Time{})
+
+	// Opening a Service reads the documents without resuming their captures,
+	// even those leased by the same instance id
+	s := NewServiceWithStores(testConfig(), datastore, memory.NewObjectStore())
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	docs, err := s.ListDocuments("a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 documents, got %d", len(docs))
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	for id, owner := range map[string]string{own: testInstance, unleased: ""} {
+		doc, err := datastore.GetDocument(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc.Status != model.StatusPending || doc.Job.LeaseOwner != owner {
+			t.Errorf("expected document %s left pending and leased by %q, got %s leased by %q", id, owner, doc.Status, doc.Job.LeaseOwner)
+		}
+	}
+	if n := server.Requests("/view/"); n != 0 {
+		t.Errorf("expected nothing captured, got %d requests", n)
+	}
+}
+
+func TestDispatchLeasesQueuedDocument(t *testing.T) {
+	s := newTestService(t)
+	defer s.close()
+	s.server.AddDocument(fake.NewDocument("deck", 2, ""))
+
+	// Stall the capture so the document stays leased while it's queued
+	// and running
+	s.server.Fail(fake.Failure{Path: "/view/deck", Times: 1, Delay: 200 * time.Millisecond})
+
+	doc, err := s.GenerateDocument(s.server.DocumentURL("deck").String(), "a@example.com", "", model.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.store.GetDocument(doc.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Job.LeaseOwner != testInstance {
+		t.Errorf("expected the queued document to be leased by %s, got %q", testInstance, stored.Job.LeaseOwner)
+	}
+	s.waitForStatus(t, doc.ID.Hex(), model.StatusComplete)
+}
+
+// testTask is a task.Task standing in for a capture of the document with id
+type testTask struct {
+	id string
+}
+
+func (t testTask) ID() string {
+	return t.id
+}
+
+func (t testTask) Execute(ctx context.Context, status chan<- task.TaskStatus) error {
+	return nil
+}
+
+func TestGenerateDocument(t *testing.T) {
+	s := newTestService(t)
+	defer s.close()
+	s.server.AddDocument(fake.NewDocument("deck", 3, "secret"))
+
+	doc, err := s.GenerateDocument(s.server.DocumentURL("deck").String(), "a@example.com", "secret", model.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Status != model.StatusPending {
+		t.Errorf("expected a pending document, got %s", doc.Status)
+	}
+	if doc.Adapter != scraper.DocSendAdapterName {
+		t.Errorf("expected the %s adapter, got %q", scraper.DocSendAdapterName, doc.Adapter)
+	}
+	if doc.Options.Format != model.FormatPDF || doc.Options.DPI != 72 {
+		t.Errorf("expected the configured default options, got %+v", doc.Options)
+	}
+
+	doc = s.waitForStatus(t, doc.ID.Hex(), model.StatusComplete, model.StatusError)
+	if doc.Status != model.StatusComplete {
+		t.Fatalf("expected the capture to complete, got %s: %+v", doc.Status, doc.StatusDetails)
+	}
+	if doc.Capture == nil || doc.Capture.Pages != 3 {
+		t.Errorf("expected a 3 page capture, got %+v", doc.Capture)
+	}
+	if exists, _ := s.os.Exists(doc.OutputPath()); !exists {
+		t.Errorf("expected the document at %s", doc.OutputPath())
+	}
+
+	docs, err := s.ListDocuments("a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].ID != doc.ID {
+		t.Errorf("expected the document to be listed for its owner, got %d documents", len(docs))
+	}
+}
+
+func TestGenerateDocumentFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		passcode string
+		failure  fake.Failure
+		status   model.Status
+		message  string
+		viewed   int
+	}{
+		{"wrong passcode", "guess", fake.Failure{}, model.StatusError, "Failed with error: Authentication failed", 2},
+		{"server error", "secret", fake.Failure{Path: "/view/deck", Times: 1, Status: http.StatusBadGateway}, model.StatusComplete, "Completed successfully", 3},
+		{"no content type", "secret", fake.Failure{Path: "/images/", NoContentType: true}, model.StatusComplete, "Completed successfully", 2},
+		{"slow images", "secret", fake.Failure{Path: "/images/", Delay: 50 * time.Millisecond}, model.StatusComplete, "Completed successfully", 2},
+	}
+	for _, tt := range tests {
+		s := newTestService(t)
+		s.server.AddDocument(fake.NewDocument("deck", 2, "secret"))
+		if tt.failure.Path != "" {
+			s.server.Fail(tt.failure)
+		}
+
+		doc, err := s.GenerateDocument(s.server.DocumentURL("deck").String(), "a@example.com", tt.passcode, model.Options{})
+		if err != nil {
+			s.close()
+			t.Fatal(err)
+		}
+		doc = s.waitForStatus(t, doc.ID.Hex(), model.StatusComplete, model.StatusError)
+		if doc.Status != tt.status || doc.StatusDetails[0].Message != tt.message {
+			t.Errorf("%s: expected %s with %q, got %s: %+v", tt.name, tt.status, tt.message, doc.Status, doc.StatusDetails)
+		}
+		if tt.status == model.StatusComplete && (doc.Capture == nil || doc.Capture.Pages != 2) {
+			t.Errorf("%s: expected a 2 page capture, got %+v", tt.name, doc.Capture)
+		}
+		if n := s.server.Requests("/view/deck") - s.server.Requests("/view/deck/"); n != tt.viewed {
+			t.Errorf("%s: expected the viewer requested %d times, got %d", tt.name, tt.viewed, n)
+		}
+		s.close()
+	}
+}
+
+func TestGenerateDocumentInvalid(t *testing.T) {
+	s := newTestService(t)
+	defer s.close()
+
+	tests := []struct {
+		name    string
+		url     string
+		options model.Options
+	}{
+		{"unsupported site", "https://example.com/view/deck", model.Options{}},
+		{"space", "https://docsend.com/view/s/space", model.Options{}},
+		{"format", "https://docsend.com/view/deck", model.Options{Format: "doc"}},
+	}
+	for _, tt := range tests {
+		_, err := s.GenerateDocument(tt.url, "a@example.com", "", tt.options)
+		if _, ok := err.(*InvalidRequestError); !ok {
+			t.Errorf("%s: expected an InvalidRequestError, got %v", tt.name, err)
+		}
+	}
+
+	docs, _ := s.ListDocuments("a@example.com")
+	if len(docs) != 0 {
+		t.Errorf("expected no documents stored for invalid requests, got %d", len(docs))
+	}
+}
+
+func TestHandleTaskComplete(t *testing.T) {
+	s := newTestService(t)
+	defer s.close()
+
+	doc, err := s.store.InsertDocument(&model.Document{Owner: "a@example.com", SourceURL: "https://docsend.com/view/deck"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := doc.ID.Hex()
+
+	s.handleTaskComplete(testTask{id: id})
+
+	doc, err = s.GetDocument(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Status != model.StatusComplete {
+		t.Errorf("expected a complete document, got %s", doc.Status)
+	}
+	if doc.StatusDetails[0].Message != "Completed successfully" {
+		t.Errorf("expected the completion to be the newest status detail, got %q", doc.StatusDetails[0].Message)
+	}
+}
+
+func TestHandleTaskCompleteCancelled(t *testing.T) {
+	s := newTestService(t)
+	defer s.close()
+
+	doc, err := s.store.InsertDocument(&model.Document{Owner: "a@example.com", SourceURL: "https://docsend.com/view/deck"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := doc.ID.Hex()
+	if _, err := s.store.UpdateStatus(id, model.StatusCancelled, "Cancelled by request"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.handleTaskComplete(testTask{id: id})
+
+	doc, err = s.GetDocument(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Status != model.StatusCancelled {
+		t.Errorf("expected a cancelled document to stay cancelled, got %s", doc.Status)
+	}
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/store"
+	logger "github.com/sirupsen/logrus"
+)
+
+// WatchDocument checks the document for changes at the supplied interval,
+// hourly, daily, weekly or a duration such as 12h.  The first check is one
+// interval from now
+func (s *Service) WatchDocument(id string, interval string) (*model.Document, error) {
+
+	d, err := model.ParseInterval(interval)
+	if err != nil {
+		return nil, &InvalidRequestError{Message: err.Error()}
+	}
+	if d < s.config.Watch.MinInterval {
+		return nil, &InvalidRequestError{Message: fmt.Sprintf("The watch interval must be at least %s", s.config.Watch.MinInterval)}
+	}
+
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	doc, err := s.store.GetDocument(id)
+	if err != nil {
+		return nil, err
+	}
+
+	watch := &model.Watch{
+		Interval:  interval,
+		NextCheck: time.Now().Add(d).UnixNano() / int64(time.Millisecond),
+	}
+	if doc.Watch != nil {
+		watch.LastChecked = doc.Watch.LastChecked
+	}
+	doc, err = s.store.UpdateWatch(id, watch)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("Watching document %s %s", id, interval)
+
+	s.pushDocument(doc)
+	return doc, nil
+}
+
+// UnwatchDocument stops checking the document for changes
+func (s *Service) UnwatchDocument(id string) (*model.Document, error) {
+
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	doc, err := s.store.UpdateWatch(id, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("Stopped watching document %s", id)
+
+	s.pushDocument(doc)
+	return doc, nil
+}
+
+// watchScheduler - A go routine responsible for starting the checks of watched
+// documents as they fall due
+//
+// To stop this go routine, pass a stopped chan to the stopWatchChannel. When
+// this routine completes it will notify the passed stopped channel
+func (s *Service) watchScheduler() {
+
+	ticker := time.NewTicker(s.config.Watch.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.checkWatched()
+
+		case stoppedChan := <-s.stopWatchChannel:
+			stoppedChan <- true
+			return
+		}
+	}
+}
+
+// checkWatched starts a check of each watched document that is due.  Each
+// check is claimed in the datastore first so instances sharing it don't check
+// a document twice
+func (s *Service) checkWatched() {
+
+	now := time.Now()
+	docs, err := s.store.GetWatchedDocuments(now.UnixNano() / int64(time.Millisecond))
+	if err != nil {
+		logger.Errorf("Failed to find watched documents: %s", err.Error())
+		return
+	}
+
+	for _, doc := range docs {
+
+		// Documents being captured are checked once the capture finishes
+		if doc.Cancellable() {
+			continue
+		}
+		interval, err := model.ParseInterval(doc.Watch.Interval)
+		if err != nil {
+			logger.Errorf("Document %s has an invalid watch interval: %s", doc.ID.Hex(), err.Error())
+			continue
+		}
+		s.checkDocument(doc, now.Add(interval).UnixNano()/int64(time.Millisecond))
+	}
+}
+
+// checkDocument claims the due check of a watched document and starts it,
+// scheduling the next check for the supplied time
+func (s *Service) checkDocument(doc *model.Document, next int64) {
+	id := doc.ID.Hex()
+
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	doc, err := s.store.ClaimWatch(id, doc.Watch.NextCheck, next)
+	if err == store.ErrLeaseHeld {
+		return
+	}
+	if err != nil {
+		logger.Errorf("Failed to claim the watch check of document %s: %s", id, err.Error())
+		return
+	}
+	if doc.Cancellable() {
+		return
+	}
+
+	if _, err := s.startVersion(doc, "", true); err != nil {
+		logger.Errorf("Failed to check document %s for changes: %s", id, err.Error())
+
+		updated, err := s.store.UpdateStatus(id, doc.Status, fmt.Sprintf("Failed to check for changes: %s", err.Error()))
+		if err != nil {
+			logger.Errorf("Failed to store document status update: %s", err.Error())
+			return
+		}
+		s.pushDocument(updated)
+	}
+}
+
+func (s *Service) handleTaskUnchanged(id string) {
+
+	logger.Infof("Task %s found no changes", id)
+
+	doc, err := s.updateTask(id, func() (*model.Document, error) {
+		doc, err := s.store.GetDocument(id)
+		if err != nil {
+			return nil, err
+		}
+		return s.store.UpdateStatus(id, model.StatusCapturing, fmt.Sprintf("No changes since version %d", doc.Version))
+	})
+	if err != nil {
+		logger.Errorf("Failed to store document status update: %s", err.Error())
+		return
+	}
+	if doc != nil {
+		s.pushDocument(doc)
+	}
+}
+
+// handleRevoked records that the link of a document captured before no longer
+// grants access, which stops it being watched.  Returns false if the document
+// has never been captured, when the failure is reported as usual
+func (s *Service) handleRevoked(id string, cause error) bool {
+
+	revoked := false
+	doc, err := s.updateTask(id, func() (*model.Document, error) {
+		doc, err := s.store.GetDocument(id)
+		if err != nil || doc.Version == 0 {
+			return nil, err
+		}
+		revoked = true
+
+		logger.Infof("The link of document %s has been revoked: %s", id, cause.Error())
+
+		if doc.Watch != nil {
+			watch := *doc.Watch
+			watch.Revoked = true
+			if _, err := s.store.UpdateWatch(id, &watch); err != nil {
+				return nil, err
+			}
+		}
+		return s.store.UpdateStatus(id, model.StatusError, fmt.Sprintf("The link no longer grants access: %s", cause.Error()))
+	})
+	if err != nil {
+		logger.Errorf("Failed to store document revocation: %s", err.Error())
+		return revoked
+	}
+	if doc != nil {
+		s.pushDocument(doc)
+		s.pushRevoked(doc.Owner, model.ChangeEvent{ID: id, Message: doc.StatusDetails[0].Message})
+	}
+	return revoked
+}
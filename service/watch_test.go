@@ -0,0 +1,91 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/scraper/fake"
+	"github.com/aldelucca1/docsend_scraper/store"
+	"github.com/aldelucca1/docsend_scraper/store/memory"
+)
+
+// viewerRequests counts the requests for the viewer of the fake document,
+// one per capture
+func viewerRequests(server *fake.Server, slug string) int {
+	return server.Requests("/view/"+slug) - server.Requests("/view/"+slug+"/")
+}
+
+func TestWatchScheduler(t *testing.T) {
+	cfg := testConfig()
+	cfg.Watch.PollInterval = 20 * time.Millisecond
+	s := startTestService(t, cfg, memory.NewStore(), fake.NewServer())
+	defer s.close()
+
+	// Capture a document for each watch
+	slugs := []string{"due", "revoked", "later"}
+	ids := make(map[string]string)
+	for _, slug := range slugs {
+		s.server.AddDocument(fake.NewDocument(slug, 2, ""))
+		doc, err := s.GenerateDocument(s.server.DocumentURL(slug).String(), "a@example.com", "", model.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[slug] = doc.ID.Hex()
+		s.waitForStatus(t, ids[slug], model.StatusComplete)
+	}
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	due := now - 1000
+	watches := map[string]*model.Watch{
+		"due":     {Interval: "daily", NextCheck: due},
+		"revoked": {Interval: "daily", NextCheck: due, Revoked: true},
+		"later":   {Interval: "daily", NextCheck: now + int64(time.Hour/time.Millisecond)},
+	}
+	for slug, watch := range watches {
+		if _, err := s.store.UpdateWatch(ids[slug], watch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The due document is checked, finding no changes
+	var doc *model.Document
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var err error
+		if doc, err = s.GetDocument(ids["due"]); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Status == model.StatusComplete && doc.Watch.LastChecked != 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the due document to be checked, got %s: %+v", doc.Status, doc.StatusDetails)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if doc.Version != 1 || doc.Watch.NextCheck <= now {
+		t.Errorf("expected version 1 kept and the next check scheduled, got version %d next checked at %d", doc.Version, doc.Watch.NextCheck)
+	}
+
+	// Another instance finding the same check due can't claim it
+	if _, err := s.store.ClaimWatch(ids["due"], due, now); err != store.ErrLeaseHeld {
+		t.Errorf("expected ErrLeaseHeld claiming the check again, got %v", err)
+	}
+	stale := *doc
+	stale.Watch = &model.Watch{Interval: "daily", NextCheck: due}
+	s.checkDocument(&stale, now)
+
+	// Let the scheduler poll a few more times
+	time.Sleep(10 * cfg.Watch.PollInterval)
+
+	expected := map[string]int{"due": 2, "revoked": 1, "later": 1}
+	for _, slug := range slugs {
+		if n := viewerRequests(s.server, slug); n != expected[slug] {
+			t.Errorf("%s: expected %d captures, got %d", slug, expected[slug], n)
+		}
+	}
+	if doc, _ := s.GetDocument(ids["revoked"]); doc.Watch.LastChecked != 0 {
+		t.Errorf("expected the revoked watch never to be checked, last checked at %d", doc.Watch.LastChecked)
+	}
+}
@@ -0,0 +1,167 @@
+package boltdb
+
+import (
+	"sort"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/store"
+	"github.com/boltdb/bolt"
+	"github.com/globalsign/mgo/bson"
+)
+
+var (
+	// CollectionBucket is the bucket that holds the collection objects, keyed
+	// by id
+	CollectionBucket = []byte("collection")
+	// CollectionOwnerIndex is the bucket that holds a nested bucket of
+	// collection ids for each owner
+	CollectionOwnerIndex = []byte("idx_collection_owner")
+	// DocumentCollectionIndex is the bucket that holds a nested bucket of
+	// document ids for each collection
+	DocumentCollectionIndex = []byte("idx_document_collection")
+)
+
+func init() {
+	buckets = append(buckets, CollectionBucket, CollectionOwnerIndex, DocumentCollectionIndex)
+}
+
+// GetCollections gets the set of collections for a supplied owner
+func (b *Store) GetCollections(owner string) ([]*model.Collection, error) {
+
+	collections := make([]*model.Collection, 0)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		index := tx.Bucket(CollectionOwnerIndex).Bucket([]byte(owner))
+		if index == nil {
+			return nil
+		}
+		return index.ForEach(func(id, _ []byte) error {
+			collection, err := getCollection(tx, id)
+			if err != nil {
+				return err
+			}
+			collections = append(collections, collection)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	// Order newest first, matching the documents
+	sort.SliceStable(collections, func(i, j int) bool {
+		if collections[i].Created == collections[j].Created {
+			return collections[i].ID > collections[j].ID
+		}
+		return collections[i].Created > collections[j].Created
+	})
+
+	return collections, nil
+}
+
+// GetCollection gets the collection with the supplied id
+func (b *Store) GetCollection(id string) (*model.Collection, error) {
+
+	// Validate the supplied ID is in fact an ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	var collection *model.Collection
+	err := b.db.View(func(tx *bolt.Tx) (err error) {
+		collection, err = getCollection(tx, []byte(id))
+		return
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	return collection, nil
+}
+
+// GetCollectionDocuments gets the documents of the collection with the
+// supplied id, in the order they were inserted
+func (b *Store) GetCollectionDocuments(id string) ([]*model.Document, error) {
+
+	docs := make([]*model.Document, 0)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		index := tx.Bucket(DocumentCollectionIndex).Bucket([]byte(id))
+		if index == nil {
+			return nil
+		}
+		return index.ForEach(func(id, _ []byte) error {
+			doc, err := getDocument(tx, id)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, doc)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	// Object ids increase, so they break ties between documents inserted in
+	// the same millisecond
+	sort.SliceStable(docs, func(i, j int) bool {
+		if docs[i].Created == docs[j].Created {
+			return docs[i].ID < docs[j].ID
+		}
+		return docs[i].Created < docs[j].Created
+	})
+
+	return docs, nil
+}
+
+// InsertCollection inserts the supplied collection
+func (b *Store) InsertCollection(collection *model.Collection) (*model.Collection, error) {
+
+	collection.ID = bson.NewObjectId()
+	collection.Created = makeTimestamp()
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		id := []byte(collection.ID.Hex())
+		if tx.Bucket(CollectionBucket).Get(id) != nil {
+			return store.ErrDuplicateKey
+		}
+		if err := putCollection(tx, collection); err != nil {
+			return err
+		}
+
+		// Add the collection to the owner index
+		index, err := tx.Bucket(CollectionOwnerIndex).CreateBucketIfNotExists([]byte(collection.Owner))
+		if err != nil {
+			return err
+		}
+		return index.Put(id, []byte{})
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	return collection, nil
+}
+
+// getCollection - Read and decode the collection with the given id
+func getCollection(tx *bolt.Tx, id []byte) (*model.Collection, error) {
+	data := tx.Bucket(CollectionBucket).Get(id)
+	if data == nil {
+		return nil, store.ErrNotFound
+	}
+	collection := new(model.Collection)
+	if err := bson.Unmarshal(data, collection); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+// putCollection - Encode and write the supplied collection
+func putCollection(tx *bolt.Tx, collection *model.Collection) error {
+	data, err := bson.Marshal(collection)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(CollectionBucket).Put([]byte(collection.ID.Hex()), data)
+}
@@ -0,0 +1,31 @@
+package boltdb
+
+import (
+	"time"
+)
+
+// Config - The configuration information for opening a BoltDB database file
+type Config struct {
+	path    string
+	timeout time.Duration
+}
+
+// NewConfig - Creates a new Config with the default values
+func NewConfig() *Config {
+	return &Config{
+		path:    "docsend.db",
+		timeout: time.Second,
+	}
+}
+
+// WithPath - Set the path of the database file
+func (c *Config) WithPath(path string) *Config {
+	c.path = path
+	return c
+}
+
+// WithTimeout - Set how long to wait for the file lock when opening
+func (c *Config) WithTimeout(timeout time.Duration) *Config {
+	c.timeout = timeout
+	return c
+}
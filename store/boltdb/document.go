@@ -0,0 +1,533 @@
+package boltdb
+
+import (
+	"sort"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/store"
+	"github.com/boltdb/bolt"
+	"github.com/globalsign/mgo/bson"
+)
+
+var (
+	// DocumentBucket is the bucket that holds the document objects, keyed by id
+	DocumentBucket = []byte("document")
+	// DocumentOwnerIndex is the bucket that holds a nested bucket of document
+	// ids for each owner
+	DocumentOwnerIndex = []byte("idx_document_owner")
+)
+
+func init() {
+	buckets = append(buckets, DocumentBucket, DocumentOwnerIndex)
+}
+
+// GetDocuments gets the set of documents for a supplied owner
+func (b *Store) GetDocuments(owner string) ([]*model.Document, error) {
+
+	docs := make([]*model.Document, 0)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		index := tx.Bucket(DocumentOwnerIndex).Bucket([]byte(owner))
+		if index == nil {
+			return nil
+		}
+		return index.ForEach(func(id, _ []byte) error {
+			doc, err := getDocument(tx, id)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, doc)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	// Order newest first, matching the mongodb store
+	sort.SliceStable(docs, func(i, j int) bool {
+		if docs[i].Created == docs[j].Created {
+			return docs[i].ID > docs[j].ID
+		}
+		return docs[i].Created > docs[j].Created
+	})
+
+	return docs, nil
+}
+
+// GetDocument gets the document with the supplied id
+func (b *Store) GetDocument(id string) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact an ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	var doc *model.Document
+	err := b.db.View(func(tx *bolt.Tx) (err error) {
+		doc, err = getDocument(tx, []byte(id))
+		return
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// GetDocumentsByStatus gets the documents in any of the supplied statuses,
+// oldest first
+func (b *Store) GetDocumentsByStatus(statuses ...model.Status) ([]*model.Document, error) {
+
+	docs := make([]*model.Document, 0)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(DocumentBucket).ForEach(func(id, _ []byte) error {
+			doc, err := getDocument(tx, id)
+			if err != nil {
+				return err
+			}
+			for _, status := range statuses {
+				if doc.Status == status {
+					docs = append(docs, doc)
+					break
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		if docs[i].Created == docs[j].Created {
+			return docs[i].ID < docs[j].ID
+		}
+		return docs[i].Created < docs[j].Created
+	})
+
+	return docs, nil
+}
+
+// InsertDocument inserts the supplied document
+func (b *Store) InsertDocument(doc *model.Document) (*model.Document, error) {
+
+	now := makeTimestamp()
+
+	doc.ID = bson.NewObjectId()
+	doc.Status = model.StatusPending
+	doc.StatusDetails = []model.StatusDetail{
+		model.StatusDetail{
+			Message: "Request Submitted",
+			Created: now,
+		},
+	}
+	doc.Created = now
+	doc.LastUpdated = now
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		id := []byte(doc.ID.Hex())
+		if tx.Bucket(DocumentBucket).Get(id) != nil {
+			return store.ErrDuplicateKey
+		}
+		if err := putDocument(tx, doc); err != nil {
+			return err
+		}
+
+		// Add the document to the owner index
+		index, err := tx.Bucket(DocumentOwnerIndex).CreateBucketIfNotExists([]byte(doc.Owner))
+		if err != nil {
+			return err
+		}
+		if err := index.Put(id, []byte{}); err != nil {
+			return err
+		}
+
+		// Add the document to its collection's index
+		if doc.CollectionID == "" {
+			return nil
+		}
+		index, err = tx.Bucket(DocumentCollectionIndex).CreateBucketIfNotExists([]byte(doc.CollectionID))
+		if err != nil {
+			return err
+		}
+		return index.Put(id, []byte{})
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// UpdateStatus updates the document's status
+func (b *Store) UpdateStatus(id string, status model.Status, message string) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact an ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	now := makeTimestamp()
+
+	var doc *model.Document
+	err := b.db.Update(func(tx *bolt.Tx) (err error) {
+		doc, err = getDocument(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+
+		doc.Status = status
+		doc.LastUpdated = now
+		if status != model.StatusAwaitingInput {
+			doc.Challenge = nil
+			doc.Job.Answer = ""
+		}
+
+		// Prepend the message so the newest detail is first
+		if message != "" {
+			doc.StatusDetails = append([]model.StatusDetail{
+				model.StatusDetail{
+					Message: message,
+					Created: now,
+				},
+			}, doc.StatusDetails...)
+		}
+
+		return putDocument(tx, doc)
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// AwaitInput sets the challenge a capture is waiting on the user to answer
+func (b *Store) AwaitInput(id string, challenge model.Challenge, message string) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact an ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	now := makeTimestamp()
+
+	var doc *model.Document
+	err := b.db.Update(func(tx *bolt.Tx) (err error) {
+		doc, err = getDocument(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+
+		doc.Status = model.StatusAwaitingInput
+		doc.Challenge = &challenge
+		doc.Job.Answer = ""
+		doc.LastUpdated = now
+		if message != "" {
+			doc.StatusDetails = append([]model.StatusDetail{
+				model.StatusDetail{
+					Message: message,
+					Created: now,
+				},
+			}, doc.StatusDetails...)
+		}
+
+		return putDocument(tx, doc)
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// AnswerChallenge records the user's answer to the challenge of a document
+// awaiting input
+func (b *Store) AnswerChallenge(id string, answer string) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact an ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	var doc *model.Document
+	err := b.db.Update(func(tx *bolt.Tx) (err error) {
+		doc, err = getDocument(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		if doc.Status != model.StatusAwaitingInput {
+			return store.ErrNotFound
+		}
+
+		doc.Job.Answer = answer
+
+		return putDocument(tx, doc)
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// AcquireLease acquires or renews the lease on an active document
+func (b *Store) AcquireLease(id string, owner string, expires int64) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact an ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	now := makeTimestamp()
+
+	var doc *model.Document
+	err := b.db.Update(func(tx *bolt.Tx) (err error) {
+		doc, err = getDocument(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		if !doc.Leasable(owner, now) {
+			return store.ErrLeaseHeld
+		}
+
+		doc.Job.LeaseOwner = owner
+		doc.Job.LeaseExpires = expires
+
+		return putDocument(tx, doc)
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// StartVersion queues the capture of a new version of the document
+func (b *Store) StartVersion(id string, job model.Job, message string) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact an ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	now := makeTimestamp()
+
+	var doc *model.Document
+	err := b.db.Update(func(tx *bolt.Tx) (err error) {
+		doc, err = getDocument(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+
+		doc.Job = job
+		doc.Status = model.StatusPending
+		doc.Progress = nil
+		doc.Challenge = nil
+		doc.LastUpdated = now
+		doc.StatusDetails = append([]model.StatusDetail{
+			model.StatusDetail{
+				Message: message,
+				Created: now,
+			},
+		}, doc.StatusDetails...)
+
+		return putDocument(tx, doc)
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// AddVersion records a version produced by a capture
+func (b *Store) AddVersion(id string, version model.Version) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact an ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	var doc *model.Document
+	err := b.db.Update(func(tx *bolt.Tx) (err error) {
+		doc, err = getDocument(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+
+		capture := version.Capture
+		doc.Versions = append(doc.Versions, version)
+		doc.Version = version.Number
+		doc.Capture = &capture
+
+		return putDocument(tx, doc)
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// UpdateWatch sets the document's watch schedule, or stops watching it if nil
+func (b *Store) UpdateWatch(id string, watch *model.Watch) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact an ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	var doc *model.Document
+	err := b.db.Update(func(tx *bolt.Tx) (err error) {
+		doc, err = getDocument(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+
+		doc.Watch = watch
+
+		return putDocument(tx, doc)
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// GetWatchedDocuments gets the watched documents due to be checked by the
+// supplied time
+func (b *Store) GetWatchedDocuments(due int64) ([]*model.Document, error) {
+
+	docs := make([]*model.Document, 0)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(DocumentBucket).ForEach(func(id, _ []byte) error {
+			doc, err := getDocument(tx, id)
+			if err != nil {
+				return err
+			}
+			if doc.Watch != nil && !doc.Watch.Revoked && doc.Watch.NextCheck <= due {
+				docs = append(docs, doc)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	return docs, nil
+}
+
+// ClaimWatch claims the watch check of a document due at the supplied time
+func (b *Store) ClaimWatch(id string, due int64, next int64) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact an ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	now := makeTimestamp()
+
+	var doc *model.Document
+	err := b.db.Update(func(tx *bolt.Tx) (err error) {
+		doc, err = getDocument(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		if doc.Watch == nil || doc.Watch.Revoked || doc.Watch.NextCheck != due {
+			return store.ErrLeaseHeld
+		}
+
+		doc.Watch.NextCheck = next
+		doc.Watch.LastChecked = now
+
+		return putDocument(tx, doc)
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// UpdateProgress updates the document's current capture progress
+func (b *Store) UpdateProgress(id string, progress model.Progress) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact an ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	var doc *model.Document
+	err := b.db.Update(func(tx *bolt.Tx) (err error) {
+		doc, err = getDocument(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+
+		doc.Progress = &progress
+
+		return putDocument(tx, doc)
+	})
+	if err != nil {
+		return nil, b.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// ReleaseLease releases the lease on a document if held by the supplied owner
+func (b *Store) ReleaseLease(id string, owner string) error {
+
+	// Validate the supplied ID is in fact an ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return store.ErrNotFound
+	}
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		doc, err := getDocument(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		if doc.Job.LeaseOwner != owner {
+			return nil
+		}
+
+		doc.Job.LeaseOwner = ""
+		doc.Job.LeaseExpires = 0
+
+		return putDocument(tx, doc)
+	})
+	if err != nil {
+		return b.handleError(err)
+	}
+
+	return nil
+}
+
+// getDocument - Read and decode the document with the given id
+func getDocument(tx *bolt.Tx, id []byte) (*model.Document, error) {
+	data := tx.Bucket(DocumentBucket).Get(id)
+	if data == nil {
+		return nil, store.ErrNotFound
+	}
+	doc := new(model.Document)
+	if err := bson.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// putDocument - Encode and write the supplied document
+func putDocument(tx *bolt.Tx, doc *model.Document) error {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(DocumentBucket).Put([]byte(doc.ID.Hex()), data)
+}
@@ -0,0 +1,75 @@
+package boltdb
+
+import (
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/store"
+	"github.com/boltdb/bolt"
+	logger "github.com/sirupsen/logrus"
+)
+
+var buckets = make([][]byte, 0)
+
+// NewStore - Create a new BoltDB datastore
+func NewStore(config *Config) *Store {
+	b := new(Store)
+	b.config = config
+	return b
+}
+
+// Store is a Datastore backed by an embedded BoltDB database file
+type Store struct {
+	config *Config
+	db     *bolt.DB
+}
+
+// Connect - Open the BoltDB database file
+func (b *Store) Connect() error {
+
+	logger.Infof("Opening boltdb database at: %s", b.config.path)
+
+	db, err := bolt.Open(b.config.path, 0600, &bolt.Options{Timeout: b.config.timeout})
+	if err != nil {
+		logger.Errorf("Failed to open boltdb database: %s", err.Error())
+		return err
+	}
+
+	// Ensure each of our top level buckets exist
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Errorf("Failed to create boltdb buckets: %s", err.Error())
+		db.Close()
+		return err
+	}
+
+	b.db = db
+	return nil
+}
+
+// Close - Close the BoltDB database file
+func (b *Store) Close() {
+	if b.db != nil {
+		b.db.Close()
+	}
+}
+
+// makeTimestamp - Generates a timestamp from the current time
+func makeTimestamp() int64 {
+	return time.Now().UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
+}
+
+// handleError - Convert the native BoltDB error to a datastore error
+func (b *Store) handleError(err error) error {
+	if err == store.ErrNotFound || err == store.ErrDuplicateKey || err == store.ErrLeaseHeld {
+		return err
+	}
+	logger.Errorf("BoltDB operation failed: %s", err.Error())
+	return store.ErrInternal
+}
@@ -0,0 +1,52 @@
+package boltdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/store/storetest"
+	"github.com/boltdb/bolt"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) *storetest.Fixture {
+		dir, err := ioutil.TempDir("", "boltdb")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b := NewStore(NewConfig().WithPath(filepath.Join(dir, "test.db")).WithTimeout(time.Second))
+		if err := b.Connect(); err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+
+		return &storetest.Fixture{
+			Datastore: b,
+			SetCreated: func(id string, created int64) {
+				err := b.db.Update(func(tx *bolt.Tx) error {
+					if doc, err := getDocument(tx, []byte(id)); err == nil {
+						doc.Created = created
+						return putDocument(tx, doc)
+					}
+					collection, err := getCollection(tx, []byte(id))
+					if err != nil {
+						return err
+					}
+					collection.Created = created
+					return putCollection(tx, collection)
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			Close: func() {
+				b.Close()
+				os.RemoveAll(dir)
+			},
+		}
+	})
+}
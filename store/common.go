@@ -6,4 +6,6 @@ var (
 	ErrDuplicateKey = errors.New("duplicate key")
 	ErrNotFound     = errors.New("not found")
 	ErrInternal     = errors.New("internal error")
+	ErrLeaseHeld    = errors.New("lease held")
+	ErrEmptyPrefix  = errors.New("empty prefix")
 )
@@ -19,9 +19,64 @@ type Datastore interface {
 	// Gets the document with the supplied id
 	GetDocument(id string) (*model.Document, error)
 
-	// Inserts the supplied document
-	InsertDocument(sourceURL string, owner string) (*model.Document, error)
+	// Gets the documents in any of the supplied statuses, oldest first
+	GetDocumentsByStatus(statuses ...model.Status) ([]*model.Document, error)
 
-	// Updates the document's status
+	// Inserts the supplied document, assigning its id, status and timestamps
+	InsertDocument(doc *model.Document) (*model.Document, error)
+
+	// Updates the document's status.  The challenge of a document awaiting
+	// input and its answer are cleared by any other status
 	UpdateStatus(id string, status model.Status, message string) (*model.Document, error)
+
+	// Sets the challenge a capture is waiting on the user to answer, moving
+	// the document to StatusAwaitingInput
+	AwaitInput(id string, challenge model.Challenge, message string) (*model.Document, error)
+
+	// Records the user's answer to the challenge of a document awaiting
+	// input.  Returns ErrNotFound if the document isn't awaiting input
+	AnswerChallenge(id string, answer string) (*model.Document, error)
+
+	// Updates the document's current capture progress
+	UpdateProgress(id string, progress model.Progress) (*model.Document, error)
+
+	// Queues the capture of a new version of the document, setting its job
+	// and resetting its status to pending
+	StartVersion(id string, job model.Job, message string) (*model.Document, error)
+
+	// Records a version produced by a capture, making it the document's
+	// latest version
+	AddVersion(id string, version model.Version) (*model.Document, error)
+
+	// Sets the document's watch schedule, or stops watching it if nil
+	UpdateWatch(id string, watch *model.Watch) (*model.Document, error)
+
+	// Gets the watched documents due to be checked by the supplied time
+	GetWatchedDocuments(due int64) ([]*model.Document, error)
+
+	// Claims the watch check of a document due at the supplied time,
+	// scheduling the next check.  Returns ErrLeaseHeld if the check was
+	// already claimed or the document is no longer watched
+	ClaimWatch(id string, due int64, next int64) (*model.Document, error)
+
+	// Gets the set of collections for a supplied owner
+	GetCollections(owner string) ([]*model.Collection, error)
+
+	// Gets the collection with the supplied id
+	GetCollection(id string) (*model.Collection, error)
+
+	// Gets the documents of the collection with the supplied id, in the
+	// order they were inserted
+	GetCollectionDocuments(id string) ([]*model.Document, error)
+
+	// Inserts the supplied collection, assigning its id and timestamp
+	InsertCollection(collection *model.Collection) (*model.Collection, error)
+
+	// Acquires or renews the lease on an active document until the supplied
+	// expiry.  Returns ErrLeaseHeld if another owner holds an unexpired lease
+	// or the document is no longer active
+	AcquireLease(id string, owner string, expires int64) (*model.Document, error)
+
+	// Releases the lease on a document if held by the supplied owner
+	ReleaseLease(id string, owner string) error
 }
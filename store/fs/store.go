@@ -4,6 +4,8 @@ import (
 	"io"
 	"os"
 	"path"
+
+	"github.com/aldelucca1/docsend_scraper/store"
 )
 
 // NewStore - Create a new filesystem backed object store
@@ -22,6 +24,8 @@ type Store struct {
 func (fs *Store) Exists(pathStr string) (bool, error) {
 	out := path.Join(fs.config.outputPath, pathStr)
 	if _, err := os.Stat(out); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
 		return false, err
 	}
 	return true, nil
@@ -40,7 +44,7 @@ func (fs *Store) Write(pathStr string, reader io.Reader) error {
 		return err
 	}
 
-	to, err := os.OpenFile(out, os.O_RDWR|os.O_CREATE, os.ModePerm)
+	to, err := os.OpenFile(out, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.ModePerm)
 	if err != nil {
 		return err
 	}
@@ -55,3 +59,21 @@ func (fs *Store) Read(pathStr string) (io.Reader, error) {
 	out := path.Join(fs.config.outputPath, pathStr)
 	return os.Open(out)
 }
+
+// Delete the object at the given path
+func (fs *Store) Delete(pathStr string) error {
+	out := path.Join(fs.config.outputPath, pathStr)
+	if err := os.Remove(out); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// DeletePrefix deletes every object beneath the given path
+func (fs *Store) DeletePrefix(pathStr string) error {
+	prefix, err := store.DirPrefix(pathStr)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(path.Join(fs.config.outputPath, prefix))
+}
@@ -0,0 +1,43 @@
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aldelucca1/docsend_scraper/store"
+)
+
+func TestDeletePrefix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fs := NewStore(NewConfig().WithOutputPath(dir))
+	keys := []string{"cache/a/v1/page-0001.json", "cache/a/v1/page-0001.img", "cache/a/v10/page-0001.json", "cache/a/v1.pdf"}
+	for _, key := range keys {
+		if err := fs.Write(key, strings.NewReader("data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := fs.DeletePrefix("/"); err != store.ErrEmptyPrefix {
+		t.Errorf("expected ErrEmptyPrefix deleting everything, got %v", err)
+	}
+	if err := fs.DeletePrefix("cache/a/v1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.DeletePrefix("cache/a/v1"); err != nil {
+		t.Errorf("expected deleting a missing prefix to succeed, got %v", err)
+	}
+
+	for i, key := range keys {
+		exists, _ := fs.Exists(key)
+		if deleted := i < 2; exists == deleted {
+			t.Errorf("%s: expected deleted %v, exists %v", key, deleted, exists)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/aldelucca1/docsend_scraper/store"
+)
+
+// NewObjectStore - Create a new in-memory object store
+func NewObjectStore() *ObjectStore {
+	o := new(ObjectStore)
+	o.objects = make(map[string][]byte)
+	return o
+}
+
+// ObjectStore is an ObjectStore held entirely in memory
+type ObjectStore struct {
+	mutex   sync.RWMutex
+	objects map[string][]byte
+}
+
+// Exists checks if an object exists at the given path
+func (o *ObjectStore) Exists(path string) (bool, error) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	_, ok := o.objects[path]
+	return ok, nil
+}
+
+// Write an object to the given path
+func (o *ObjectStore) Write(path string, reader io.Reader) error {
+
+	// Read the object fully before taking the lock, the reader may be slow
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.objects[path] = data
+	return nil
+}
+
+// Read an object from the given path
+func (o *ObjectStore) Read(path string) (io.Reader, error) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	data, ok := o.objects[path]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return bytes.NewReader(data), nil
+}
+
+// Delete the object at the given path
+func (o *ObjectStore) Delete(path string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	delete(o.objects, path)
+	return nil
+}
+
+// DeletePrefix deletes every object beneath the given path
+func (o *ObjectStore) DeletePrefix(path string) error {
+	prefix, err := store.DirPrefix(path)
+	if err != nil {
+		return err
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	for key := range o.objects {
+		if strings.HasPrefix(key, prefix) {
+			delete(o.objects, key)
+		}
+	}
+	return nil
+}
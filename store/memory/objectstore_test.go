@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/aldelucca1/docsend_scraper/store"
+)
+
+func TestObjectStore(t *testing.T) {
+	o := NewObjectStore()
+
+	if _, err := o.Read("a/b.pdf"); err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound reading a missing object, got %v", err)
+	}
+	if err := o.Write("a/b.pdf", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := o.Exists("a/b.pdf"); !exists {
+		t.Error("expected the written object to exist")
+	}
+
+	reader, err := o.Read("a/b.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(reader)
+	if string(data) != "data" {
+		t.Errorf("expected to read back %q, got %q", "data", data)
+	}
+
+	if err := o.Delete("a/b.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Delete("a/b.pdf"); err != nil {
+		t.Errorf("expected deleting a missing object to succeed, got %v", err)
+	}
+	if exists, _ := o.Exists("a/b.pdf"); exists {
+		t.Error("expected the deleted object not to exist")
+	}
+}
+
+func TestObjectStoreDeletePrefix(t *testing.T) {
+	o := NewObjectStore()
+	keys := []string{"cache/a/v1/page-0001.json", "cache/a/v1/page-0001.img", "cache/a/v10/page-0001.json", "cache/a/v1.pdf"}
+	for _, key := range keys {
+		if err := o.Write(key, strings.NewReader("data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, prefix := range []string{"", "/", "."} {
+		if err := o.DeletePrefix(prefix); err != store.ErrEmptyPrefix {
+			t.Errorf("%q: expected ErrEmptyPrefix, got %v", prefix, err)
+		}
+	}
+	if err := o.DeletePrefix("cache/a/v1/"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, key := range keys {
+		exists, _ := o.Exists(key)
+		if deleted := i < 2; exists == deleted {
+			t.Errorf("%s: expected deleted %v, exists %v", key, deleted, exists)
+		}
+	}
+}
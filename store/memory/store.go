@@ -0,0 +1,507 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/store"
+	"github.com/globalsign/mgo/bson"
+)
+
+// newObjectID generates the ids of inserted documents and collections
+var newObjectID = bson.NewObjectId
+
+// NewStore - Create a new in-memory datastore
+func NewStore() *Store {
+	m := new(Store)
+	m.documents = make(map[string]*model.Document)
+	m.collections = make(map[string]*model.Collection)
+	return m
+}
+
+// Store is a Datastore held entirely in memory.  Its contents are lost when
+// the process exits, making it suitable for tests and ephemeral runs
+type Store struct {
+	mutex       sync.RWMutex
+	documents   map[string]*model.Document
+	collections map[string]*model.Collection
+}
+
+// Connect - Nothing to connect to for the in-memory store
+func (m *Store) Connect() error {
+	return nil
+}
+
+// Close - Nothing to close for the in-memory store
+func (m *Store) Close() {
+}
+
+// GetDocuments gets the set of documents for a supplied owner
+func (m *Store) GetDocuments(owner string) ([]*model.Document, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	docs := make([]*model.Document, 0)
+	for _, doc := range m.documents {
+		if doc.Owner == owner {
+			docs = append(docs, copyDocument(doc))
+		}
+	}
+
+	// Order newest first, matching the mongodb store
+	sort.SliceStable(docs, func(i, j int) bool {
+		if docs[i].Created == docs[j].Created {
+			return docs[i].ID > docs[j].ID
+		}
+		return docs[i].Created > docs[j].Created
+	})
+
+	return docs, nil
+}
+
+// GetDocument gets the document with the supplied id
+func (m *Store) GetDocument(id string) (*model.Document, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	doc, ok := m.documents[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return copyDocument(doc), nil
+}
+
+// GetDocumentsByStatus gets the documents in any of the supplied statuses,
+// oldest first
+func (m *Store) GetDocumentsByStatus(statuses ...model.Status) ([]*model.Document, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	docs := make([]*model.Document, 0)
+	for _, doc := range m.documents {
+		for _, status := range statuses {
+			if doc.Status == status {
+				docs = append(docs, copyDocument(doc))
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		if docs[i].Created == docs[j].Created {
+			return docs[i].ID < docs[j].ID
+		}
+		return docs[i].Created < docs[j].Created
+	})
+
+	return docs, nil
+}
+
+// InsertDocument inserts the supplied document
+func (m *Store) InsertDocument(doc *model.Document) (*model.Document, error) {
+
+	now := makeTimestamp()
+
+	doc.ID = newObjectID()
+	doc.Status = model.StatusPending
+	doc.StatusDetails = []model.StatusDetail{
+		model.StatusDetail{
+			Message: "Request Submitted",
+			Created: now,
+		},
+	}
+	doc.Created = now
+	doc.LastUpdated = now
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.documents[doc.ID.Hex()]; ok {
+		return nil, store.ErrDuplicateKey
+	}
+	m.documents[doc.ID.Hex()] = copyDocument(doc)
+
+	return copyDocument(doc), nil
+}
+
+// UpdateStatus updates the document's status
+func (m *Store) UpdateStatus(id string, status model.Status, message string) (*model.Document, error) {
+
+	now := makeTimestamp()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	doc, ok := m.documents[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	doc.Status = status
+	doc.LastUpdated = now
+	if status != model.StatusAwaitingInput {
+		doc.Challenge = nil
+		doc.Job.Answer = ""
+	}
+
+	// Prepend the message so the newest detail is first
+	if message != "" {
+		doc.StatusDetails = append([]model.StatusDetail{
+			model.StatusDetail{
+				Message: message,
+				Created: now,
+			},
+		}, doc.StatusDetails...)
+	}
+
+	return copyDocument(doc), nil
+}
+
+// AwaitInput sets the challenge a capture is waiting on the user to answer
+func (m *Store) AwaitInput(id string, challenge model.Challenge, message string) (*model.Document, error) {
+
+	now := makeTimestamp()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	doc, ok := m.documents[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	doc.Status = model.StatusAwaitingInput
+	doc.Challenge = &challenge
+	doc.Job.Answer = ""
+	doc.LastUpdated = now
+	if message != "" {
+		doc.StatusDetails = append([]model.StatusDetail{
+			model.StatusDetail{
+				Message: message,
+				Created: now,
+			},
+		}, doc.StatusDetails...)
+	}
+
+	return copyDocument(doc), nil
+}
+
+// AnswerChallenge records the user's answer to the challenge of a document
+// awaiting input
+func (m *Store) AnswerChallenge(id string, answer string) (*model.Document, error) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	doc, ok := m.documents[id]
+	if !ok || doc.Status != model.StatusAwaitingInput {
+		return nil, store.ErrNotFound
+	}
+
+	doc.Job.Answer = answer
+
+	return copyDocument(doc), nil
+}
+
+// StartVersion queues the capture of a new version of the document
+func (m *Store) StartVersion(id string, job model.Job, message string) (*model.Document, error) {
+
+	now := makeTimestamp()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	doc, ok := m.documents[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	doc.Job = job
+	doc.Status = model.StatusPending
+	doc.Progress = nil
+	doc.Challenge = nil
+	doc.LastUpdated = now
+	doc.StatusDetails = append([]model.StatusDetail{
+		model.StatusDetail{
+			Message: message,
+			Created: now,
+		},
+	}, doc.StatusDetails...)
+
+	return copyDocument(doc), nil
+}
+
+// AddVersion records a version produced by a capture
+func (m *Store) AddVersion(id string, version model.Version) (*model.Document, error) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	doc, ok := m.documents[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	doc.Versions = append(doc.Versions, version)
+	doc.Version = version.Number
+	doc.Capture = copyCapture(&version.Capture)
+
+	return copyDocument(doc), nil
+}
+
+// UpdateWatch sets the document's watch schedule, or stops watching it if nil
+func (m *Store) UpdateWatch(id string, watch *model.Watch) (*model.Document, error) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	doc, ok := m.documents[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	if watch != nil {
+		w := *watch
+		watch = &w
+	}
+	doc.Watch = watch
+
+	return copyDocument(doc), nil
+}
+
+// GetWatchedDocuments gets the watched documents due to be checked by the
+// supplied time
+func (m *Store) GetWatchedDocuments(due int64) ([]*model.Document, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	docs := make([]*model.Document, 0)
+	for _, doc := range m.documents {
+		if doc.Watch != nil && !doc.Watch.Revoked && doc.Watch.NextCheck <= due {
+			docs = append(docs, copyDocument(doc))
+		}
+	}
+	return docs, nil
+}
+
+// ClaimWatch claims the watch check of a document due at the supplied time
+func (m *Store) ClaimWatch(id string, due int64, next int64) (*model.Document, error) {
+
+	now := makeTimestamp()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	doc, ok := m.documents[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if doc.Watch == nil || doc.Watch.Revoked || doc.Watch.NextCheck != due {
+		return nil, store.ErrLeaseHeld
+	}
+
+	doc.Watch.NextCheck = next
+	doc.Watch.LastChecked = now
+
+	return copyDocument(doc), nil
+}
+
+// UpdateProgress updates the document's current capture progress
+func (m *Store) UpdateProgress(id string, progress model.Progress) (*model.Document, error) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	doc, ok := m.documents[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	doc.Progress = &progress
+
+	return copyDocument(doc), nil
+}
+
+// GetCollections gets the set of collections for a supplied owner
+func (m *Store) GetCollections(owner string) ([]*model.Collection, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	collections := make([]*model.Collection, 0)
+	for _, collection := range m.collections {
+		if collection.Owner == owner {
+			collections = append(collections, copyCollection(collection))
+		}
+	}
+
+	// Order newest first, matching the documents
+	sort.SliceStable(collections, func(i, j int) bool {
+		if collections[i].Created == collections[j].Created {
+			return collections[i].ID > collections[j].ID
+		}
+		return collections[i].Created > collections[j].Created
+	})
+
+	return collections, nil
+}
+
+// GetCollection gets the collection with the supplied id
+func (m *Store) GetCollection(id string) (*model.Collection, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	collection, ok := m.collections[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return copyCollection(collection), nil
+}
+
+// GetCollectionDocuments gets the documents of the collection with the
+// supplied id, in the order they were inserted
+func (m *Store) GetCollectionDocuments(id string) ([]*model.Document, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	docs := make([]*model.Document, 0)
+	for _, doc := range m.documents {
+		if doc.CollectionID == id {
+			docs = append(docs, copyDocument(doc))
+		}
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		if docs[i].Created == docs[j].Created {
+			return docs[i].ID < docs[j].ID
+		}
+		return docs[i].Created < docs[j].Created
+	})
+
+	return docs, nil
+}
+
+// InsertCollection inserts the supplied collection
+func (m *Store) InsertCollection(collection *model.Collection) (*model.Collection, error) {
+
+	collection.ID = newObjectID()
+	collection.Created = makeTimestamp()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.collections[collection.ID.Hex()]; ok {
+		return nil, store.ErrDuplicateKey
+	}
+	m.collections[collection.ID.Hex()] = copyCollection(collection)
+
+	return copyCollection(collection), nil
+}
+
+// AcquireLease acquires or renews the lease on an active document
+func (m *Store) AcquireLease(id string, owner string, expires int64) (*model.Document, error) {
+
+	now := makeTimestamp()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	doc, ok := m.documents[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if !doc.Leasable(owner, now) {
+		return nil, store.ErrLeaseHeld
+	}
+
+	doc.Job.LeaseOwner = owner
+	doc.Job.LeaseExpires = expires
+
+	return copyDocument(doc), nil
+}
+
+// ReleaseLease releases the lease on a document if held by the supplied owner
+func (m *Store) ReleaseLease(id string, owner string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	doc, ok := m.documents[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if doc.Job.LeaseOwner == owner {
+		doc.Job.LeaseOwner = ""
+		doc.Job.LeaseExpires = 0
+	}
+	return nil
+}
+
+// copyDocument - Copy the document so callers can't modify the stored value
+func copyDocument(doc *model.Document) *model.Document {
+	c := *doc
+	c.StatusDetails = append([]model.StatusDetail(nil), doc.StatusDetails...)
+	if doc.Progress != nil {
+		progress := *doc.Progress
+		c.Progress = &progress
+	}
+	if doc.Challenge != nil {
+		challenge := *doc.Challenge
+		c.Challenge = &challenge
+	}
+	if doc.Options.Optimize != nil {
+		optimize := *doc.Options.Optimize
+		c.Options.Optimize = &optimize
+	}
+	if doc.Capture != nil {
+		c.Capture = copyCapture(doc.Capture)
+	}
+	if doc.Watch != nil {
+		watch := *doc.Watch
+		c.Watch = &watch
+	}
+	if doc.Versions != nil {
+		c.Versions = make([]model.Version, len(doc.Versions))
+		for i, version := range doc.Versions {
+			version.Capture = *copyCapture(&version.Capture)
+			if version.Changes != nil {
+				changes := model.Changes{
+					Added:   append([]int{}, version.Changes.Added...),
+					Removed: append([]int{}, version.Changes.Removed...),
+					Changed: append([]int{}, version.Changes.Changed...),
+				}
+				version.Changes = &changes
+			}
+			c.Versions[i] = version
+		}
+	}
+	return &c
+}
+
+// copyCollection - Copy the collection so callers can't modify the stored
+// value.  The documents are read separately so aren't copied
+func copyCollection(collection *model.Collection) *model.Collection {
+	c := *collection
+	if collection.Options.Optimize != nil {
+		optimize := *collection.Options.Optimize
+		c.Options.Optimize = &optimize
+	}
+	c.Documents = nil
+	return &c
+}
+
+// copyCapture returns a deep copy of the capture
+func copyCapture(capture *model.Capture) *model.Capture {
+	c := *capture
+	if capture.Optimization != nil {
+		optimization := *capture.Optimization
+		c.Optimization = &optimization
+	}
+	c.PageHashes = append([]string(nil), capture.PageHashes...)
+	return &c
+}
+
+// makeTimestamp - Generates a timestamp from the current time
+func makeTimestamp() int64 {
+	return time.Now().UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
+}
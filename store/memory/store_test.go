@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/store"
+	"github.com/aldelucca1/docsend_scraper/store/storetest"
+	"github.com/globalsign/mgo/bson"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) *storetest.Fixture {
+		m := NewStore()
+		return &storetest.Fixture{
+			Datastore: m,
+			SetCreated: func(id string, created int64) {
+				if doc, ok := m.documents[id]; ok {
+					doc.Created = created
+				}
+				if collection, ok := m.collections[id]; ok {
+					collection.Created = created
+				}
+			},
+			Close: m.Close,
+		}
+	})
+}
+
+func TestDuplicateKey(t *testing.T) {
+	id := bson.NewObjectId()
+	newObjectID = func() bson.ObjectId { return id }
+	defer func() { newObjectID = bson.NewObjectId }()
+
+	m := NewStore()
+	if _, err := m.InsertDocument(&model.Document{Owner: "a@example.com"}); err != nil {
+		t.Fatalf("first insert failed: %s", err)
+	}
+	if _, err := m.InsertDocument(&model.Document{Owner: "a@example.com"}); err != store.ErrDuplicateKey {
+		t.Errorf("expected ErrDuplicateKey inserting a document, got %v", err)
+	}
+	if _, err := m.InsertCollection(&model.Collection{Owner: "a@example.com"}); err != nil {
+		t.Fatalf("first collection insert failed: %s", err)
+	}
+	if _, err := m.InsertCollection(&model.Collection{Owner: "a@example.com"}); err != store.ErrDuplicateKey {
+		t.Errorf("expected ErrDuplicateKey inserting a collection, got %v", err)
+	}
+}
+
+func TestDocumentsAreCopied(t *testing.T) {
+	m := NewStore()
+	doc, err := m.InsertDocument(&model.Document{Owner: "a@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc.Owner = "b@example.com"
+	doc.StatusDetails[0].Message = "changed"
+
+	stored, err := m.GetDocument(doc.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Owner != "a@example.com" || stored.StatusDetails[0].Message != "Request Submitted" {
+		t.Errorf("changing the returned document changed the stored one: %+v", stored)
+	}
+}
@@ -0,0 +1,132 @@
+package mongo
+
+import (
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/store"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// CollectionCollection is the collection the holds the collection objects
+const CollectionCollection = "collection"
+
+func init() {
+	indexes[CollectionCollection] = []mgo.Index{
+		mgo.Index{Name: "idx_collection_owner", Key: []string{"owner"}},
+	}
+}
+
+// GetCollections gets the set of collections for a supplied owner
+func (s *Store) GetCollections(owner string) ([]*model.Collection, error) {
+
+	// Create the query
+	query := bson.M{"owner": owner}
+
+	// Acquire a mongodb session
+	session, err := s.getSession()
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+	defer session.Close()
+
+	// Query the list of collections for the supplied owner
+	collections := make([]*model.Collection, 0)
+
+	db := session.DB(s.config.db)
+	c := db.C(CollectionCollection)
+	q := c.Find(query).Sort("-created", "-_id")
+
+	iter := q.Iter()
+	for collection := new(model.Collection); iter.Next(&collection); collection = new(model.Collection) {
+		collections = append(collections, collection)
+	}
+	if err = iter.Close(); err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// GetCollection gets the collection with the supplied id
+func (s *Store) GetCollection(id string) (*model.Collection, error) {
+
+	// Validate the supplied ID is in fact a MongoDB ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	// Acquire a mongodb session
+	session, err := s.getSession()
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+	defer session.Close()
+
+	// Get the Collection
+	var collection *model.Collection
+
+	db := session.DB(s.config.db)
+	c := db.C(CollectionCollection)
+	err = c.FindId(bson.ObjectIdHex(id)).One(&collection)
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+
+	return collection, nil
+}
+
+// GetCollectionDocuments gets the documents of the collection with the
+// supplied id, in the order they were inserted
+func (s *Store) GetCollectionDocuments(id string) ([]*model.Document, error) {
+
+	// Create the query
+	query := bson.M{"collection_id": id}
+
+	// Acquire a mongodb session
+	session, err := s.getSession()
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+	defer session.Close()
+
+	// Query the list of documents in the collection
+	docs := make([]*model.Document, 0)
+
+	db := session.DB(s.config.db)
+	c := db.C(DocumentCollection)
+	q := c.Find(query).Sort("created", "_id")
+
+	iter := q.Iter()
+	for doc := new(model.Document); iter.Next(&doc); doc = new(model.Document) {
+		docs = append(docs, doc)
+	}
+	if err = iter.Close(); err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// InsertCollection inserts the supplied collection
+func (s *Store) InsertCollection(collection *model.Collection) (*model.Collection, error) {
+
+	collection.ID = bson.NewObjectId()
+	collection.Created = makeTimestamp()
+
+	// Acquire a mongodb session
+	session, err := s.getSession()
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+	defer session.Close()
+
+	// Insert the Collection
+	db := session.DB(s.config.db)
+	c := db.C(CollectionCollection)
+	err = c.Insert(collection)
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+
+	return collection, nil
+}
@@ -1,8 +1,6 @@
 package mongo
 
 import (
-	"os"
-
 	"github.com/globalsign/mgo"
 )
 
@@ -25,11 +23,11 @@ type Config struct {
 // NewConfig - Creates a new Config with the default values
 func NewConfig() *Config {
 	return &Config{
-		endpoints:   []string{os.Getenv("MONGO_HOST")},
+		endpoints:   []string{"localhost"},
 		replicaSet:  "",
 		db:          "docsend",
 		consistency: mgo.PrimaryPreferred,
-		credentials: &Credentials{username: "docsend", password: os.Getenv("MONGO_PWD")},
+		credentials: &Credentials{username: "docsend", password: ""},
 	}
 }
 
@@ -13,6 +13,9 @@ const DocumentCollection = "document"
 func init() {
 	indexes[DocumentCollection] = []mgo.Index{
 		mgo.Index{Name: "idx_document_owner", Key: []string{"owner"}},
+		mgo.Index{Name: "idx_document_status", Key: []string{"status", "created"}},
+		mgo.Index{Name: "idx_document_watch", Key: []string{"watch.next_check"}, Sparse: true},
+		mgo.Index{Name: "idx_document_collection", Key: []string{"collection_id", "created"}, Sparse: true},
 	}
 }
 
@@ -34,7 +37,7 @@ func (s *Store) GetDocuments(owner string) ([]*model.Document, error) {
 
 	db := session.DB(s.config.db)
 	c := db.C(DocumentCollection)
-	q := c.Find(query).Sort("-created")
+	q := c.Find(query).Sort("-created", "-_id")
 
 	iter := q.Iter()
 	for doc := new(model.Document); iter.Next(&doc); doc = new(model.Document) {
@@ -75,25 +78,53 @@ func (s *Store) GetDocument(id string) (*model.Document, error) {
 	return doc, nil
 }
 
+// GetDocumentsByStatus gets the documents in any of the supplied statuses,
+// oldest first
+func (s *Store) GetDocumentsByStatus(statuses ...model.Status) ([]*model.Document, error) {
+
+	// Create the query
+	query := bson.M{"status": bson.M{"$in": statuses}}
+
+	// Acquire a mongodb session
+	session, err := s.getSession()
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+	defer session.Close()
+
+	// Query the list of documents in the supplied statuses
+	docs := make([]*model.Document, 0)
+
+	db := session.DB(s.config.db)
+	c := db.C(DocumentCollection)
+	q := c.Find(query).Sort("created", "_id")
+
+	iter := q.Iter()
+	for doc := new(model.Document); iter.Next(&doc); doc = new(model.Document) {
+		docs = append(docs, doc)
+	}
+	if err = iter.Close(); err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
 // InsertDocument inserts the supplied document
-func (s *Store) InsertDocument(sourceURL string, owner string) (*model.Document, error) {
+func (s *Store) InsertDocument(doc *model.Document) (*model.Document, error) {
 
 	now := makeTimestamp()
 
-	doc := &model.Document{
-		ID:        bson.NewObjectId(),
-		Owner:     owner,
-		SourceURL: sourceURL,
-		Status:    model.StatusPending,
-		StatusDetails: []model.StatusDetail{
-			model.StatusDetail{
-				Message: "Request Submitted",
-				Created: now,
-			},
+	doc.ID = bson.NewObjectId()
+	doc.Status = model.StatusPending
+	doc.StatusDetails = []model.StatusDetail{
+		model.StatusDetail{
+			Message: "Request Submitted",
+			Created: now,
 		},
-		Created:     now,
-		LastUpdated: now,
 	}
+	doc.Created = now
+	doc.LastUpdated = now
 
 	// Acquire a mongodb session
 	session, err := s.getSession()
@@ -130,6 +161,12 @@ func (s *Store) UpdateStatus(id string, status model.Status, message string) (*m
 			"last_updated": now,
 		},
 	}
+	if status != model.StatusAwaitingInput {
+		update["$unset"] = bson.M{
+			"challenge":  "",
+			"job.answer": "",
+		}
+	}
 	if message != "" {
 		update["$push"] = bson.M{
 			"status_details": bson.M{
@@ -163,3 +200,432 @@ func (s *Store) UpdateStatus(id string, status model.Status, message string) (*m
 
 	return doc, nil
 }
+
+// AwaitInput sets the challenge a capture is waiting on the user to answer
+func (s *Store) AwaitInput(id string, challenge model.Challenge, message string) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact a MongoDB ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	now := makeTimestamp()
+
+	// Create our update document
+	update := bson.M{
+		"$set": bson.M{
+			"status":       model.StatusAwaitingInput,
+			"challenge":    challenge,
+			"last_updated": now,
+		},
+		"$unset": bson.M{
+			"job.answer": "",
+		},
+	}
+	if message != "" {
+		update["$push"] = bson.M{
+			"status_details": bson.M{
+				"$each": []model.StatusDetail{
+					model.StatusDetail{
+						Message: message,
+						Created: now,
+					},
+				},
+				"$position": 0,
+			},
+		}
+	}
+
+	// Acquire a mongodb session
+	session, err := s.getSession()
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+	defer session.Close()
+
+	// Find and update the document
+	var doc *model.Document
+
+	db := session.DB(s.config.db)
+	c := db.C(DocumentCollection)
+	_, err = c.FindId(bson.ObjectIdHex(id)).Apply(mgo.Change{Update: update, ReturnNew: true}, &doc)
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// AnswerChallenge records the user's answer to the challenge of a document
+// awaiting input
+func (s *Store) AnswerChallenge(id string, answer string) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact a MongoDB ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	// Only match the document while it's awaiting input
+	query := bson.M{
+		"_id":    bson.ObjectIdHex(id),
+		"status": model.StatusAwaitingInput,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"job.answer": answer,
+		},
+	}
+
+	// Acquire a mongodb session
+	session, err := s.getSession()
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+	defer session.Close()
+
+	// Find and update the document
+	var doc *model.Document
+
+	db := session.DB(s.config.db)
+	c := db.C(DocumentCollection)
+	_, err = c.Find(query).Apply(mgo.Change{Update: update, ReturnNew: true}, &doc)
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// StartVersion queues the capture of a new version of the document
+func (s *Store) StartVersion(id string, job model.Job, message string) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact a MongoDB ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	now := makeTimestamp()
+
+	// Create our update document
+	update := bson.M{
+		"$set": bson.M{
+			"job":          job,
+			"status":       model.StatusPending,
+			"last_updated": now,
+		},
+		"$unset": bson.M{
+			"progress":  "",
+			"challenge": "",
+		},
+		"$push": bson.M{
+			"status_details": bson.M{
+				"$each": []model.StatusDetail{
+					model.StatusDetail{
+						Message: message,
+						Created: now,
+					},
+				},
+				"$position": 0,
+			},
+		},
+	}
+
+	// Acquire a mongodb session
+	session, err := s.getSession()
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+	defer session.Close()
+
+	// Find and update the document
+	var doc *model.Document
+
+	db := session.DB(s.config.db)
+	c := db.C(DocumentCollection)
+	_, err = c.FindId(bson.ObjectIdHex(id)).Apply(mgo.Change{Update: update, ReturnNew: true}, &doc)
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// AddVersion records a version produced by a capture
+func (s *Store) AddVersion(id string, version model.Version) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact a MongoDB ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	// Create our update document
+	update := bson.M{
+		"$set": bson.M{
+			"version": version.Number,
+			"capture": version.Capture,
+		},
+		"$push": bson.M{
+			"versions": version,
+		},
+	}
+
+	// Acquire a mongodb session
+	session, err := s.getSession()
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+	defer session.Close()
+
+	// Find and update the document
+	var doc *model.Document
+
+	db := session.DB(s.config.db)
+	c := db.C(DocumentCollection)
+	_, err = c.FindId(bson.ObjectIdHex(id)).Apply(mgo.Change{Update: update, ReturnNew: true}, &doc)
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// UpdateWatch sets the document's watch schedule, or stops watching it if nil
+func (s *Store) UpdateWatch(id string, watch *model.Watch) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact a MongoDB ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	// Create our update document
+	update := bson.M{"$set": bson.M{"watch": watch}}
+	if watch == nil {
+		update = bson.M{"$unset": bson.M{"watch": ""}}
+	}
+
+	// Acquire a mongodb session
+	session, err := s.getSession()
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+	defer session.Close()
+
+	// Find and update the document
+	var doc *model.Document
+
+	db := session.DB(s.config.db)
+	c := db.C(DocumentCollection)
+	_, err = c.FindId(bson.ObjectIdHex(id)).Apply(mgo.Change{Update: update, ReturnNew: true}, &doc)
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// GetWatchedDocuments gets the watched documents due to be checked by the
+// supplied time
+func (s *Store) GetWatchedDocuments(due int64) ([]*model.Document, error) {
+
+	// Create the query
+	query := bson.M{
+		"watch.next_check": bson.M{"$lte": due},
+		"watch.revoked":    false,
+	}
+
+	// Acquire a mongodb session
+	session, err := s.getSession()
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+	defer session.Close()
+
+	// Query the list of documents due to be checked
+	docs := make([]*model.Document, 0)
+
+	db := session.DB(s.config.db)
+	c := db.C(DocumentCollection)
+	q := c.Find(query)
+
+	iter := q.Iter()
+	for doc := new(model.Document); iter.Next(&doc); doc = new(model.Document) {
+		docs = append(docs, doc)
+	}
+	if err = iter.Close(); err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// ClaimWatch claims the watch check of a document due at the supplied time
+func (s *Store) ClaimWatch(id string, due int64, next int64) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact a MongoDB ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	now := makeTimestamp()
+
+	// Only match the document if the check hasn't been claimed already
+	query := bson.M{
+		"_id":              bson.ObjectIdHex(id),
+		"watch.next_check": due,
+		"watch.revoked":    false,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"watch.next_check":   next,
+			"watch.last_checked": now,
+		},
+	}
+
+	// Acquire a mongodb session
+	session, err := s.getSession()
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+	defer session.Close()
+
+	// Find and update the document
+	var doc *model.Document
+
+	db := session.DB(s.config.db)
+	c := db.C(DocumentCollection)
+	_, err = c.Find(query).Apply(mgo.Change{Update: update, ReturnNew: true}, &doc)
+	if err == mgo.ErrNotFound {
+
+		// Distinguish a missing document from a check already claimed
+		if n, cerr := c.FindId(bson.ObjectIdHex(id)).Count(); cerr == nil && n > 0 {
+			return nil, store.ErrLeaseHeld
+		}
+	}
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// UpdateProgress updates the document's current capture progress
+func (s *Store) UpdateProgress(id string, progress model.Progress) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact a MongoDB ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	// Create our update document
+	update := bson.M{
+		"$set": bson.M{
+			"progress": progress,
+		},
+	}
+
+	// Acquire a mongodb session
+	session, err := s.getSession()
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+	defer session.Close()
+
+	// Find and update the document
+	var doc *model.Document
+
+	db := session.DB(s.config.db)
+	c := db.C(DocumentCollection)
+	_, err = c.FindId(bson.ObjectIdHex(id)).Apply(mgo.Change{Update: update, ReturnNew: true}, &doc)
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// AcquireLease acquires or renews the lease on an active document
+func (s *Store) AcquireLease(id string, owner string, expires int64) (*model.Document, error) {
+
+	// Validate the supplied ID is in fact a MongoDB ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return nil, store.ErrNotFound
+	}
+
+	now := makeTimestamp()
+
+	// Only match the document if the lease is free, expired or already ours
+	query := bson.M{
+		"_id":    bson.ObjectIdHex(id),
+		"status": bson.M{"$in": []model.Status{model.StatusPending, model.StatusCapturing, model.StatusAwaitingInput}},
+		"$or": []bson.M{
+			bson.M{"job.lease_owner": bson.M{"$exists": false}},
+			bson.M{"job.lease_owner": owner},
+			bson.M{"job.lease_expires": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"job.lease_owner":   owner,
+			"job.lease_expires": expires,
+		},
+	}
+
+	// Acquire a mongodb session
+	session, err := s.getSession()
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+	defer session.Close()
+
+	// Find and update the document
+	var doc *model.Document
+
+	db := session.DB(s.config.db)
+	c := db.C(DocumentCollection)
+	_, err = c.Find(query).Apply(mgo.Change{Update: update, ReturnNew: true}, &doc)
+	if err == mgo.ErrNotFound {
+
+		// Distinguish a missing document from one we can't lease
+		if n, cerr := c.FindId(bson.ObjectIdHex(id)).Count(); cerr == nil && n > 0 {
+			return nil, store.ErrLeaseHeld
+		}
+	}
+	if err != nil {
+		return nil, s.handleError(err)
+	}
+
+	return doc, nil
+}
+
+// ReleaseLease releases the lease on a document if held by the supplied owner
+func (s *Store) ReleaseLease(id string, owner string) error {
+
+	// Validate the supplied ID is in fact a MongoDB ObjectID
+	if !bson.IsObjectIdHex(id) {
+		return store.ErrNotFound
+	}
+
+	query := bson.M{"_id": bson.ObjectIdHex(id), "job.lease_owner": owner}
+	update := bson.M{
+		"$unset": bson.M{
+			"job.lease_owner":   "",
+			"job.lease_expires": "",
+		},
+	}
+
+	// Acquire a mongodb session
+	session, err := s.getSession()
+	if err != nil {
+		return s.handleError(err)
+	}
+	defer session.Close()
+
+	db := session.DB(s.config.db)
+	c := db.C(DocumentCollection)
+	err = c.Update(query, update)
+	if err != nil && err != mgo.ErrNotFound {
+		return s.handleError(err)
+	}
+
+	return nil
+}
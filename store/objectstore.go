@@ -1,6 +1,10 @@
 package store
 
-import "io"
+import (
+	"io"
+	"path"
+	"strings"
+)
 
 // ObjectStore is an interface to an underlying object storage
 type ObjectStore interface {
@@ -13,4 +17,23 @@ type ObjectStore interface {
 
 	// Read an object from the given path
 	Read(path string) (io.Reader, error)
+
+	// Delete the object at the given path, deleting a missing object is not an
+	// error
+	Delete(path string) error
+
+	// Delete every object beneath the given path, as though it were a
+	// directory.  The path must not be empty
+	DeletePrefix(path string) error
+}
+
+// DirPrefix returns the path as a key prefix ending in a slash, so it only
+// matches the objects beneath it and not its siblings, e.g. cache/a/v1 and
+// cache/a/v10.  Returns ErrEmptyPrefix if the path would match every object
+func DirPrefix(p string) (string, error) {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return "", ErrEmptyPrefix
+	}
+	return p + "/", nil
 }
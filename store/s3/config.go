@@ -0,0 +1,82 @@
+package s3
+
+// minPartSize - The smallest part size S3 accepts for all but the last part of
+// a multipart upload
+const minPartSize = 5 * 1024 * 1024
+
+// Credentials - The access key pair used to sign requests
+type Credentials struct {
+	accessKey string
+	secretKey string
+}
+
+// Config - The configuration information for connecting to an S3 compatible
+// object storage service
+type Config struct {
+	endpoint    string
+	region      string
+	secure      bool
+	bucket      string
+	prefix      string
+	partSize    int64
+	credentials *Credentials
+}
+
+// NewConfig - Creates a new Config with the default values
+func NewConfig() *Config {
+	return &Config{
+		endpoint:    "s3.amazonaws.com",
+		region:      "",
+		secure:      true,
+		bucket:      "",
+		prefix:      "",
+		partSize:    minPartSize,
+		credentials: &Credentials{accessKey: "", secretKey: ""},
+	}
+}
+
+// WithEndpoint - Set the host (and optional port) of the service
+func (c *Config) WithEndpoint(endpoint string) *Config {
+	c.endpoint = endpoint
+	return c
+}
+
+// WithRegion - Set the region the bucket lives in
+func (c *Config) WithRegion(region string) *Config {
+	c.region = region
+	return c
+}
+
+// WithSecure - Set whether to connect over TLS
+func (c *Config) WithSecure(secure bool) *Config {
+	c.secure = secure
+	return c
+}
+
+// WithBucket - Set the bucket objects are stored in
+func (c *Config) WithBucket(bucket string) *Config {
+	c.bucket = bucket
+	return c
+}
+
+// WithPrefix - Set the key prefix prepended to every object path
+func (c *Config) WithPrefix(prefix string) *Config {
+	c.prefix = prefix
+	return c
+}
+
+// WithPartSize - Set the size of each part of a multipart upload.  Values
+// below the S3 minimum of 5MiB are raised to the minimum
+func (c *Config) WithPartSize(partSize int64) *Config {
+	if partSize < minPartSize {
+		partSize = minPartSize
+	}
+	c.partSize = partSize
+	return c
+}
+
+// WithCredentials - Set the access key pair to use when connecting
+func (c *Config) WithCredentials(accessKey string, secretKey string) *Config {
+	c.credentials = &Credentials{accessKey: accessKey, secretKey: secretKey}
+	return c
+}
@@ -0,0 +1,221 @@
+package s3
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"path"
+	"sync"
+
+	"github.com/aldelucca1/docsend_scraper/store"
+	minio "github.com/minio/minio-go"
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	// errorCodeNoSuchKey - The S3 error code for a missing object
+	errorCodeNoSuchKey = "NoSuchKey"
+)
+
+// NewStore - Create a new S3 backed object store
+func NewStore(config *Config) *Store {
+	s := new(Store)
+	s.config = config
+	return s
+}
+
+// Store is an ObjectStore backed by an S3 compatible service such as AWS S3 or
+// MinIO
+type Store struct {
+	config *Config
+	mutex  sync.Mutex
+	core   *minio.Core
+}
+
+// Exists checks if an object exists at the given path
+func (s *Store) Exists(pathStr string) (bool, error) {
+
+	core, err := s.getCore()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = core.StatObject(s.config.bucket, s.key(pathStr), minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == errorCodeNoSuchKey {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Write an object to the given path.  The reader is streamed to the bucket in
+// parts so the whole object is never held in memory
+func (s *Store) Write(pathStr string, reader io.Reader) error {
+
+	core, err := s.getCore()
+	if err != nil {
+		return err
+	}
+
+	key := s.key(pathStr)
+	contentType := mime.TypeByExtension(path.Ext(pathStr))
+	buf := make([]byte, s.config.partSize)
+
+	// Read the first part, if the object fits in a single part skip the
+	// multipart upload entirely
+	n, err := io.ReadFull(reader, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		metadata := map[string]string{}
+		if contentType != "" {
+			metadata["Content-Type"] = contentType
+		}
+		_, err = core.PutObject(s.config.bucket, key, bytes.NewReader(buf[:n]), int64(n), "", "", metadata, nil)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	uploadID, err := core.NewMultipartUpload(s.config.bucket, key, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return err
+	}
+
+	parts := make([]minio.CompletePart, 0)
+	for partID := 1; n > 0; partID++ {
+
+		part, err := core.PutObjectPart(s.config.bucket, key, uploadID, partID, bytes.NewReader(buf[:n]), int64(n), "", "", nil)
+		if err != nil {
+			s.abort(core, key, uploadID)
+			return err
+		}
+		parts = append(parts, minio.CompletePart{PartNumber: part.PartNumber, ETag: part.ETag})
+
+		// Read the next part, a short read is the final part
+		n, err = io.ReadFull(reader, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			s.abort(core, key, uploadID)
+			return err
+		}
+	}
+
+	_, err = core.CompleteMultipartUpload(s.config.bucket, key, uploadID, parts)
+	if err != nil {
+		s.abort(core, key, uploadID)
+	}
+	return err
+}
+
+// Read an object from the given path
+func (s *Store) Read(pathStr string) (io.Reader, error) {
+
+	core, err := s.getCore()
+	if err != nil {
+		return nil, err
+	}
+
+	reader, _, err := core.GetObject(s.config.bucket, s.key(pathStr), minio.GetObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == errorCodeNoSuchKey {
+			return nil, store.ErrNotFound
+		}
+		return nil, err
+	}
+	return reader, nil
+}
+
+// Delete the object at the given path
+func (s *Store) Delete(pathStr string) error {
+
+	core, err := s.getCore()
+	if err != nil {
+		return err
+	}
+
+	err = core.RemoveObject(s.config.bucket, s.key(pathStr))
+	if err != nil && minio.ToErrorResponse(err).Code != errorCodeNoSuchKey {
+		return err
+	}
+	return nil
+}
+
+// DeletePrefix deletes every object beneath the given path
+func (s *Store) DeletePrefix(pathStr string) error {
+
+	prefix, err := store.DirPrefix(pathStr)
+	if err != nil {
+		return err
+	}
+
+	core, err := s.getCore()
+	if err != nil {
+		return err
+	}
+
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	// List the objects into the batch delete, the first error listing is
+	// returned once the delete has drained the keys already listed
+	var listErr error
+	keys := make(chan string)
+	go func() {
+		defer close(keys)
+		for object := range core.Client.ListObjectsV2(s.config.bucket, s.key(prefix)+"/", true, doneCh) {
+			if object.Err != nil {
+				listErr = object.Err
+				return
+			}
+			keys <- object.Key
+		}
+	}()
+
+	for removeErr := range core.Client.RemoveObjects(s.config.bucket, keys) {
+		if err == nil {
+			err = removeErr.Err
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return listErr
+}
+
+// key - Map an object path to its key within the bucket
+func (s *Store) key(pathStr string) string {
+	return path.Join(s.config.prefix, pathStr)
+}
+
+// abort - Abort a failed multipart upload so its parts aren't left behind
+func (s *Store) abort(core *minio.Core, key string, uploadID string) {
+	if err := core.AbortMultipartUpload(s.config.bucket, key, uploadID); err != nil {
+		logger.Warnf("Failed to abort multipart upload of %s: %s", key, err.Error())
+	}
+}
+
+// getCore - Lazily create the S3 client on first use
+func (s *Store) getCore() (*minio.Core, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.core == nil {
+
+		logger.Infof("Connecting to s3 at: %s, bucket: %s", s.config.endpoint, s.config.bucket)
+
+		client, err := minio.NewWithRegion(
+			s.config.endpoint,
+			s.config.credentials.accessKey,
+			s.config.credentials.secretKey,
+			s.config.secure,
+			s.config.region,
+		)
+		if err != nil {
+			logger.Errorf("Failed to create s3 client: %s", err.Error())
+			return nil, err
+		}
+		s.core = &minio.Core{Client: client}
+	}
+	return s.core, nil
+}
@@ -0,0 +1,367 @@
+// Package storetest holds the behavioural tests every Datastore must pass, so
+// the backends are checked to order, lease and guard documents alike
+package storetest
+
+import (
+	"testing"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+	"github.com/aldelucca1/docsend_scraper/store"
+	"github.com/globalsign/mgo/bson"
+)
+
+// Fixture is a connected, empty Datastore under test
+type Fixture struct {
+	Datastore store.Datastore
+
+	// SetCreated overwrites when the document or collection with the id was
+	// created, which the Datastore otherwise sets itself, so ties can be
+	// tested
+	SetCreated func(id string, created int64)
+
+	// Close closes the Datastore and removes anything it left behind
+	Close func()
+}
+
+// Run runs the behavioural tests against the Datastores returned by open,
+// each test on a Datastore of its own
+func Run(t *testing.T, open func(t *testing.T) *Fixture) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, f *Fixture)
+	}{
+		{"NotFound", testNotFound},
+		{"GetDocumentsNewestFirst", testGetDocumentsNewestFirst},
+		{"GetCollectionsNewestFirst", testGetCollectionsNewestFirst},
+		{"UpdateStatusPrependsDetail", testUpdateStatusPrependsDetail},
+		{"AnswerChallenge", testAnswerChallenge},
+		{"AcquireLease", testAcquireLease},
+		{"ClaimWatch", testClaimWatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := open(t)
+			defer f.Close()
+			tt.fn(t, f)
+		})
+	}
+}
+
+// insert inserts a document for the owner, failing the test if it can't
+func insert(t *testing.T, ds store.Datastore, doc *model.Document) string {
+	inserted, err := ds.InsertDocument(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inserted.ID.Hex()
+}
+
+func testNotFound(t *testing.T, f *Fixture) {
+	ds := f.Datastore
+	for _, id := range []string{bson.NewObjectId().Hex(), "not-an-id"} {
+		tests := []struct {
+			name string
+			fn   func() error
+		}{
+			{"GetDocument", func() error { _, err := ds.GetDocument(id); return err }},
+			{"UpdateStatus", func() error { _, err := ds.UpdateStatus(id, model.StatusComplete, "done"); return err }},
+			{"AwaitInput", func() error { _, err := ds.AwaitInput(id, model.Challenge{}, ""); return err }},
+			{"AnswerChallenge", func() error { _, err := ds.AnswerChallenge(id, "123456"); return err }},
+			{"UpdateProgress", func() error { _, err := ds.UpdateProgress(id, model.Progress{}); return err }},
+			{"StartVersion", func() error { _, err := ds.StartVersion(id, model.Job{}, "again"); return err }},
+			{"AddVersion", func() error { _, err := ds.AddVersion(id, model.Version{Number: 1}); return err }},
+			{"UpdateWatch", func() error { _, err := ds.UpdateWatch(id, nil); return err }},
+			{"ClaimWatch", func() error { _, err := ds.ClaimWatch(id, 1, 2); return err }},
+			{"AcquireLease", func() error { _, err := ds.AcquireLease(id, "owner", 1); return err }},
+			{"ReleaseLease", func() error { return ds.ReleaseLease(id, "owner") }},
+			{"GetCollection", func() error { _, err := ds.GetCollection(id); return err }},
+		}
+		for _, tt := range tests {
+			if err := tt.fn(); err != store.ErrNotFound {
+				t.Errorf("%s(%q): expected ErrNotFound, got %v", tt.name, id, err)
+			}
+		}
+	}
+}
+
+func testGetDocumentsNewestFirst(t *testing.T, f *Fixture) {
+	ds := f.Datastore
+
+	// Created times, with a tie broken by the id
+	created := []int64{100, 300, 200, 300}
+	ids := make([]string, len(created))
+	for i, c := range created {
+		ids[i] = insert(t, ds, &model.Document{Owner: "a@example.com"})
+		f.SetCreated(ids[i], c)
+	}
+	insert(t, ds, &model.Document{Owner: "b@example.com"})
+
+	docs, err := ds.GetDocuments("a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{ids[3], ids[1], ids[2], ids[0]}
+	if len(docs) != len(expected) {
+		t.Fatalf("expected %d documents, got %d", len(expected), len(docs))
+	}
+	for i, doc := range docs {
+		if doc.ID.Hex() != expected[i] {
+			t.Errorf("document %d: expected %s, got %s", i, expected[i], doc.ID.Hex())
+		}
+	}
+
+	if docs, err := ds.GetDocuments("c@example.com"); err != nil || len(docs) != 0 {
+		t.Errorf("expected no documents for an unknown owner, got %d, %v", len(docs), err)
+	}
+
+	// Pending documents are listed oldest first for recovery
+	if _, err := ds.UpdateStatus(ids[2], model.StatusComplete, "done"); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := ds.GetDocumentsByStatus(model.StatusPending, model.StatusCapturing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{ids[0], ids[1], ids[3]}
+	if len(pending) != 4 {
+		t.Fatalf("expected the 4 pending documents, got %d", len(pending))
+	}
+	for i, id := range expected {
+		if pending[i].ID.Hex() != id {
+			t.Errorf("pending document %d: expected %s, got %s", i, id, pending[i].ID.Hex())
+		}
+	}
+}
+
+func testGetCollectionsNewestFirst(t *testing.T, f *Fixture) {
+	ds := f.Datastore
+
+	created := []int64{100, 200, 200}
+	ids := make([]string, len(created))
+	for i, c := range created {
+		collection, err := ds.InsertCollection(&model.Collection{Owner: "a@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = collection.ID.Hex()
+		f.SetCreated(ids[i], c)
+	}
+	if _, err := ds.InsertCollection(&model.Collection{Owner: "b@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	collections, err := ds.GetCollections("a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{ids[2], ids[1], ids[0]}
+	if len(collections) != len(expected) {
+		t.Fatalf("expected %d collections, got %d", len(expected), len(collections))
+	}
+	for i, collection := range collections {
+		if collection.ID.Hex() != expected[i] {
+			t.Errorf("collection %d: expected %s, got %s", i, expected[i], collection.ID.Hex())
+		}
+	}
+
+	// The documents of a collection are listed in the order inserted
+	docs := make([]string, 3)
+	for i := range docs {
+		docs[i] = insert(t, ds, &model.Document{Owner: "a@example.com", CollectionID: ids[0]})
+	}
+	insert(t, ds, &model.Document{Owner: "a@example.com", CollectionID: ids[1]})
+	f.SetCreated(docs[0], 300)
+	f.SetCreated(docs[1], 100)
+	f.SetCreated(docs[2], 100)
+
+	listed, err := ds.GetCollectionDocuments(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{docs[1], docs[2], docs[0]}
+	if len(listed) != len(expected) {
+		t.Fatalf("expected %d collection documents, got %d", len(expected), len(listed))
+	}
+	for i, doc := range listed {
+		if doc.ID.Hex() != expected[i] {
+			t.Errorf("collection document %d: expected %s, got %s", i, expected[i], doc.ID.Hex())
+		}
+	}
+}
+
+func testUpdateStatusPrependsDetail(t *testing.T, f *Fixture) {
+	ds := f.Datastore
+	id := insert(t, ds, &model.Document{Owner: "a@example.com"})
+
+	if _, err := ds.UpdateStatus(id, model.StatusCapturing, "Started capturing document"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.UpdateStatus(id, model.StatusCapturing, ""); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ds.UpdateStatus(id, model.StatusComplete, "Completed successfully")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Status != model.StatusComplete {
+		t.Errorf("expected status %s, got %s", model.StatusComplete, doc.Status)
+	}
+	expected := []string{"Completed successfully", "Started capturing document", "Request Submitted"}
+	if len(doc.StatusDetails) != len(expected) {
+		t.Fatalf("expected %d status details, got %d", len(expected), len(doc.StatusDetails))
+	}
+	for i, detail := range doc.StatusDetails {
+		if detail.Message != expected[i] {
+			t.Errorf("status detail %d: expected %q, got %q", i, expected[i], detail.Message)
+		}
+	}
+}
+
+func testAnswerChallenge(t *testing.T, f *Fixture) {
+	ds := f.Datastore
+	id := insert(t, ds, &model.Document{Owner: "a@example.com"})
+
+	// Only a document awaiting input can be answered
+	if _, err := ds.AnswerChallenge(id, "123456"); err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound answering a pending document, got %v", err)
+	}
+
+	challenge := model.Challenge{Gate: model.GateVerification, Message: "Enter the code"}
+	doc, err := ds.AwaitInput(id, challenge, "Enter the code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Status != model.StatusAwaitingInput || doc.Challenge == nil || doc.Challenge.Gate != model.GateVerification {
+		t.Errorf("expected the document awaiting the challenge, got %s with %+v", doc.Status, doc.Challenge)
+	}
+	if doc, err = ds.AnswerChallenge(id, "123456"); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Job.Answer != "123456" {
+		t.Errorf("expected the answer stored, got %q", doc.Job.Answer)
+	}
+
+	// Any other status clears the challenge and its answer
+	if _, err := ds.UpdateStatus(id, model.StatusCapturing, ""); err != nil {
+		t.Fatal(err)
+	}
+	if doc, err = ds.GetDocument(id); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Challenge != nil || doc.Job.Answer != "" {
+		t.Errorf("expected the challenge and answer cleared, got %+v and %q", doc.Challenge, doc.Job.Answer)
+	}
+}
+
+func testAcquireLease(t *testing.T, f *Fixture) {
+	ds := f.Datastore
+	id := insert(t, ds, &model.Document{Owner: "a@example.com"})
+
+	// Expiry times long after the test, and one long before it
+	future, later, expired := int64(1)<<61, int64(1)<<62, int64(1)
+
+	tests := []struct {
+		name    string
+		owner   string
+		expires int64
+		err     error
+	}{
+		{"unleased", "first", future, nil},
+		{"held", "second", future, store.ErrLeaseHeld},
+		{"renewed", "first", later, nil},
+	}
+	for _, tt := range tests {
+		doc, err := ds.AcquireLease(id, tt.owner, tt.expires)
+		if err != tt.err {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+			continue
+		}
+		if err == nil && (doc.Job.LeaseOwner != tt.owner || doc.Job.LeaseExpires != tt.expires) {
+			t.Errorf("%s: expected the lease held by %s until %d, got %+v", tt.name, tt.owner, tt.expires, doc.Job)
+		}
+	}
+
+	// Releasing a lease held by another owner leaves it in place
+	if err := ds.ReleaseLease(id, "second"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.AcquireLease(id, "second", future); err != store.ErrLeaseHeld {
+		t.Errorf("expected the lease still held, got %v", err)
+	}
+	if err := ds.ReleaseLease(id, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.AcquireLease(id, "second", expired); err != nil {
+		t.Errorf("expected the released lease to be acquired, got %v", err)
+	}
+
+	// An expired lease can be taken over
+	if _, err := ds.AcquireLease(id, "third", future); err != nil {
+		t.Errorf("expected the expired lease to be taken over, got %v", err)
+	}
+
+	// Documents no longer active can't be leased
+	if _, err := ds.UpdateStatus(id, model.StatusComplete, "done"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.AcquireLease(id, "third", future); err != store.ErrLeaseHeld {
+		t.Errorf("expected ErrLeaseHeld leasing a complete document, got %v", err)
+	}
+}
+
+func testClaimWatch(t *testing.T, f *Fixture) {
+	ds := f.Datastore
+	due := insert(t, ds, &model.Document{Owner: "a@example.com"})
+	later := insert(t, ds, &model.Document{Owner: "a@example.com"})
+	revoked := insert(t, ds, &model.Document{Owner: "a@example.com"})
+	unwatched := insert(t, ds, &model.Document{Owner: "a@example.com"})
+
+	watches := map[string]*model.Watch{
+		due:     &model.Watch{Interval: "daily", NextCheck: 100},
+		later:   &model.Watch{Interval: "daily", NextCheck: 500},
+		revoked: &model.Watch{Interval: "daily", NextCheck: 100, Revoked: true},
+	}
+	for id, watch := range watches {
+		if _, err := ds.UpdateWatch(id, watch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	docs, err := ds.GetWatchedDocuments(200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].ID.Hex() != due {
+		t.Fatalf("expected only the due document, got %d documents", len(docs))
+	}
+
+	// The check is claimed once for the time it was due
+	doc, err := ds.ClaimWatch(due, 100, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Watch.NextCheck != 300 || doc.Watch.LastChecked == 0 {
+		t.Errorf("expected the next check scheduled, got %+v", doc.Watch)
+	}
+	if _, err := ds.ClaimWatch(due, 100, 300); err != store.ErrLeaseHeld {
+		t.Errorf("expected ErrLeaseHeld claiming the check again, got %v", err)
+	}
+	if docs, _ := ds.GetWatchedDocuments(200); len(docs) != 0 {
+		t.Errorf("expected no documents due once claimed, got %d", len(docs))
+	}
+
+	for name, id := range map[string]string{"revoked": revoked, "unwatched": unwatched} {
+		if _, err := ds.ClaimWatch(id, 100, 300); err != store.ErrLeaseHeld {
+			t.Errorf("%s: expected ErrLeaseHeld, got %v", name, err)
+		}
+	}
+
+	// Stopping the watch stops the checks
+	if _, err := ds.UpdateWatch(later, nil); err != nil {
+		t.Fatal(err)
+	}
+	if docs, _ := ds.GetWatchedDocuments(1000); len(docs) != 1 || docs[0].ID.Hex() != due {
+		t.Errorf("expected only the rescheduled document due, got %d documents", len(docs))
+	}
+}
@@ -0,0 +1,56 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+// ErrInputTimeout is returned when the user doesn't answer the challenge a
+// task is waiting on in time
+var ErrInputTimeout = errors.New("Timed out waiting for input")
+
+// AnswerPollInterval is how often a task waiting on input checks for an answer
+var AnswerPollInterval = 2 * time.Second
+
+// Answerer supplies the answers users give to the challenges their tasks are
+// waiting on, even when the answer was given to another process
+type Answerer interface {
+
+	// Answer returns the answer to the challenge of the task with the
+	// supplied id, or "" if it hasn't been answered yet
+	Answer(id string) (string, error)
+}
+
+// awaitAnswer waits for the answer to the challenge of the task with the
+// supplied id.  Returns ErrInputTimeout if it isn't answered within the
+// timeout, or the context's error if it's cancelled first
+func awaitAnswer(ctx context.Context, answers Answerer, id string, timeout time.Duration) (string, error) {
+
+	ticker := time.NewTicker(AnswerPollInterval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			answer, err := answers.Answer(id)
+			if err != nil {
+				logger.Warnf("Failed to check for an answer to task %s: %s", id, err.Error())
+				continue
+			}
+			if answer != "" {
+				return answer, nil
+			}
+
+		case <-deadline.C:
+			return "", ErrInputTimeout
+
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
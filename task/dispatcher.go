@@ -11,6 +11,7 @@ import (
 // a Worker becomes available
 type Dispatcher struct {
 	workerPool      chan chan Task
+	running         *runningTasks
 	statusChannel   chan TaskStatus
 	completeChannel chan Task
 	errorChannel    chan Failure
@@ -21,12 +22,13 @@ type Dispatcher struct {
 func NewDispatcher(maxWorkers int) *Dispatcher {
 	d := new(Dispatcher)
 	d.workerPool = make(chan chan Task, maxWorkers)
+	d.running = newRunningTasks()
 	d.statusChannel = make(chan TaskStatus)
 	d.completeChannel = make(chan Task)
 	d.errorChannel = make(chan Failure)
 	d.workers = make([]*Worker, 0, maxWorkers)
 	for i := 0; i < maxWorkers; i++ {
-		d.workers = append(d.workers, NewWorker(i, d.workerPool, d.statusChannel, d.completeChannel, d.errorChannel))
+		d.workers = append(d.workers, NewWorker(i, d.workerPool, d.running, d.statusChannel, d.completeChannel, d.errorChannel))
 	}
 	return d
 }
@@ -99,11 +101,25 @@ func (d *Dispatcher) Dispatch(task Task) {
 	taskChannel <- task
 }
 
+// Cancel - Cancels the context of the running task with the supplied id,
+// returning false if no such task is running
+func (d *Dispatcher) Cancel(id string) bool {
+	return d.running.cancel(id)
+}
+
 // NonBlockingDispatcher - A Dispatcher that will not block when adding new
 // tasks, rather it will queue the tasks until a worker becomes available
 type NonBlockingDispatcher struct {
 	*Dispatcher
-	taskQueue chan Task
+	taskQueue       chan Task
+	cancelQueue     chan cancelRequest
+	dispatchStopped chan bool
+}
+
+// cancelRequest asks the dispatch loop to cancel the task with the given id
+type cancelRequest struct {
+	id        string
+	cancelled chan bool
 }
 
 // NewNonBlockingDispatcher - Create a new non-blocking Dispatcher
@@ -111,6 +127,8 @@ func NewNonBlockingDispatcher(maxWorkers int) *NonBlockingDispatcher {
 	d := new(NonBlockingDispatcher)
 	d.Dispatcher = NewDispatcher(maxWorkers)
 	d.taskQueue = make(chan Task)
+	d.cancelQueue = make(chan cancelRequest)
+	d.dispatchStopped = make(chan bool, 1)
 	return d
 }
 
@@ -120,11 +138,14 @@ func (d *NonBlockingDispatcher) Start() {
 	go d.dispatch()
 }
 
-// Stop - Stops this Dispatcher waiting for all tasks to complete
+// Stop - Stops this Dispatcher waiting for all running tasks to complete.
+// Queued tasks that haven't started are dropped
 func (d *NonBlockingDispatcher) Stop() {
 
-	// Close the task queue
+	// Close the task queue and wait for the dispatch loop to exit so nothing
+	// is handed to a stopping Worker
 	close(d.taskQueue)
+	<-d.dispatchStopped
 
 	d.Dispatcher.Stop()
 }
@@ -134,16 +155,72 @@ func (d *NonBlockingDispatcher) Dispatch(task Task) {
 	d.taskQueue <- task
 }
 
-// dispatch - Pulls tasks off the pending task queue and executes them
+// Cancel - Cancels the task with the supplied id, removing it from the queue if
+// it hasn't started or cancelling its context if it is running.  Returns false
+// if no such task is queued or running
+func (d *NonBlockingDispatcher) Cancel(id string) bool {
+	req := cancelRequest{id: id, cancelled: make(chan bool, 1)}
+	d.cancelQueue <- req
+	return <-req.cancelled
+}
+
+// dispatch - Pulls tasks off the task queue into a pending list and hands them
+// to Workers as they become idle.  This loop will complete when the taskQueue
+// chan is closed
 func (d *NonBlockingDispatcher) dispatch() {
-	// Pull tasks from the taskQueue.  This loop will complete when the taskQueue
-	// chan is closed
-	for task := range d.taskQueue {
-		// try to obtain a worker task channel that is available, this will block
-		// until a worker is idle
-		taskChannel := <-d.workerPool
-
-		// dispatch the task to the worker task channel
-		taskChannel <- task
+	pending := make([]Task, 0)
+	for {
+		// Only wait on an idle worker when we have something to give it
+		var workerPool chan chan Task
+		if len(pending) > 0 {
+			workerPool = d.workerPool
+		}
+
+		select {
+		case task, more := <-d.taskQueue:
+			if !more {
+				if len(pending) > 0 {
+					logger.Infof("Dropping %d queued tasks", len(pending))
+				}
+				d.dispatchStopped <- true
+				return
+			}
+			// A task already queued isn't queued again
+			if queued(pending, task.ID()) {
+				logger.Infof("Task %s is already queued, dropping the duplicate", task.ID())
+				continue
+			}
+			pending = append(pending, task)
+
+		case req := <-d.cancelQueue:
+			// Drop the task if it's still queued, otherwise it may be running
+			cancelled := false
+			for i, task := range pending {
+				if task.ID() == req.id {
+					pending = append(pending[:i], pending[i+1:]...)
+					cancelled = true
+					break
+				}
+			}
+			if !cancelled {
+				cancelled = d.Dispatcher.Cancel(req.id)
+			}
+			req.cancelled <- cancelled
+
+		case taskChannel := <-workerPool:
+			// dispatch the task to the worker task channel
+			taskChannel <- pending[0]
+			pending = pending[1:]
+		}
+	}
+}
+
+// queued reports whether a task with the supplied id is in the list
+func queued(pending []Task, id string) bool {
+	for _, task := range pending {
+		if task.ID() == id {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,130 @@
+package task
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// funcTask is a Task running the supplied function
+type funcTask struct {
+	id string
+	fn func(ctx context.Context) error
+}
+
+func (t *funcTask) ID() string {
+	return t.id
+}
+
+func (t *funcTask) Execute(ctx context.Context, status chan<- TaskStatus) error {
+	return t.fn(ctx)
+}
+
+// drain consumes the Dispatcher's channels until it's stopped, counting the
+// tasks completed
+func drain(d *NonBlockingDispatcher, completed *int32) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for range d.Complete() {
+			atomic.AddInt32(completed, 1)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range d.Status() {
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range d.Error() {
+		}
+	}()
+	return &wg
+}
+
+func TestDispatcherDropsQueuedDuplicate(t *testing.T) {
+	d := NewNonBlockingDispatcher(1)
+	d.Start()
+	var completed int32
+	wg := drain(d, &completed)
+
+	// Keep the only worker busy so the others stay queued
+	release := make(chan bool)
+	d.Dispatch(&funcTask{id: "busy", fn: func(ctx context.Context) error {
+		<-release
+		return nil
+	}})
+
+	var runs int32
+	for i := 0; i < 2; i++ {
+		d.Dispatch(&funcTask{id: "doc", fn: func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return nil
+		}})
+	}
+	close(release)
+
+	waitFor(t, func() bool { return atomic.LoadInt32(&completed) == 2 })
+	d.Stop()
+	wg.Wait()
+
+	if runs != 1 {
+		t.Errorf("expected the duplicate task to be dropped, it ran %d times", runs)
+	}
+}
+
+func TestDispatcherNeverRunsTaskTwiceAtOnce(t *testing.T) {
+	d := NewNonBlockingDispatcher(2)
+	d.Start()
+	var completed int32
+	wg := drain(d, &completed)
+
+	var running, overlapped int32
+	started := make(chan bool, 2)
+	release := make(chan bool)
+	task := func(ctx context.Context) error {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		started <- true
+		<-release
+		atomic.AddInt32(&running, -1)
+		return nil
+	}
+
+	// The second task is dispatched once the first is running, so it isn't
+	// dropped as queued but must wait for the first to finish
+	d.Dispatch(&funcTask{id: "doc", fn: task})
+	<-started
+	d.Dispatch(&funcTask{id: "doc", fn: task})
+
+	select {
+	case <-started:
+		t.Error("expected the second task to wait for the first to finish")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	waitFor(t, func() bool { return atomic.LoadInt32(&completed) == 2 })
+	d.Stop()
+	wg.Wait()
+
+	if overlapped != 0 {
+		t.Error("expected tasks with the same id never to run at once")
+	}
+}
+
+// waitFor waits for the condition to hold
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,89 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+// ErrLeased is returned when a Task is already leased by another worker.
+// Workers drop such tasks rather than reporting them as failed
+var ErrLeased = errors.New("task is leased by another worker")
+
+// Leaser grants exclusive, expiring leases on tasks so the same task can't be
+// run twice, even by workers in different processes
+type Leaser interface {
+
+	// Acquire or renew the lease on the task with the supplied id for the
+	// given duration.  Returns ErrLeased if the task can't be leased
+	Acquire(id string, ttl time.Duration) error
+
+	// Release the lease on the task with the supplied id
+	Release(id string)
+}
+
+type leasedTask struct {
+	Task
+	leaser Leaser
+	ttl    time.Duration
+}
+
+// NewLeasedTask wraps the supplied Task so that it only executes while holding
+// a lease, renewing the lease until the Task completes.  If the lease is lost,
+// for instance because the task was cancelled elsewhere, the Task's context is
+// cancelled and ErrLeased is returned
+func NewLeasedTask(task Task, leaser Leaser, ttl time.Duration) Task {
+	return &leasedTask{
+		Task:   task,
+		leaser: leaser,
+		ttl:    ttl,
+	}
+}
+
+func (t *leasedTask) Execute(ctx context.Context, status chan<- TaskStatus) error {
+
+	if err := t.leaser.Acquire(t.ID(), t.ttl); err != nil {
+		return err
+	}
+	defer t.leaser.Release(t.ID())
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Renew the lease well before it expires until the Task completes
+	done := make(chan bool)
+	lost := make(chan bool, 1)
+	go func() {
+		ticker := time.NewTicker(t.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				err := t.leaser.Acquire(t.ID(), t.ttl)
+				if err == ErrLeased {
+					logger.Warnf("Lost lease on task %s, stopping it", t.ID())
+					lost <- true
+					cancel()
+					return
+				}
+				if err != nil {
+					logger.Warnf("Failed to renew lease on task %s: %s", t.ID(), err.Error())
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := t.Task.Execute(ctx, status)
+	close(done)
+
+	select {
+	case <-lost:
+		return ErrLeased
+	default:
+		return err
+	}
+}
@@ -0,0 +1,145 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/scraper"
+	logger "github.com/sirupsen/logrus"
+)
+
+// RetryPolicy controls how a failed Task is retried
+type RetryPolicy struct {
+
+	// The maximum number of attempts, including the first
+	MaxAttempts int
+
+	// The delay before the first retry
+	InitialBackoff time.Duration
+
+	// The upper bound on the delay between attempts
+	MaxBackoff time.Duration
+
+	// The factor the delay grows by after each attempt
+	Multiplier float64
+
+	// The fraction of the delay randomly added or removed, between 0 and 1
+	Jitter float64
+
+	// Classifies errors as retryable, defaults to IsRetryable
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy returns a RetryPolicy with the default values
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 5 * time.Second,
+		MaxBackoff:     2 * time.Minute,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// Backoff returns the delay to wait after the supplied failed attempt
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if max := float64(p.MaxBackoff); backoff > max {
+		backoff = max
+	}
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(backoff)
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// permanentError marks an error as not worth retrying
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Permanent marks the supplied error as not retryable
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsRetryable is the default error classifier.  Authentication failures,
+// unanswered challenges, client errors, unsupported images, extraction rules
+// that don't match and errors marked Permanent aren't retried, anything else
+// is assumed to be transient
+func IsRetryable(err error) bool {
+	switch e := err.(type) {
+	case *permanentError, *scraper.RulesError:
+		return false
+	case *scraper.HTTPError:
+		return e.Temporary()
+	case *scraper.ImageError:
+		return e.Temporary()
+	}
+	return err != scraper.ErrAuthenticationFailed && err != scraper.ErrUnsupportedSite && err != ErrLeased &&
+		err != scraper.ErrInputRequired && err != ErrInputTimeout && err != scraper.ErrNoRulesMatched
+}
+
+type retryTask struct {
+	Task
+	policy RetryPolicy
+}
+
+// NewRetryTask wraps the supplied Task so that retryable failures are retried
+// according to the policy.  Each failed attempt is reported as a status update
+func NewRetryTask(task Task, policy RetryPolicy) Task {
+	return &retryTask{
+		Task:   task,
+		policy: policy,
+	}
+}
+
+func (t *retryTask) Execute(ctx context.Context, status chan<- TaskStatus) error {
+	for attempt := 1; ; attempt++ {
+
+		if attempt > 1 {
+			status <- TaskStatus{Message: fmt.Sprintf("Starting attempt %d of %d", attempt, t.policy.MaxAttempts), Task: t}
+		}
+
+		err := t.Task.Execute(ctx, status)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if attempt >= t.policy.MaxAttempts || !t.policy.retryable(err) {
+			return err
+		}
+
+		backoff := t.policy.Backoff(attempt)
+		logger.Infof("Task %s attempt %d failed, retrying in %s: %s", t.ID(), attempt, backoff, err.Error())
+		status <- TaskStatus{
+			Message: fmt.Sprintf("Attempt %d of %d failed: %s, retrying in %s", attempt, t.policy.MaxAttempts, err.Error(), backoff.Round(time.Second)),
+			Task:    t,
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
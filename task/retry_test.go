@@ -0,0 +1,151 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/scraper"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+	}
+
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{10, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if backoff := policy.Backoff(tt.attempt); backoff != tt.expected {
+			t.Errorf("attempt %d: expected %s, got %s", tt.attempt, tt.expected, backoff)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	policy := RetryPolicy{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+
+	varied := false
+	for i := 0; i < 100; i++ {
+		backoff := policy.Backoff(2)
+		if backoff < 1600*time.Millisecond || backoff > 2400*time.Millisecond {
+			t.Fatalf("expected the backoff within 20%% of 2s, got %s", backoff)
+		}
+		if backoff != 2*time.Second {
+			varied = true
+		}
+	}
+	if !varied {
+		t.Error("expected the jitter to vary the backoff")
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"server error", &scraper.HTTPError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"rate limited", &scraper.HTTPError{StatusCode: http.StatusTooManyRequests}, true},
+		{"not found", &scraper.HTTPError{StatusCode: http.StatusNotFound}, false},
+		{"forbidden", &scraper.HTTPError{StatusCode: http.StatusForbidden}, false},
+		{"truncated image", &scraper.ImageError{Page: 1, Message: "unexpected EOF"}, true},
+		{"unsupported image", &scraper.ImageError{Page: 1, Message: "unsupported", Unsupported: true}, false},
+		{"authentication", scraper.ErrAuthenticationFailed, false},
+		{"unsupported site", scraper.ErrUnsupportedSite, false},
+		{"no rules", scraper.ErrNoRulesMatched, false},
+		{"no page rules", &scraper.RulesError{Page: 1}, false},
+		{"input required", scraper.ErrInputRequired, false},
+		{"input timeout", ErrInputTimeout, false},
+		{"leased", ErrLeased, false},
+		{"permanent", Permanent(errors.New("bad request")), false},
+		{"unknown", errors.New("connection reset by peer"), true},
+	}
+	for _, tt := range tests {
+		if retryable := IsRetryable(tt.err); retryable != tt.retryable {
+			t.Errorf("%s: expected retryable %t, got %t", tt.name, tt.retryable, retryable)
+		}
+	}
+}
+
+func TestRetryTask(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		Multiplier:     2,
+	}
+
+	tests := []struct {
+		name     string
+		errs     []error
+		attempts int
+		err      error
+	}{
+		{"succeeds", []error{nil}, 1, nil},
+		{"retried", []error{&scraper.HTTPError{StatusCode: 502}, &scraper.HTTPError{StatusCode: 502}, nil}, 3, nil},
+		{"permanent", []error{scraper.ErrAuthenticationFailed}, 1, scraper.ErrAuthenticationFailed},
+		{"exhausted", []error{ioError(1), ioError(2), ioError(3)}, 3, ioError(3)},
+	}
+	for _, tt := range tests {
+		attempts := 0
+		task := NewRetryTask(&funcTask{id: "doc", fn: func(ctx context.Context) error {
+			err := tt.errs[attempts]
+			attempts++
+			return err
+		}}, policy)
+
+		status := make(chan TaskStatus, 10)
+		err := task.Execute(context.Background(), status)
+		if attempts != tt.attempts {
+			t.Errorf("%s: expected %d attempts, got %d", tt.name, tt.attempts, attempts)
+		}
+		if err != tt.err {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestRetryTaskCancelled(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Hour,
+		MaxBackoff:     time.Hour,
+		Multiplier:     2,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	task := NewRetryTask(&funcTask{id: "doc", fn: func(ctx context.Context) error {
+		cancel()
+		return errors.New("connection reset by peer")
+	}}, policy)
+
+	if err := task.Execute(ctx, make(chan TaskStatus, 10)); err != context.Canceled {
+		t.Errorf("expected the cancelled task to stop retrying, got %v", err)
+	}
+}
+
+// ioError is a transient error, comparable so it can be expected
+type ioError int
+
+func (e ioError) Error() string {
+	return "i/o timeout"
+}
@@ -0,0 +1,62 @@
+package task
+
+import (
+	"context"
+	"sync"
+)
+
+// runningTask is the entry for a single running Task
+type runningTask struct {
+	cancel context.CancelFunc
+	done   chan bool
+}
+
+// runningTasks tracks the cancel functions of the tasks being executed by a
+// Dispatcher's Workers
+type runningTasks struct {
+	mutex sync.Mutex
+	tasks map[string]*runningTask
+}
+
+func newRunningTasks() *runningTasks {
+	return &runningTasks{tasks: make(map[string]*runningTask)}
+}
+
+// add registers a running task, returning its entry for removal.  A task with
+// the same id never runs twice at once, if one is still running, such as a
+// cancelled capture that hasn't stopped yet, add waits for it to finish
+func (r *runningTasks) add(id string, cancel context.CancelFunc) *runningTask {
+	entry := &runningTask{cancel: cancel, done: make(chan bool)}
+	for {
+		r.mutex.Lock()
+		running, ok := r.tasks[id]
+		if !ok {
+			r.tasks[id] = entry
+			r.mutex.Unlock()
+			return entry
+		}
+		r.mutex.Unlock()
+		<-running.done
+	}
+}
+
+// remove unregisters a task, letting any waiting to run with the same id start
+func (r *runningTasks) remove(id string, entry *runningTask) {
+	r.mutex.Lock()
+	if r.tasks[id] == entry {
+		delete(r.tasks, id)
+	}
+	r.mutex.Unlock()
+	close(entry.done)
+}
+
+// cancel cancels the running task with the supplied id
+func (r *runningTasks) cancel(id string) bool {
+	r.mutex.Lock()
+	entry, ok := r.tasks[id]
+	r.mutex.Unlock()
+	if ok {
+		entry.cancel()
+	}
+	return ok
+}
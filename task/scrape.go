@@ -1,8 +1,13 @@
 package task
 
 import (
+	"context"
+	"fmt"
 	"net/url"
+	"path"
+	"time"
 
+	"github.com/aldelucca1/docsend_scraper/model"
 	"github.com/aldelucca1/docsend_scraper/scraper"
 	"github.com/aldelucca1/docsend_scraper/store"
 )
@@ -10,31 +15,77 @@ import (
 type scrapeTask struct {
 	os       store.ObjectStore
 	id       string
+	version  int
 	url      *url.URL
+	dst      string
 	email    string
 	passcode string
+	options  scraper.Options
+	answers  Answerer
+	timeout  time.Duration
 }
 
-// NewScrapeTask creates a new task for scraping the given URL
-func NewScrapeTask(os store.ObjectStore, id string, url *url.URL, email string, passcode string) Task {
+// NewScrapeTask creates a new task for capturing a version of the document
+// from the given URL, writing it to the supplied path within the object store.
+// A capture needing input from the user waits up to the timeout for it to be
+// answered through the Answerer, while it fails straight away with
+// scraper.ErrInputRequired if the Answerer is nil
+func NewScrapeTask(os store.ObjectStore, id string, version int, url *url.URL, dst string, email string, passcode string, options scraper.Options, answers Answerer, timeout time.Duration) Task {
 	task := &scrapeTask{
 		os:       os,
 		id:       id,
+		version:  version,
 		url:      url,
+		dst:      dst,
 		email:    email,
 		passcode: passcode,
+		options:  options,
+		answers:  answers,
+		timeout:  timeout,
 	}
 	return task
 }
 
+// CachePrefix returns where the pages of a version of the document are
+// cached while it's captured
+func CachePrefix(id string, version int) string {
+	return path.Join("cache", id, fmt.Sprintf("v%d", version))
+}
+
 func (t *scrapeTask) ID() string {
 	return t.id
 }
 
-func (t *scrapeTask) Execute(status chan<- TaskStatus) error {
+func (t *scrapeTask) Execute(ctx context.Context, status chan<- TaskStatus) error {
 	s := scraper.NewScraper(t.os)
+	s.Options = t.options
+	s.Cache = t.os
+	s.CachePrefix = CachePrefix(t.id, t.version)
 	s.StatusHandler = func(msg string) {
 		status <- TaskStatus{Message: msg, Task: t}
 	}
-	return s.Scrape(t.url, t.email, t.passcode)
+	s.ProgressHandler = func(progress model.Progress) {
+		status <- TaskStatus{Progress: &progress, Task: t}
+	}
+	if t.answers != nil {
+		s.ChallengeHandler = func(challenge model.Challenge) (string, error) {
+			status <- TaskStatus{Challenge: &challenge, Task: t}
+			answer, err := awaitAnswer(ctx, t.answers, t.id, t.timeout)
+			if err != nil {
+				return "", err
+			}
+			status <- TaskStatus{Message: "Received the answer, continuing the capture", Task: t}
+			return answer, nil
+		}
+	}
+	err := s.ScrapeTo(ctx, t.url, t.email, t.passcode, t.dst)
+	if err == scraper.ErrUnchanged {
+		status <- TaskStatus{Unchanged: true, Task: t}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	status <- TaskStatus{Capture: s.Capture, Task: t}
+	return nil
 }
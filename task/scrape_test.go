@@ -0,0 +1,177 @@
+package task
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aldelucca1/docsend_scraper/scraper"
+	"github.com/aldelucca1/docsend_scraper/scraper/fake"
+	"github.com/aldelucca1/docsend_scraper/store"
+	"github.com/aldelucca1/docsend_scraper/store/memory"
+)
+
+// answers is an Answerer with a fixed answer for each task id
+type answers map[string]string
+
+func (a answers) Answer(id string) (string, error) {
+	return a[id], nil
+}
+
+// startFakeServer starts a fake DocSend server serving the documents, which
+// every Scraper created captures from until it's closed
+func startFakeServer(docs ...fake.Document) *fake.Server {
+	server := fake.NewServer()
+	for _, doc := range docs {
+		server.AddDocument(doc)
+	}
+	scraper.SetBaseClient(server.Client())
+	return server
+}
+
+func stopFakeServer(server *fake.Server) {
+	server.Close()
+	scraper.SetBaseClient(nil)
+}
+
+// execute runs the task, returning its error and the statuses it reported
+func execute(task Task) ([]TaskStatus, error) {
+	ch := make(chan TaskStatus)
+	done := make(chan []TaskStatus)
+	go func() {
+		var statuses []TaskStatus
+		for status := range ch {
+			statuses = append(statuses, status)
+		}
+		done <- statuses
+	}()
+	err := task.Execute(context.Background(), ch)
+	close(ch)
+	return <-done, err
+}
+
+// newTestScrapeTask returns a task capturing the fake document with the slug
+// to deck.pdf in the object store
+func newTestScrapeTask(os store.ObjectStore, server *fake.Server, slug string, passcode string, options scraper.Options, answers Answerer) Task {
+	return NewScrapeTask(os, "doc", 1, server.DocumentURL(slug), "deck.pdf", "a@example.com", passcode, options, answers, 500*time.Millisecond)
+}
+
+func TestScrapeTask(t *testing.T) {
+	server := startFakeServer(fake.NewDocument("deck", 3, "secret"))
+	defer stopFakeServer(server)
+	os := memory.NewObjectStore()
+
+	statuses, err := execute(newTestScrapeTask(os, server, "deck", "secret", scraper.DefaultOptions(), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := statuses[len(statuses)-1]
+	if last.Capture == nil || last.Capture.Pages != 3 {
+		t.Fatalf("expected the capture of 3 pages to be reported last, got %+v", last)
+	}
+	if exists, _ := os.Exists("deck.pdf"); !exists {
+		t.Error("expected the document to be written")
+	}
+
+	// Capturing the same pages again is reported as unchanged
+	options := scraper.DefaultOptions()
+	options.Previous = last.Capture.PageHashes
+	statuses, err = execute(newTestScrapeTask(os, server, "deck", "secret", options, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := statuses[len(statuses)-1]; !last.Unchanged {
+		t.Errorf("expected the capture to be reported unchanged, got %+v", last)
+	}
+}
+
+func TestScrapeTaskAuthenticationFailed(t *testing.T) {
+	server := startFakeServer(fake.NewDocument("deck", 2, "secret"))
+	defer stopFakeServer(server)
+
+	task := NewRetryTask(newTestScrapeTask(memory.NewObjectStore(), server, "deck", "guess", scraper.DefaultOptions(), nil), RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		Multiplier:     2,
+	})
+	_, err := execute(task)
+	if err != scraper.ErrAuthenticationFailed {
+		t.Errorf("expected ErrAuthenticationFailed, got %v", err)
+	}
+	if n := server.Requests("/view/deck"); n != 2 {
+		t.Errorf("expected a single attempt opening and posting the auth form, got %d requests", n)
+	}
+}
+
+func TestScrapeTaskRetried(t *testing.T) {
+	server := startFakeServer(fake.NewDocument("deck", 2, ""))
+	defer stopFakeServer(server)
+	server.Fail(fake.Failure{Path: "/view/deck", Times: 2, Status: http.StatusServiceUnavailable})
+	os := memory.NewObjectStore()
+
+	task := NewRetryTask(newTestScrapeTask(os, server, "deck", "", scraper.DefaultOptions(), nil), RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		Multiplier:     2,
+	})
+	statuses, err := execute(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := statuses[len(statuses)-1]; last.Capture == nil || last.Capture.Pages != 2 {
+		t.Errorf("expected the capture to complete once retried, got %+v", last)
+	}
+	if n := server.Requests("/view/deck") - server.Requests("/view/deck/"); n != 3 {
+		t.Errorf("expected the viewer to be opened 3 times, got %d", n)
+	}
+}
+
+func TestScrapeTaskChallenge(t *testing.T) {
+	interval := AnswerPollInterval
+	AnswerPollInterval = 10 * time.Millisecond
+	defer func() {
+		AnswerPollInterval = interval
+	}()
+
+	doc := fake.NewDocument("deck", 2, "")
+	doc.EmailOnly = true
+	doc.VerificationCode = "123456"
+	server := startFakeServer(doc)
+	defer stopFakeServer(server)
+
+	tests := []struct {
+		name     string
+		answers  Answerer
+		err      error
+		asked    bool
+		captured bool
+	}{
+		{"answered", answers{"doc": "123456"}, nil, true, true},
+		{"unanswered", answers{}, ErrInputTimeout, true, false},
+		{"no answerer", nil, scraper.ErrInputRequired, false, false},
+	}
+	for _, tt := range tests {
+		statuses, err := execute(newTestScrapeTask(memory.NewObjectStore(), server, "deck", "", scraper.DefaultOptions(), tt.answers))
+		if err != tt.err {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.err, err)
+		}
+		asked, captured := false, false
+		for _, status := range statuses {
+			if status.Challenge != nil {
+				asked = true
+			}
+			if status.Capture != nil {
+				captured = true
+			}
+		}
+		if asked != tt.asked {
+			t.Errorf("%s: expected the challenge asked %t, got %t", tt.name, tt.asked, asked)
+		}
+		if captured != tt.captured {
+			t.Errorf("%s: expected captured %t, got %t", tt.name, tt.captured, captured)
+		}
+	}
+}
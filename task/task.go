@@ -1,9 +1,22 @@
 package task
 
-// TaskStatus represents a status update from a Task
+import (
+	"context"
+
+	"github.com/aldelucca1/docsend_scraper/model"
+)
+
+// TaskStatus represents a status update from a Task, either a readable message,
+// a progress update when Progress is set, the document produced when Capture
+// is set, that a watch check found nothing changed when Unchanged is set or
+// that the Task is waiting on the user to answer a Challenge
 type TaskStatus struct {
-	Task    Task
-	Message string
+	Task      Task
+	Message   string
+	Progress  *model.Progress
+	Capture   *model.Capture
+	Unchanged bool
+	Challenge *model.Challenge
 }
 
 // Failure represents a failed task
@@ -12,8 +25,9 @@ type Failure struct {
 	Error error
 }
 
-// Task represents the task to be run
+// Task represents the task to be run.  A Task should stop promptly and return
+// once the supplied context is cancelled
 type Task interface {
-	Execute(status chan<- TaskStatus) error
+	Execute(ctx context.Context, status chan<- TaskStatus) error
 	ID() string
 }
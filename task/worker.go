@@ -1,6 +1,8 @@
 package task
 
 import (
+	"context"
+
 	logger "github.com/sirupsen/logrus"
 )
 
@@ -13,13 +15,15 @@ type Worker struct {
 	completeChannel chan<- Task
 	errorChannel    chan<- Failure
 	stoppedChannel  chan bool
+	running         *runningTasks
 }
 
 // NewWorker - Creates a new Worker
-func NewWorker(id int, pool chan chan Task, statusChannel chan<- TaskStatus, completeChannel chan<- Task, errorChannel chan<- Failure) *Worker {
+func NewWorker(id int, pool chan chan Task, running *runningTasks, statusChannel chan<- TaskStatus, completeChannel chan<- Task, errorChannel chan<- Failure) *Worker {
 	w := new(Worker)
 	w.id = id
 	w.pool = pool
+	w.running = running
 	w.statusChannel = statusChannel
 	w.completeChannel = completeChannel
 	w.errorChannel = errorChannel
@@ -40,7 +44,21 @@ func (w *Worker) run() {
 	// the taskChannel is closed
 	for task := range w.taskChannel {
 		logger.Infof("Worker %d: Got task with id: %s", w.id, task.ID())
-		if err := task.Execute(w.statusChannel); err != nil {
+
+		// Run the task with a context that can be cancelled through the
+		// Dispatcher
+		ctx, cancel := context.WithCancel(context.Background())
+		entry := w.running.add(task.ID(), cancel)
+		err := task.Execute(ctx, w.statusChannel)
+		w.running.remove(task.ID(), entry)
+		cancelled := ctx.Err() != nil
+		cancel()
+
+		if err == ErrLeased {
+			logger.Infof("Worker %d: Skipped task with id: %s, it is leased elsewhere", w.id, task.ID())
+		} else if err != nil && cancelled {
+			logger.Infof("Worker %d: Cancelled task with id: %s", w.id, task.ID())
+		} else if err != nil {
 			w.errorChannel <- Failure{Task: task, Error: err}
 		} else {
 			w.completeChannel <- task